@@ -1,20 +1,45 @@
 package main
 
 import (
+	"fmt"
 	"fsync/client/configs"
 	"fsync/client/global"
+	"fsync/client/internal/cli"
 	"fsync/client/internal/storage"
 	"fsync/client/logger"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
 )
 
+// defaultShutdownTimeout 未配置 shutdown_timeout 时退出前等待命令执行完成的时间
+const defaultShutdownTimeout = 30 * time.Second
+
 func main() {
 	// 加载配置
 	log.Println("加载配置项")
 	if err := configs.LoadConfig("client/configs"); err != nil {
-		panic(err)
+		// 配置有误时由 `config check` 列出问题，其他情况直接退出
+		if len(os.Args) < 2 || os.Args[1] != "config" {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	log.Println("加载到配置", global.Config())
+
+	// 带参数时作为命令行工具运行，不启动同步
+	if len(os.Args) > 1 {
+		if err := cli.Run(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-	log.Println("加载到配置", global.Configs)
 
 	// 初始化日志
 	if err := logger.InitLogger(); err != nil {
@@ -28,5 +53,76 @@ func main() {
 		panic(err)
 	}
 
-	select {}
+	// 配置文件变化时自动重新加载
+	configs.Watch(reloadConfig)
+
+	// 等待退出信号或控制接口的退出请求，SIGHUP 重新加载配置
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+wait:
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				reloadConfig()
+				continue
+			}
+			global.Logger.Info("收到退出信号，正在退出", zap.String("signal", sig.String()))
+			break wait
+		case <-storage.ShutdownRequested():
+			break wait
+		}
+	}
+
+	// 退出过程中再次收到信号时立即退出，未完成的传输下次启动时续传
+	go func() {
+		<-signals
+		global.Logger.Warn("再次收到退出信号，立即退出")
+		logger.Sync()
+		os.Exit(1)
+	}()
+
+	timeout := global.Config().Client.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	storage.StopFileSync(timeout)
+	global.Logger.Info("客户端已退出")
+}
+
+// reloadMutex 串行化 SIGHUP 和配置文件变化触发的重新加载
+var reloadMutex sync.Mutex
+
+// reloadConfig 重新读取配置文件，校验通过后应用可以在运行中生效的修改；
+// 包含需要重启的修改或校验失败时整体拒绝，继续使用当前配置
+func reloadConfig() {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	cfg, err := configs.Reload()
+	var changed []string
+	if err == nil {
+		changed, err = configs.CheckLive(global.Config(), cfg)
+	}
+	if err != nil {
+		global.Logger.Error("重新加载配置失败，继续使用当前配置", zap.Error(err))
+		return
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	// 日志级别已在校验时检查过
+	_ = logger.SetLevel(cfg.Logger.Level)
+	previous := global.Config()
+	global.SetConfig(cfg)
+	timeout := cfg.Client.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	if err := storage.ApplyLive(previous, changed, timeout); err != nil {
+		global.Logger.Error("应用新配置失败", zap.Error(err))
+		return
+	}
+	global.Logger.Info("已重新加载配置", zap.Strings("changed", changed))
 }
@@ -2,24 +2,64 @@ package configs
 
 import (
 	"fsync/client/global"
+	"fsync/client/models"
+	"fsync/pkg/utils"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
+// EnvPrefix 环境变量覆盖配置项时使用的前缀，配置项中的 . 换成 _ 并转为大写，
+// 如 FSYNC_CLIENT_SYNC_DIR 覆盖 client.sync_dir，FSYNC_CLIENT_IGNORE="*.tmp,*.swp" 覆盖列表
+const EnvPrefix = "FSYNC"
+
+// LoadConfig 读取配置文件并校验。校验失败时返回列出所有问题的错误，仍会通过 global.SetConfig 发布解析出的配置
 func LoadConfig(path string) error {
 	viper.AddConfigPath(path)
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 
-	viper.AutomaticEnv() // 允许环境变量覆盖
+	// 允许环境变量覆盖，逐项绑定使配置文件中没有写出的配置项也能被覆盖
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	for _, key := range utils.ConfigKeys(models.Config{}) {
+		_ = viper.BindEnv(key)
+	}
 
 	if err := viper.ReadInConfig(); err != nil {
 		return err
 	}
 
-	if err := viper.Unmarshal(&global.Configs); err != nil {
-		return err
+	cfg, err := decode()
+	global.SetConfig(cfg)
+	return err
+}
+
+// Reload 重新读取配置文件并返回校验通过的新配置，不调用 global.SetConfig，由调用方决定如何应用
+func Reload() (*models.Config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, err
 	}
+	cfg, err := decode()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decode 解析并校验配置，解析失败的配置项不再重复报告校验问题
+func decode() (*models.Config, error) {
+	var cfg models.Config
+	problems := make(utils.ConfigProblems, 0)
+	if err := viper.Unmarshal(&cfg, viper.DecodeHook(utils.ConfigDecodeHook())); err != nil {
+		problems = append(problems, utils.DecodeProblems(err)...)
+	}
+	problems = problems.Merge(Validate(&cfg))
+	return &cfg, problems.Err()
+}
 
-	return nil
+// File 返回正在使用的配置文件路径
+func File() string {
+	return viper.ConfigFileUsed()
 }
@@ -0,0 +1,153 @@
+package configs
+
+import (
+	"fsync/client/internal/bandwidth"
+	"fsync/client/internal/compress"
+	"fsync/client/internal/e2e"
+	"fsync/client/internal/ignore"
+	"fsync/client/models"
+	"fsync/pkg/crypto"
+	"fsync/pkg/utils"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxSocketPath Unix 套接字路径的长度上限（macOS 为 104 字节，Linux 为 108 字节，取较小值）
+const maxSocketPath = 103
+
+// Validate 展开路径中的 ~ 并校验配置，返回发现的所有问题
+func Validate(cfg *models.Config) utils.ConfigProblems {
+	p := make(utils.ConfigProblems, 0)
+
+	// 日志
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(cfg.Logger.Level)); err != nil {
+		p.Add("logger.level", "无效的日志级别 %q，可选 debug、info、warn、error、dpanic、panic、fatal", cfg.Logger.Level)
+	}
+	if cfg.Logger.Format != "json" && cfg.Logger.Format != "console" {
+		p.Add("logger.format", "无效的日志格式 %q，可选 json、console", cfg.Logger.Format)
+	}
+	expandPaths(&p, "logger.output_paths", cfg.Logger.OutputPaths)
+	expandPaths(&p, "logger.error_output_paths", cfg.Logger.ErrorOutputPaths)
+
+	c := &cfg.Client
+
+	// 路径
+	if c.SyncDir == "" {
+		p.Add("client.sync_dir", "不能为空")
+	} else if expandPath(&p, "client.sync_dir", &c.SyncDir) {
+		if info, err := os.Stat(c.SyncDir); err != nil {
+			p.Add("client.sync_dir", "无法访问 %s: %v，请先创建该目录", c.SyncDir, err)
+		} else if !info.IsDir() {
+			p.Add("client.sync_dir", "%s 不是目录", c.SyncDir)
+		}
+	}
+	if c.TokenDir == "" {
+		p.Add("client.token_dir", "不能为空")
+	} else {
+		expandPath(&p, "client.token_dir", &c.TokenDir)
+	}
+	if c.ControlSocket != "" && expandPath(&p, "client.control_socket", &c.ControlSocket) && len(c.ControlSocket) > maxSocketPath {
+		p.Add("client.control_socket", "路径过长（%d 字节，上限 %d），请换一个较短的路径", len(c.ControlSocket), maxSocketPath)
+	}
+
+	// 服务端
+	switch {
+	case c.ServerAddr == "":
+		p.Add("client.server_addr", "不能为空")
+	case strings.Contains(c.ServerAddr, "://"):
+		p.Add("client.server_addr", "%q 不应包含协议，写作 host:port，协议由 client.protocol 指定", c.ServerAddr)
+	default:
+		if _, port, err := net.SplitHostPort(c.ServerAddr); err != nil {
+			p.Add("client.server_addr", "%q 应写作 host:port: %v", c.ServerAddr, err)
+		} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			p.Add("client.server_addr", "端口 %q 应为 1-65535", port)
+		}
+	}
+	if c.Protocol != "http" && c.Protocol != "https" {
+		p.Add("client.protocol", "无效的协议 %q，可选 http、https", c.Protocol)
+	}
+
+	if c.ShutdownTimeout < 0 {
+		p.Add("client.shutdown_timeout", "不能为负数")
+	}
+	if _, err := ignore.New(c.Ignore); err != nil {
+		p.Add("client.ignore", "%v", err)
+	}
+
+	// 回收站与删除保护
+	if c.Trash.MaxAge < 0 {
+		p.Add("client.trash.max_age", "不能为负数")
+	}
+	if c.Trash.MaxSizeMB < 0 {
+		p.Add("client.trash.max_size_mb", "不能为负数")
+	}
+	if c.Trash.CleanInterval < 0 {
+		p.Add("client.trash.clean_interval", "不能为负数")
+	}
+	if c.DeleteBrake.Enabled && c.DeleteBrake.Window <= 0 {
+		p.Add("client.delete_brake.window", "启用删除保护时必须大于 0，如 60s")
+	}
+	if c.DeleteBrake.MaxCount < 0 {
+		p.Add("client.delete_brake.max_count", "不能为负数")
+	}
+	if c.DeleteBrake.MaxPercent < 0 || c.DeleteBrake.MaxPercent > 100 {
+		p.Add("client.delete_brake.max_percent", "应为 0-100")
+	}
+
+	// 传输
+	if !compress.Valid(c.Transfer.Compression) {
+		p.Add("client.transfer.compression", "不支持的压缩编码 %q，可选 zstd、gzip 或留空", c.Transfer.Compression)
+	}
+	if c.Transfer.CompressMinSize < 0 {
+		p.Add("client.transfer.compress_min_size", "不能为负数")
+	}
+	if c.Transfer.PollInterval < 0 {
+		p.Add("client.transfer.poll_interval", "不能为负数")
+	}
+	if _, err := bandwidth.NewController(c.Bandwidth, zap.NewNop()); err != nil {
+		p = append(p, "client."+err.Error())
+	}
+
+	// 加密，为 0 的项使用默认值
+	kdf := crypto.DefaultKDFParams
+	if c.Encryption.KDF.Time != 0 {
+		kdf.Time = c.Encryption.KDF.Time
+	}
+	if c.Encryption.KDF.MemoryKiB != 0 {
+		kdf.MemoryKiB = c.Encryption.KDF.MemoryKiB
+	}
+	if c.Encryption.KDF.Threads != 0 {
+		kdf.Threads = c.Encryption.KDF.Threads
+	}
+	if err := kdf.Validate(); err != nil {
+		p.Add("client.encryption.kdf", "%v", err)
+	}
+	if c.Encryption.RootID != "" && !e2e.ValidRootID(c.Encryption.RootID) {
+		p.Add("client.encryption.root_id", "只能包含字母、数字、点、下划线和连字符，最长 64 个字符")
+	}
+	return p
+}
+
+// expandPath 展开单个路径中的 ~，失败时记录问题并返回 false
+func expandPath(p *utils.ConfigProblems, key string, path *string) bool {
+	expanded, err := utils.ExpandPath(*path)
+	if err != nil {
+		p.Add(key, "%v", err)
+		return false
+	}
+	*path = expanded
+	return true
+}
+
+// expandPaths 展开日志输出路径中的 ~，stdout、stderr 保持不变
+func expandPaths(p *utils.ConfigProblems, key string, paths []string) {
+	for i := range paths {
+		expandPath(p, key, &paths[i])
+	}
+}
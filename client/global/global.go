@@ -2,11 +2,30 @@ package global
 
 import (
 	"fsync/client/models"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 )
 
+// MetaDirName 同步目录下存放客户端内部数据（回收站等）的隐藏目录，不参与同步
+const MetaDirName = ".fsync"
+
+// Version 客户端版本，登录时上报给服务端。发布构建时用 -ldflags "-X fsync/client/global.Version=v1.2.3" 设置
+var Version = "dev"
+
 var (
-	Configs *models.Config
-	Logger  *zap.Logger
+	Logger *zap.Logger
 )
+
+// configs 当前生效的配置。重新加载时整体替换，读取方通过 Config 拿到的快照不会被并发修改
+var configs atomic.Pointer[models.Config]
+
+// Config 返回当前生效的配置。一次处理中需要读取多个配置项时应先保存返回值，避免前后读到不同版本
+func Config() *models.Config {
+	return configs.Load()
+}
+
+// SetConfig 发布新配置，之后调用 Config 的读取方立即看到新值
+func SetConfig(cfg *models.Config) {
+	configs.Store(cfg)
+}
@@ -0,0 +1,330 @@
+// client/internal/api/client.go
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"fsync/client/global"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 被服务端限流（429）时按 Retry-After 等待后重放请求的次数和单次等待的上限，超出时把 429 返回给调用方
+const (
+	maxRateLimitRetries = 3
+	maxRetryAfter       = time.Minute
+)
+
+// Response 服务端统一响应结构
+type Response struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// StatusError 服务端返回的非 2xx 响应
+type StatusError struct {
+	Status int
+	Msg    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("服务端返回 %d: %s", e.Status, e.Msg)
+}
+
+// Client 访问 fsync 服务端的 HTTP 客户端，自动附带访问令牌并在过期时刷新
+type Client struct {
+	baseURL string
+	http    *http.Client
+
+	mutex    sync.Mutex
+	tokens   *TokenPair
+	throttle Throttle
+
+	// refreshMutex 串行化刷新。服务端的刷新令牌只能使用一次，重复使用会导致整个会话被吊销
+	refreshMutex sync.Mutex
+}
+
+// Throttle 限制文件内容的传输速率，JSON 请求不受影响
+type Throttle interface {
+	UploadReader(r io.Reader) io.Reader
+	DownloadReader(r io.Reader) io.Reader
+}
+
+// sizedBody 被限速包装的请求体，保留原始长度以便按 Content-Length 发送
+type sizedBody struct {
+	io.Reader
+	size int64
+}
+
+// throttledBody 被限速包装的响应体
+type throttledBody struct {
+	io.Reader
+	io.Closer
+}
+
+// NewClient 根据配置创建客户端。使用 https 时只接受首次连接时信任的证书，尚未信任时请求返回 ErrNotTrusted；
+// 已登记本设备时在 TLS 握手中出示设备证书
+func NewClient() (*Client, error) {
+	c := &Client{
+		baseURL: fmt.Sprintf("%s://%s", global.Config().Client.Protocol, global.Config().Client.ServerAddr),
+		http:    &http.Client{Timeout: 0}, // 大文件传输不设整体超时，由调用方控制
+	}
+	if global.Config().Client.Protocol == "https" {
+		pinned, err := PinnedCert()
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = pinnedTLSConfig(pinned)
+		// 已登记本设备时携带设备证书
+		deviceCert, err := loadDeviceKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		if deviceCert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*deviceCert}
+		}
+		c.http.Transport = transport
+	}
+	return c, nil
+}
+
+// NewAuthedClient 创建并加载本地令牌的客户端
+func NewAuthedClient() (*Client, error) {
+	tokens, err := LoadTokens()
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	c.tokens = tokens
+	return c, nil
+}
+
+// SetThrottle 设置文件内容传输的限速器，所有使用该客户端的传输共用
+func (c *Client) SetThrottle(t Throttle) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.throttle = t
+}
+
+// uploadBody 返回按上传限速发送 data 的请求体工厂
+func (c *Client) uploadBody(data []byte) func() io.Reader {
+	return func() io.Reader {
+		c.mutex.Lock()
+		t := c.throttle
+		c.mutex.Unlock()
+		if t == nil {
+			return bytes.NewReader(data)
+		}
+		return &sizedBody{Reader: t.UploadReader(bytes.NewReader(data)), size: int64(len(data))}
+	}
+}
+
+// throttleDownload 按下载限速包装响应体
+func (c *Client) throttleDownload(resp *http.Response) {
+	c.mutex.Lock()
+	t := c.throttle
+	c.mutex.Unlock()
+	if t != nil {
+		resp.Body = throttledBody{Reader: t.DownloadReader(resp.Body), Closer: resp.Body}
+	}
+}
+
+// Login 登录并保存令牌，同时上报本机的设备信息。账号开启了两步验证时不保存令牌，
+// 返回的 challenge 非空，需再以 LoginTOTP 提交验证码
+func (c *Client) Login(username, password string) (challenge string, err error) {
+	var resp loginResponse
+	body := map[string]interface{}{"username": username, "password": password, "device": localDeviceInfo()}
+	if err := c.doJSON(http.MethodPost, "/user/login", body, &resp, false); err != nil {
+		return "", err
+	}
+	if resp.TOTPRequired {
+		return resp.Challenge, nil
+	}
+	return "", c.useTokens(&resp.TokenPair)
+}
+
+// LoginTOTP 登录的第二步：提交两步验证码（或恢复码）换取令牌
+func (c *Client) LoginTOTP(challenge, code string) error {
+	var tokens TokenPair
+	body := map[string]interface{}{"challenge": challenge, "code": code, "device": localDeviceInfo()}
+	if err := c.doJSON(http.MethodPost, "/user/login/totp", body, &tokens, false); err != nil {
+		return err
+	}
+	return c.useTokens(&tokens)
+}
+
+// useTokens 使用并保存登录得到的令牌
+func (c *Client) useTokens(tokens *TokenPair) error {
+	c.mutex.Lock()
+	c.tokens = tokens
+	c.mutex.Unlock()
+	if err := saveDeviceID(tokens.DeviceID); err != nil {
+		return err
+	}
+	return SaveTokens(tokens)
+}
+
+// Register 注册用户，非首个用户需要管理员验证
+func (c *Client) Register(username, password, adminUsername, adminPassword string) error {
+	body := map[string]string{
+		"username":       username,
+		"password":       password,
+		"admin_username": adminUsername,
+		"admin_password": adminPassword,
+	}
+	return c.doJSON(http.MethodPost, "/user/register", body, nil, false)
+}
+
+// doJSON 发送 JSON 请求并将响应中的 data 解析到 out
+func (c *Client) doJSON(method, path string, in, out interface{}, authed bool) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	resp, err := c.Do(method, path, func() io.Reader { return bytes.NewReader(payload) }, map[string]string{
+		"Content-Type": "application/json",
+	}, authed)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+// Do 发送请求。body 以工厂函数提供，以便令牌刷新后重放请求。authed 为 true 时附带访问令牌
+func (c *Client) Do(method, path string, body func() io.Reader, headers map[string]string, authed bool) (*http.Response, error) {
+	resp, err := c.sendLimited(method, path, body, headers, authed)
+	if err != nil {
+		return nil, err
+	}
+	if !authed || resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	// 访问令牌失效时尝试刷新一次并重放请求
+	resp.Body.Close()
+	if err := c.refresh(resp.Request.Header.Get("Authorization")); err != nil {
+		return nil, err
+	}
+	return c.sendLimited(method, path, body, headers, authed)
+}
+
+// sendLimited 发送请求，被服务端限流时按 Retry-After 等待后重放
+func (c *Client) sendLimited(method, path string, body func() io.Reader, headers map[string]string, authed bool) (*http.Response, error) {
+	for i := 0; ; i++ {
+		resp, err := c.send(method, path, body, headers, authed)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || i == maxRateLimitRetries {
+			return resp, err
+		}
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		wait := time.Duration(seconds) * time.Second
+		if err != nil || wait > maxRetryAfter {
+			return resp, nil
+		}
+		resp.Body.Close()
+		time.Sleep(wait)
+	}
+}
+
+// send 发送单次请求
+func (c *Client) send(method, path string, body func() io.Reader, headers map[string]string, authed bool) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = body()
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if sized, ok := reader.(*sizedBody); ok {
+		req.ContentLength = sized.size
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if authed {
+		c.mutex.Lock()
+		if c.tokens == nil {
+			c.mutex.Unlock()
+			return nil, fmt.Errorf("尚未登录，请先执行 login")
+		}
+		req.Header.Set("Authorization", "Bearer "+c.tokens.AccessToken)
+		c.mutex.Unlock()
+	}
+	return c.http.Do(req)
+}
+
+// refresh 使用刷新令牌换取新的令牌对并保存。failedAuth 为被拒绝请求的 Authorization 头，
+// 其他请求或其他进程（如运行中的客户端与命令行）已经刷新过时直接使用新令牌，不再重复刷新
+func (c *Client) refresh(failedAuth string) error {
+	c.refreshMutex.Lock()
+	defer c.refreshMutex.Unlock()
+
+	c.mutex.Lock()
+	current := c.tokens
+	c.mutex.Unlock()
+	if current == nil {
+		return fmt.Errorf("尚未登录，请先执行 login")
+	}
+	if "Bearer "+current.AccessToken != failedAuth {
+		return nil
+	}
+	if saved, err := LoadTokens(); err == nil && saved.RefreshToken != current.RefreshToken {
+		c.mutex.Lock()
+		c.tokens = saved
+		c.mutex.Unlock()
+		return nil
+	}
+
+	var tokens TokenPair
+	body := map[string]string{"refresh_token": current.RefreshToken}
+	if err := c.doJSON(http.MethodPost, "/user/refresh", body, &tokens, false); err != nil {
+		return fmt.Errorf("刷新令牌失败，请重新登录: %w", err)
+	}
+	c.mutex.Lock()
+	c.tokens = &tokens
+	c.mutex.Unlock()
+	return SaveTokens(&tokens)
+}
+
+// Logout 在服务端登出，all 为 true 时登出所有设备上的会话
+func (c *Client) Logout(all bool) error {
+	path := "/user/logout"
+	if all {
+		path = "/user/logout/all"
+	}
+	return c.doJSON(http.MethodPost, path, nil, nil, true)
+}
+
+// decodeResponse 解析统一响应，非 2xx 时返回 StatusError
+func decodeResponse(resp *http.Response, out interface{}) error {
+	var r Response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		if resp.StatusCode >= 300 {
+			return &StatusError{Status: resp.StatusCode, Msg: resp.Status}
+		}
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return &StatusError{Status: resp.StatusCode, Msg: r.Msg}
+	}
+	if out != nil && len(r.Data) > 0 && string(r.Data) != "null" {
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			return fmt.Errorf("解析响应数据失败: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// client/internal/api/device.go
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"fsync/client/global"
+	"fsync/pkg/utils"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// TokenDir 下保存设备 ID、设备证书和私钥的文件名。设备 ID 在登出后保留，再次登录时仍是同一台设备
+const (
+	deviceIDFile   = "device_id"
+	deviceCertFile = "device.crt"
+	deviceKeyFile  = "device.key"
+)
+
+// Device 服务端记录的设备
+type Device struct {
+	ID            string     `json:"id"`
+	Username      string     `json:"username"`
+	Name          string     `json:"name"`
+	Platform      string     `json:"platform"`
+	ClientVersion string     `json:"client_version"`
+	LastSeenIP    string     `json:"last_seen_ip"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	CertNotAfter  *time.Time `json:"cert_not_after"` // 未登记设备证书时为空
+	RevokedAt     *time.Time `json:"revoked_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Current       bool       `json:"current"`
+}
+
+// DeviceInfo 登录时上报的本机信息
+type DeviceInfo struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Platform      string `json:"platform"`
+	ClientVersion string `json:"client_version"`
+}
+
+// enrollResponse 登记设备的响应
+type enrollResponse struct {
+	Device Device `json:"device"`
+	Cert   string `json:"cert"`
+	CACert string `json:"ca_cert"`
+}
+
+// localDeviceInfo 返回本机的设备信息，以主机名作为设备名
+func localDeviceInfo() DeviceInfo {
+	hostname, _ := os.Hostname()
+	return DeviceInfo{
+		ID:            LocalDeviceID(),
+		Name:          hostname,
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		ClientVersion: global.Version,
+	}
+}
+
+// LocalDeviceID 返回服务端分配给本机的设备 ID，尚未登录过时为空
+func LocalDeviceID() string {
+	data, err := os.ReadFile(filepath.Join(global.Config().Client.TokenDir, deviceIDFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// saveDeviceID 保存服务端分配给本机的设备 ID
+func saveDeviceID(id string) error {
+	if id == "" || id == LocalDeviceID() {
+		return nil
+	}
+	if err := os.MkdirAll(global.Config().Client.TokenDir, 0700); err != nil {
+		return fmt.Errorf("创建令牌目录失败: %w", err)
+	}
+	return os.WriteFile(filepath.Join(global.Config().Client.TokenDir, deviceIDFile), []byte(id+"\n"), 0600)
+}
+
+// devicePaths 返回设备证书和私钥文件路径
+func devicePaths() (certFile, keyFile string) {
+	dir := global.Config().Client.TokenDir
+	return filepath.Join(dir, deviceCertFile), filepath.Join(dir, deviceKeyFile)
+}
+
+// EnrollDevice 在本机生成私钥，提交证书签名请求为本设备申请证书，保存服务端签发的设备证书。
+// 私钥不离开本机，之后的 https 连接携带该证书（双向 TLS）。name 非空时同时重命名设备
+func (c *Client) EnrollDevice(name string) (*Device, error) {
+	key, err := utils.GenerateKey(utils.KeyECDSA)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificateRequest(nil, &x509.CertificateRequest{Subject: pkix.Name{CommonName: name}}, key)
+	if err != nil {
+		return nil, fmt.Errorf("生成证书签名请求失败: %w", err)
+	}
+
+	var resp enrollResponse
+	body := map[string]string{
+		"name": name,
+		"csr":  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})),
+	}
+	if err := c.doJSON(http.MethodPost, "/device/enroll", body, &resp, true); err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(resp.Cert))
+	if block == nil {
+		return nil, fmt.Errorf("服务端返回的设备证书无效")
+	}
+	if err := os.MkdirAll(global.Config().Client.TokenDir, 0700); err != nil {
+		return nil, fmt.Errorf("创建令牌目录失败: %w", err)
+	}
+	certFile, keyFile := devicePaths()
+	if err := utils.WriteCertFiles(certFile, keyFile, [][]byte{block.Bytes}, key); err != nil {
+		return nil, err
+	}
+	return &resp.Device, nil
+}
+
+// DeviceCert 读取本机的设备证书，尚未登记时返回 nil
+func DeviceCert() (*x509.Certificate, error) {
+	certFile, _ := devicePaths()
+	if _, err := os.Stat(certFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return utils.ReadCertFile(certFile)
+}
+
+// loadDeviceKeyPair 读取设备证书和私钥用于双向 TLS，尚未登记时返回 nil
+func loadDeviceKeyPair() (*tls.Certificate, error) {
+	certFile, keyFile := devicePaths()
+	if _, err := os.Stat(certFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载设备证书失败: %w", err)
+	}
+	return &pair, nil
+}
+
+// RemoveDeviceCert 删除本机的设备证书和私钥
+func RemoveDeviceCert() error {
+	certFile, keyFile := devicePaths()
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除设备证书失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListDevices 列出当前用户的设备
+func (c *Client) ListDevices() ([]Device, error) {
+	devices := make([]Device, 0)
+	if err := c.doJSON(http.MethodGet, "/device", nil, &devices, true); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// RenameDevice 重命名当前用户的设备
+func (c *Client) RenameDevice(id, name string) (*Device, error) {
+	var device Device
+	if err := c.doJSON(http.MethodPut, "/device/"+url.PathEscape(id), map[string]string{"name": name}, &device, true); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// LogoutDevice 远程登出当前用户的设备，服务端吊销它的令牌并断开连接
+func (c *Client) LogoutDevice(id string) (*Device, error) {
+	var device Device
+	if err := c.doJSON(http.MethodPost, "/device/"+url.PathEscape(id)+"/logout", nil, &device, true); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// ListAllDevices 列出所有用户的设备，需要管理员权限
+func (c *Client) ListAllDevices() ([]Device, error) {
+	devices := make([]Device, 0)
+	if err := c.doJSON(http.MethodGet, "/admin/devices", nil, &devices, true); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// RevokeDevice 吊销设备证书，服务端立即断开该设备的连接，需要管理员权限
+func (c *Client) RevokeDevice(id string) (*Device, error) {
+	var device Device
+	if err := c.doJSON(http.MethodPost, "/admin/devices/"+url.PathEscape(id)+"/revoke", nil, &device, true); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
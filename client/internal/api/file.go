@@ -0,0 +1,227 @@
+// client/internal/api/file.go
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ChunkHashHeader 上传分块时携带分块 SHA256 的请求头，与服务端一致
+const ChunkHashHeader = "X-Chunk-Hash"
+
+// FileHashHeader 下载响应中携带文件整体 SHA256 的响应头，与服务端一致
+const FileHashHeader = "X-File-Hash"
+
+// ContentMACHeader 下载响应中携带端到端加密文件明文 MAC 的响应头，与服务端一致
+const ContentMACHeader = "X-Content-MAC"
+
+// ContentCodecHeader 下载响应中携带文件压缩编码的响应头，与服务端一致
+const ContentCodecHeader = "X-Content-Codec"
+
+// FileMeta 服务端文件元数据
+type FileMeta struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Hash       string `json:"hash"`
+	ContentMAC string `json:"content_mac"`
+	Codec      string `json:"codec"`
+}
+
+// UploadMeta 上传时随文件提交、下载时用于还原内容的元数据
+type UploadMeta struct {
+	ContentMAC string `json:"content_mac,omitempty"` // 端到端加密时明文的带密钥 MAC
+	Codec      string `json:"codec,omitempty"`       // 上传前使用的压缩编码
+}
+
+// GetFileMeta 查询文件元数据
+func (c *Client) GetFileMeta(path string) (*FileMeta, error) {
+	var meta FileMeta
+	if err := c.doJSON(http.MethodGet, "/file/meta?path="+url.QueryEscape(path), nil, &meta, true); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// UploadSession 服务端上传会话信息
+type UploadSession struct {
+	SessionID   string `json:"session_id"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Hash        string `json:"hash"`
+	ChunkSize   int64  `json:"chunk_size"`
+	TotalChunks int    `json:"total_chunks"`
+	Received    []int  `json:"received"`
+	Completed   bool   `json:"completed"`
+}
+
+// CreateUpload 创建上传会话，服务端存在同内容的未完成会话时返回该会话
+func (c *Client) CreateUpload(path string, size int64, hash string, meta UploadMeta) (*UploadSession, error) {
+	var session UploadSession
+	body := map[string]interface{}{"path": path, "size": size, "hash": hash, "content_mac": meta.ContentMAC, "codec": meta.Codec}
+	if err := c.doJSON(http.MethodPost, "/file/uploads", body, &session, true); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetUpload 查询上传会话，得到服务端已确认的分块
+func (c *Client) GetUpload(sessionID string) (*UploadSession, error) {
+	var session UploadSession
+	if err := c.doJSON(http.MethodGet, "/file/uploads/"+url.PathEscape(sessionID), nil, &session, true); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// PutChunk 上传一个分块
+func (c *Client) PutChunk(sessionID string, index int, hash string, data []byte) error {
+	path := fmt.Sprintf("/file/uploads/%s/chunks/%d", url.PathEscape(sessionID), index)
+	resp, err := c.Do(http.MethodPut, path, c.uploadBody(data), map[string]string{
+		"Content-Type":  "application/octet-stream",
+		ChunkHashHeader: hash,
+	}, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, nil)
+}
+
+// CompleteUpload 通知服务端所有分块已上传
+func (c *Client) CompleteUpload(sessionID string) error {
+	return c.doJSON(http.MethodPost, "/file/uploads/"+url.PathEscape(sessionID)+"/complete", nil, nil, true)
+}
+
+// Download 从 offset 开始下载文件，offset > 0 时使用 HTTP Range 续传。
+// ifRange 为上次下载得到的文件哈希，文件已变化时服务端返回完整内容（200）
+func (c *Client) Download(path string, offset int64, ifRange string) (*http.Response, error) {
+	headers := map[string]string{}
+	if offset > 0 {
+		headers["Range"] = fmt.Sprintf("bytes=%d-", offset)
+		if ifRange != "" {
+			headers["If-Range"] = `"` + ifRange + `"`
+		}
+	}
+	resp, err := c.Do(http.MethodGet, "/file/download?path="+url.QueryEscape(path), nil, headers, true)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		return nil, decodeResponse(resp, nil)
+	}
+	c.throttleDownload(resp)
+	return resp, nil
+}
+
+// ChunkRef 分块清单中的一项，Codec 为该分块的压缩编码，为空时分块未压缩
+type ChunkRef struct {
+	Hash  string `json:"hash"`
+	Size  int64  `json:"size"`
+	Codec string `json:"codec,omitempty"`
+}
+
+// GetManifest 查询文件的分块清单，整块上传的文件返回空列表
+func (c *Client) GetManifest(path string) ([]ChunkRef, error) {
+	var result struct {
+		Chunks []ChunkRef `json:"chunks"`
+	}
+	if err := c.doJSON(http.MethodGet, "/file/manifest?path="+url.QueryEscape(path), nil, &result, true); err != nil {
+		return nil, err
+	}
+	return result.Chunks, nil
+}
+
+// MissingChunks 查询服务端缺失的内容分块
+func (c *Client) MissingChunks(hashes []string) ([]string, error) {
+	var result struct {
+		Missing []string `json:"missing"`
+	}
+	body := map[string]interface{}{"hashes": hashes}
+	if err := c.doJSON(http.MethodPost, "/file/chunks/missing", body, &result, true); err != nil {
+		return nil, err
+	}
+	return result.Missing, nil
+}
+
+// PutContentChunk 上传一个内容分块
+func (c *Client) PutContentChunk(hash string, data []byte) error {
+	resp, err := c.Do(http.MethodPut, "/file/chunks/"+url.PathEscape(hash), c.uploadBody(data),
+		map[string]string{"Content-Type": "application/octet-stream"}, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, nil)
+}
+
+// CommitFile 以分块清单提交文件新版本
+func (c *Client) CommitFile(path string, size int64, hash string, chunks []ChunkRef, meta UploadMeta) error {
+	body := map[string]interface{}{"path": path, "size": size, "hash": hash, "chunks": chunks,
+		"content_mac": meta.ContentMAC, "codec": meta.Codec}
+	return c.doJSON(http.MethodPost, "/file/commit", body, nil, true)
+}
+
+// RemoveFile 删除服务端文件，文件不存在时返回 404 的 StatusError
+func (c *Client) RemoveFile(path string) error {
+	return c.doJSON(http.MethodDelete, "/file?path="+url.QueryEscape(path), nil, nil, true)
+}
+
+// FileChange 其他设备发起的文件变更
+type FileChange struct {
+	ID      uint64 `json:"id"`
+	Path    string `json:"path"`
+	Deleted bool   `json:"deleted"`
+}
+
+// FileChanges 一次拉取的变更，Cursor 为下次拉取的起点
+type FileChanges struct {
+	Changes []FileChange `json:"changes"`
+	Cursor  uint64       `json:"cursor"`
+	More    bool         `json:"more"`
+}
+
+// Changes 拉取 since 之后其他设备的文件变更
+func (c *Client) Changes(since uint64) (*FileChanges, error) {
+	var changes FileChanges
+	if err := c.doJSON(http.MethodGet, fmt.Sprintf("/file/changes?since=%d", since), nil, &changes, true); err != nil {
+		return nil, err
+	}
+	return &changes, nil
+}
+
+// LatestChange 返回服务端当前的变更游标，首次拉取时从这里开始
+func (c *Client) LatestChange() (uint64, error) {
+	var changes FileChanges
+	if err := c.doJSON(http.MethodGet, "/file/changes", nil, &changes, true); err != nil {
+		return 0, err
+	}
+	return changes.Cursor, nil
+}
+
+// KeyFile 服务端保存的端到端加密密钥文件
+type KeyFile struct {
+	Data    string `json:"data"`
+	Version int    `json:"version"`
+}
+
+// GetKeyFile 读取加密根目录的密钥文件，不存在时返回 404 的 StatusError
+func (c *Client) GetKeyFile(rootID string) (*KeyFile, error) {
+	var kf KeyFile
+	if err := c.doJSON(http.MethodGet, "/file/keyfile?root="+url.QueryEscape(rootID), nil, &kf, true); err != nil {
+		return nil, err
+	}
+	return &kf, nil
+}
+
+// PutKeyFile 保存加密根目录的密钥文件，version 为读取时的版本，首次创建为 0。
+// 期间被其他设备修改时返回 409 的 StatusError
+func (c *Client) PutKeyFile(rootID, data string, version int) (*KeyFile, error) {
+	var kf KeyFile
+	body := map[string]interface{}{"data": data, "version": version}
+	if err := c.doJSON(http.MethodPut, "/file/keyfile?root="+url.QueryEscape(rootID), body, &kf, true); err != nil {
+		return nil, err
+	}
+	return &kf, nil
+}
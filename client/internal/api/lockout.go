@@ -0,0 +1,67 @@
+// client/internal/api/lockout.go
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Lockout 因连续登录失败正在锁定中的用户名或 IP
+type Lockout struct {
+	Username    string    `json:"username"`
+	IP          string    `json:"ip"`
+	LockedUntil time.Time `json:"locked_until"`
+	Lockouts    int       `json:"lockouts"`
+}
+
+// AuditEntry 服务端的审计日志记录
+type AuditEntry struct {
+	ID        uint      `json:"id"`
+	Event     string    `json:"event"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	Actor     string    `json:"actor"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListLockouts 列出正在锁定中的用户名和 IP，需要管理员权限
+func (c *Client) ListLockouts() ([]Lockout, error) {
+	lockouts := make([]Lockout, 0)
+	if err := c.doJSON(http.MethodGet, "/admin/lockouts", nil, &lockouts, true); err != nil {
+		return nil, err
+	}
+	return lockouts, nil
+}
+
+// UnlockUser 解除用户名的锁定，需要管理员权限
+func (c *Client) UnlockUser(username string) error {
+	return c.doJSON(http.MethodPost, "/admin/users/"+url.PathEscape(username)+"/unlock", nil, nil, true)
+}
+
+// UnlockIP 解除 IP 的锁定，需要管理员权限
+func (c *Client) UnlockIP(ip string) error {
+	return c.doJSON(http.MethodPost, "/admin/ips/"+url.PathEscape(ip)+"/unlock", nil, nil, true)
+}
+
+// AuditLog 按时间倒序查询审计日志，username 为空时查询所有用户，需要管理员权限
+func (c *Client) AuditLog(username string, limit int) ([]AuditEntry, error) {
+	query := url.Values{}
+	if username != "" {
+		query.Set("username", username)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/admin/audit"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	entries := make([]AuditEntry, 0)
+	if err := c.doJSON(http.MethodGet, path, nil, &entries, true); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
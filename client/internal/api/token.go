@@ -0,0 +1,61 @@
+// client/internal/api/token.go
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"fsync/client/global"
+	"os"
+	"path/filepath"
+)
+
+// tokenFile TokenDir 下保存令牌的文件名
+const tokenFile = "token.json"
+
+// TokenPair 访问令牌与刷新令牌，DeviceID 为令牌所属的设备
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	DeviceID     string `json:"device_id,omitempty"`
+}
+
+// tokenPath 返回令牌文件路径
+func tokenPath() string {
+	return filepath.Join(global.Config().Client.TokenDir, tokenFile)
+}
+
+// LoadTokens 读取本地保存的令牌
+func LoadTokens() (*TokenPair, error) {
+	data, err := os.ReadFile(tokenPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("尚未登录，请先执行 login")
+		}
+		return nil, fmt.Errorf("读取令牌失败: %w", err)
+	}
+	var tokens TokenPair
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("解析令牌失败: %w", err)
+	}
+	return &tokens, nil
+}
+
+// SaveTokens 保存令牌，文件仅当前用户可读写
+func SaveTokens(tokens *TokenPair) error {
+	if err := os.MkdirAll(global.Config().Client.TokenDir, 0700); err != nil {
+		return fmt.Errorf("创建令牌目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tokenPath(), data, 0600)
+}
+
+// RemoveTokens 删除本地令牌
+func RemoveTokens() error {
+	if err := os.Remove(tokenPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除令牌失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// client/internal/api/totp.go
+package api
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// loginResponse 登录的响应，开启了两步验证时只有 TOTPRequired 和 Challenge
+type loginResponse struct {
+	TokenPair
+	TOTPRequired bool   `json:"totp_required"`
+	Challenge    string `json:"challenge"`
+}
+
+// TOTPSetup 开启两步验证时生成的密钥
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TOTPStatus 两步验证状态
+type TOTPStatus struct {
+	Enabled       bool `json:"enabled"`
+	RecoveryCodes int  `json:"recovery_codes"`
+}
+
+// recoveryCodesResponse 新生成的恢复码
+type recoveryCodesResponse struct {
+	Codes []string `json:"codes"`
+}
+
+// TOTPStatus 查看当前用户的两步验证状态
+func (c *Client) TOTPStatus() (*TOTPStatus, error) {
+	var status TOTPStatus
+	if err := c.doJSON(http.MethodGet, "/user/totp", nil, &status, true); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SetupTOTP 生成两步验证密钥，提交第一个验证码后才生效
+func (c *Client) SetupTOTP() (*TOTPSetup, error) {
+	var setup TOTPSetup
+	if err := c.doJSON(http.MethodPost, "/user/totp/setup", nil, &setup, true); err != nil {
+		return nil, err
+	}
+	return &setup, nil
+}
+
+// EnableTOTP 提交第一个验证码开启两步验证，返回恢复码
+func (c *Client) EnableTOTP(code string) ([]string, error) {
+	var resp recoveryCodesResponse
+	if err := c.doJSON(http.MethodPost, "/user/totp/enable", map[string]string{"code": code}, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Codes, nil
+}
+
+// DisableTOTP 用验证码（或恢复码）关闭两步验证
+func (c *Client) DisableTOTP(code string) error {
+	return c.doJSON(http.MethodPost, "/user/totp/disable", map[string]string{"code": code}, nil, true)
+}
+
+// RegenerateRecoveryCodes 用验证码生成新的恢复码，旧的恢复码失效
+func (c *Client) RegenerateRecoveryCodes(code string) ([]string, error) {
+	var resp recoveryCodesResponse
+	if err := c.doJSON(http.MethodPost, "/user/totp/recovery-codes", map[string]string{"code": code}, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Codes, nil
+}
+
+// ResetTOTP 关闭其他用户的两步验证，需要管理员权限
+func (c *Client) ResetTOTP(username string) error {
+	return c.doJSON(http.MethodPost, "/admin/users/"+url.PathEscape(username)+"/totp/reset", nil, nil, true)
+}
@@ -0,0 +1,226 @@
+// client/internal/api/trust.go
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"fsync/client/global"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// trustFile TokenDir 下保存已信任服务器证书的文件名
+const trustFile = "known_servers.json"
+
+// ErrNotTrusted 尚未确认过服务器证书
+var ErrNotTrusted = errors.New("尚未信任该服务器的证书，请先执行 login 确认证书指纹")
+
+// TrustedServer 首次连接时确认并固定的服务器证书。服务器使用自己的自签名 CA 时固定 CA 证书，
+// 更换叶子证书不需要重新确认；其他情况固定叶子证书
+type TrustedServer struct {
+	Cert      string    `json:"cert"` // PEM 格式
+	TrustedAt time.Time `json:"trusted_at"`
+}
+
+// CertMismatchError 服务器出示的证书与固定的证书不一致
+type CertMismatchError struct {
+	Addr     string
+	Pinned   string
+	Received string
+}
+
+func (e *CertMismatchError) Error() string {
+	return fmt.Sprintf("!!! 警告：服务器 %s 的证书与首次连接时信任的证书不一致，可能正在遭受中间人攻击，已拒绝连接\n"+
+		"  已信任的证书指纹: %s\n  本次收到的证书指纹: %s\n"+
+		"如果确认服务器更换了证书，请向管理员核实新指纹后执行 `trust reset` 并重新 login", e.Addr, e.Pinned, e.Received)
+}
+
+// Fingerprint 返回证书的 SHA-256 指纹，形如 AB:CD:...
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hexed := strings.ToUpper(hex.EncodeToString(sum[:]))
+	parts := make([]string, 0, len(sum))
+	for i := 0; i < len(hexed); i += 2 {
+		parts = append(parts, hexed[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// trustPath 返回已信任证书文件的路径
+func trustPath() string {
+	return filepath.Join(global.Config().Client.TokenDir, trustFile)
+}
+
+// loadTrusted 读取所有已信任的服务器，键为 server_addr
+func loadTrusted() (map[string]TrustedServer, error) {
+	servers := make(map[string]TrustedServer)
+	data, err := os.ReadFile(trustPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return servers, nil
+		}
+		return nil, fmt.Errorf("读取已信任的证书失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("解析已信任的证书失败: %w", err)
+	}
+	return servers, nil
+}
+
+// saveTrusted 保存已信任的服务器，文件仅当前用户可读写
+func saveTrusted(servers map[string]TrustedServer) error {
+	if err := os.MkdirAll(global.Config().Client.TokenDir, 0700); err != nil {
+		return fmt.Errorf("创建令牌目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(servers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(trustPath(), data, 0600)
+}
+
+// PinnedCert 返回当前服务器已信任的证书，尚未信任时返回 nil
+func PinnedCert() (*x509.Certificate, error) {
+	servers, err := loadTrusted()
+	if err != nil {
+		return nil, err
+	}
+	server, ok := servers[global.Config().Client.ServerAddr]
+	if !ok {
+		return nil, nil
+	}
+	block, _ := pem.Decode([]byte(server.Cert))
+	if block == nil {
+		return nil, fmt.Errorf("已信任的证书格式错误，请执行 `trust reset` 后重新 login")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// PinCert 信任当前服务器的证书
+func PinCert(cert *x509.Certificate) error {
+	servers, err := loadTrusted()
+	if err != nil {
+		return err
+	}
+	servers[global.Config().Client.ServerAddr] = TrustedServer{
+		Cert:      string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		TrustedAt: time.Now(),
+	}
+	return saveTrusted(servers)
+}
+
+// ResetTrust 删除当前服务器已信任的证书，下次 login 时重新确认
+func ResetTrust() (bool, error) {
+	servers, err := loadTrusted()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := servers[global.Config().Client.ServerAddr]; !ok {
+		return false, nil
+	}
+	delete(servers, global.Config().Client.ServerAddr)
+	return true, saveTrusted(servers)
+}
+
+// FetchServerCert 连接服务器并返回需要固定的证书，不做任何校验，仅用于首次连接时让用户确认
+func FetchServerCert() (*x509.Certificate, error) {
+	addr := global.Config().Client.ServerAddr
+	host, _, _ := net.SplitHostPort(addr)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true, // 证书由用户确认指纹
+	})
+	if err != nil {
+		return nil, fmt.Errorf("连接服务器失败: %w", err)
+	}
+	defer conn.Close()
+	chain := conn.ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("服务器没有出示证书")
+	}
+	return pinTarget(chain), nil
+}
+
+// pinTarget 选择证书链中需要固定的证书：链以服务器自己的自签名 CA 结尾时固定 CA，
+// 否则固定叶子证书。固定公共 CA 或中间证书意味着信任它为任意域名签发的证书
+func pinTarget(chain []*x509.Certificate) *x509.Certificate {
+	if root := chain[len(chain)-1]; len(chain) > 1 && ownRoot(root) {
+		return root
+	}
+	return chain[0]
+}
+
+// ownRoot 判断证书是否为自签名且不受系统信任的根证书，即服务器自己生成的本地 CA
+func ownRoot(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+		return false
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return false
+	}
+	_, err := cert.Verify(x509.VerifyOptions{})
+	return err != nil
+}
+
+// pinnedTLSConfig 返回只接受已信任证书或由它签发的服务器证书的 TLS 配置。
+// 自签名证书通常不包含客户端访问时使用的地址，固定的是服务器自己的 CA 时不校验主机名
+func pinnedTLSConfig(pinned *x509.Certificate) *tls.Config {
+	addr := global.Config().Client.ServerAddr
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true, // 由 VerifyConnection 按固定的证书校验
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if pinned == nil {
+				return ErrNotTrusted
+			}
+			return verifyPinned(addr, pinned, cs.PeerCertificates)
+		},
+	}
+}
+
+// verifyPinned 校验服务器证书是固定的证书或由它签发。固定的不是服务器自己的自签名 CA 时
+// （叶子证书，或早期版本固定的公共 CA、中间证书），还要求证书包含访问服务器时使用的主机名
+func verifyPinned(addr string, pinned *x509.Certificate, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("服务器没有出示证书")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(pinned)
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates}
+	if !ownRoot(pinned) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		opts.DNSName = host
+	}
+	_, err := chain[0].Verify(opts)
+	if err == nil {
+		return nil
+	}
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &invalid) && invalid.Reason == x509.Expired {
+		return fmt.Errorf("服务器 %s 的证书已过期或尚未生效（%s 至 %s），请联系管理员更换证书",
+			addr, invalid.Cert.NotBefore.Format(time.DateOnly), invalid.Cert.NotAfter.Format(time.DateOnly))
+	}
+	var hostErr x509.HostnameError
+	if errors.As(err, &hostErr) {
+		return fmt.Errorf("服务器 %s 的证书不包含该地址，拒绝连接: %w", addr, err)
+	}
+	return &CertMismatchError{Addr: addr, Pinned: Fingerprint(pinned), Received: Fingerprint(chain[len(chain)-1])}
+}
@@ -0,0 +1,288 @@
+// client/internal/bandwidth/controller.go
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"fsync/client/models"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Rule 时间段限速规则，End 早于 Start 时表示跨越午夜，End 等于 Start 时表示全天
+type Rule struct {
+	Start    time.Duration // 距当天零点的时长
+	End      time.Duration
+	Upload   int64
+	Download int64
+}
+
+// Override 运行时通过控制接口设置的覆盖项，为 nil 的项沿用配置和时间表
+type Override struct {
+	Upload   *int64 `json:"upload,omitempty"`
+	Download *int64 `json:"download,omitempty"`
+	Metered  *bool  `json:"metered,omitempty"`
+}
+
+// Request 通过控制接口提交的限速调整
+type Request struct {
+	Override
+	Reset bool `json:"reset,omitempty"` // 先清除之前的所有覆盖项，恢复配置和时间表
+}
+
+// Status 当前生效的限速状态
+type Status struct {
+	Upload       int64    `json:"upload"`   // 上传每秒字节数，0 为不限速
+	Download     int64    `json:"download"` // 下载每秒字节数，0 为不限速
+	Metered      bool     `json:"metered"`
+	MeteredLimit int64    `json:"metered_limit"` // 计量模式下暂缓的文件大小阈值
+	Rule         string   `json:"rule,omitempty"`
+	Override     Override `json:"override"`
+}
+
+// Controller 管理共享的上传、下载令牌桶，按时间表和运行时覆盖项调整速率，
+// 并在计量模式下暂缓大文件传输
+type Controller struct {
+	logger *zap.Logger
+
+	up     *Limiter
+	down   *Limiter
+	cancel context.CancelFunc
+
+	mutex        sync.Mutex
+	upload       int64
+	download     int64
+	rules        []Rule
+	metered      bool
+	meteredLimit int64
+	override     Override
+	activeRule   string
+	wasMetered   bool
+	onResume     []func()
+}
+
+// NewController 按配置创建限速控制器
+func NewController(cfg models.BandwidthConfig, logger *zap.Logger) (*Controller, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Controller{
+		logger: logger,
+		up:     newLimiter(ctx),
+		down:   newLimiter(ctx),
+		cancel: cancel,
+	}
+	if err := c.Reconfigure(cfg); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reconfigure 应用新的限速配置，运行时覆盖项保持不变。配置无效时返回错误且不做任何修改
+func (c *Controller) Reconfigure(cfg models.BandwidthConfig) error {
+	upload, err := ParseSize(cfg.Upload)
+	if err != nil {
+		return fmt.Errorf("bandwidth.upload: %w", err)
+	}
+	download, err := ParseSize(cfg.Download)
+	if err != nil {
+		return fmt.Errorf("bandwidth.download: %w", err)
+	}
+	meteredLimit, err := ParseSize(cfg.Metered.MaxSize)
+	if err != nil {
+		return fmt.Errorf("bandwidth.metered.max_size: %w", err)
+	}
+	rules := make([]Rule, 0, len(cfg.Schedule))
+	for i, r := range cfg.Schedule {
+		rule, err := parseRule(r)
+		if err != nil {
+			return fmt.Errorf("bandwidth.schedule[%d]: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	c.mutex.Lock()
+	c.upload = upload
+	c.download = download
+	c.rules = rules
+	c.metered = cfg.Metered.Enabled
+	c.meteredLimit = meteredLimit
+	c.mutex.Unlock()
+	c.apply(time.Now())
+	return nil
+}
+
+// parseRule 解析一条时间表规则
+func parseRule(r models.BandwidthRule) (Rule, error) {
+	start, err := parseClock(r.Start)
+	if err != nil {
+		return Rule{}, err
+	}
+	end, err := parseClock(r.End)
+	if err != nil {
+		return Rule{}, err
+	}
+	upload, err := ParseSize(r.Upload)
+	if err != nil {
+		return Rule{}, err
+	}
+	download, err := ParseSize(r.Download)
+	if err != nil {
+		return Rule{}, err
+	}
+	return Rule{Start: start, End: end, Upload: upload, Download: download}, nil
+}
+
+// parseClock 解析 HH:MM 格式的时刻
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时刻 %q，应为 HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains 判断某时刻是否落在规则的时间段内
+func (r Rule) contains(clock time.Duration) bool {
+	if r.Start == r.End {
+		return true
+	}
+	if r.Start < r.End {
+		return clock >= r.Start && clock < r.End
+	}
+	return clock >= r.Start || clock < r.End
+}
+
+// UploadReader 返回按上传速率限速的读取器
+func (c *Controller) UploadReader(r io.Reader) io.Reader {
+	return c.up.Reader(r)
+}
+
+// DownloadReader 返回按下载速率限速的读取器
+func (c *Controller) DownloadReader(r io.Reader) io.Reader {
+	return c.down.Reader(r)
+}
+
+// Stop 中止所有正在等待令牌的读取，停止同步时调用，避免低速率下的传输拖住退出
+func (c *Controller) Stop() {
+	c.cancel()
+}
+
+// Deferred 判断计量模式下是否应暂缓该大小的传输
+func (c *Controller) Deferred(size int64) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.meteredLocked() && c.meteredLimit > 0 && size > c.meteredLimit
+}
+
+// OnResume 注册计量模式关闭时的回调，用于继续被暂缓的传输
+func (c *Controller) OnResume(f func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onResume = append(c.onResume, f)
+}
+
+// Update 合并运行时覆盖项，Reset 为 true 时先清除已有的覆盖项，返回调整后的状态
+func (c *Controller) Update(req Request) Status {
+	c.mutex.Lock()
+	if req.Reset {
+		c.override = Override{}
+	}
+	if req.Upload != nil {
+		c.override.Upload = req.Upload
+	}
+	if req.Download != nil {
+		c.override.Download = req.Download
+	}
+	if req.Metered != nil {
+		c.override.Metered = req.Metered
+	}
+	c.mutex.Unlock()
+	c.apply(time.Now())
+
+	status := c.Status()
+	c.logger.Info("限速已调整", zap.String("upload", FormatRate(status.Upload)),
+		zap.String("download", FormatRate(status.Download)), zap.Bool("metered", status.Metered))
+	return status
+}
+
+// Run 周期性按时间表切换速率，直到 quit 关闭
+func (c *Controller) Run(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			c.apply(now)
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Status 返回当前生效的限速状态
+func (c *Controller) Status() Status {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return Status{
+		Upload:       c.up.Rate(),
+		Download:     c.down.Rate(),
+		Metered:      c.meteredLocked(),
+		MeteredLimit: c.meteredLimit,
+		Rule:         c.activeRule,
+		Override:     c.override,
+	}
+}
+
+// apply 按时刻、时间表和覆盖项计算生效的速率，计量模式关闭时触发恢复回调
+func (c *Controller) apply(now time.Time) {
+	c.mutex.Lock()
+	upload, download := c.upload, c.download
+	activeRule := ""
+	clock := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	for _, r := range c.rules {
+		if r.contains(clock) {
+			upload, download = r.Upload, r.Download
+			activeRule = fmt.Sprintf("%02d:%02d-%02d:%02d", int(r.Start.Hours()), int(r.Start.Minutes())%60,
+				int(r.End.Hours()), int(r.End.Minutes())%60)
+			break
+		}
+	}
+	if c.override.Upload != nil {
+		upload = *c.override.Upload
+	}
+	if c.override.Download != nil {
+		download = *c.override.Download
+	}
+	if activeRule != c.activeRule {
+		c.logger.Info("切换限速时间段", zap.String("rule", activeRule),
+			zap.String("upload", FormatRate(upload)), zap.String("download", FormatRate(download)))
+	}
+	c.activeRule = activeRule
+
+	metered := c.meteredLocked()
+	var resume []func()
+	if c.wasMetered && !metered {
+		resume = append(resume, c.onResume...)
+	}
+	c.wasMetered = metered
+	c.mutex.Unlock()
+
+	c.up.SetRate(upload)
+	c.down.SetRate(download)
+	if len(resume) > 0 {
+		c.logger.Info("计量模式已关闭，继续暂缓的传输")
+		for _, f := range resume {
+			go f()
+		}
+	}
+}
+
+// meteredLocked 返回计量模式是否生效，调用方需持有锁
+func (c *Controller) meteredLocked() bool {
+	if c.override.Metered != nil {
+		return *c.override.Metered
+	}
+	return c.metered
+}
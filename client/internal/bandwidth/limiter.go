@@ -0,0 +1,139 @@
+// client/internal/bandwidth/limiter.go
+package bandwidth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Unlimited 表示不限速
+	Unlimited int64 = 0
+
+	// readChunk 每次读取的最大字节数，保证限速平滑，不会先等待很久再一次性发送整个分块
+	readChunk = 32 * 1024
+
+	// burst 令牌桶容量
+	burst = 64 * 1024
+)
+
+// ErrStopped 限速器停止后，正在等待令牌的读取返回该错误
+var ErrStopped = errors.New("限速已停止，传输中止")
+
+// Limiter 令牌桶限速器，所有传输协程共用同一个实例，速率可在运行时调整
+type Limiter struct {
+	mutex sync.Mutex
+	bps   int64
+	lim   *rate.Limiter
+	ctx   context.Context // 取消后不再等待令牌，低速率下的传输也能及时退出
+}
+
+// newLimiter 创建不限速的限速器，ctx 取消后读取立即返回 ErrStopped
+func newLimiter(ctx context.Context) *Limiter {
+	return &Limiter{lim: rate.NewLimiter(rate.Inf, burst), ctx: ctx}
+}
+
+// SetRate 设置每秒字节数，Unlimited 表示不限速
+func (l *Limiter) SetRate(bps int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if bps == l.bps {
+		return
+	}
+	l.bps = bps
+	if bps <= Unlimited {
+		l.lim.SetLimit(rate.Inf)
+		return
+	}
+	l.lim.SetLimit(rate.Limit(bps))
+}
+
+// Rate 返回当前的每秒字节数
+func (l *Limiter) Rate() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.bps
+}
+
+// Reader 返回按令牌桶限速的读取器
+func (l *Limiter) Reader(r io.Reader) io.Reader {
+	return &limitedReader{r: r, l: l}
+}
+
+// limitedReader 每读出一段数据就从令牌桶取走相同数量的令牌
+type limitedReader struct {
+	r io.Reader
+	l *Limiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > readChunk {
+		p = p[:readChunk]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if waitErr := lr.l.lim.WaitN(lr.l.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+			if lr.l.ctx.Err() != nil {
+				err = ErrStopped
+			}
+		}
+	}
+	return n, err
+}
+
+// ParseSize 解析带单位的字节数，如 512KB、1.5MB、2G，单位按 1024 进制；空字符串和 0 返回 0
+func ParseSize(text string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(text))
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "/S"), "IB")
+	s = strings.TrimSuffix(s, "B")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("无效的大小: %q（示例: 512KB、1.5MB）", text)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// FormatRate 将每秒字节数格式化为便于阅读的文本
+func FormatRate(bps int64) string {
+	if bps <= Unlimited {
+		return "不限速"
+	}
+	return FormatSize(bps) + "/s"
+}
+
+// FormatSize 将字节数格式化为便于阅读的文本
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return strconv.FormatFloat(float64(n)/(1<<30), 'f', 1, 64) + "GB"
+	case n >= 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + "MB"
+	case n >= 1<<10:
+		return strconv.FormatFloat(float64(n)/(1<<10), 'f', 1, 64) + "KB"
+	default:
+		return strconv.FormatInt(n, 10) + "B"
+	}
+}
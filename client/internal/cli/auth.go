@@ -0,0 +1,139 @@
+// client/internal/cli/auth.go
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"fsync/client/internal/api"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// stdin 共享的标准输入读取器
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt 读取一行输入
+func prompt(label string) (string, error) {
+	fmt.Print(label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptPassword 读取密码，终端下不回显
+func promptPassword(label string) (string, error) {
+	fmt.Print(label)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		return string(data), err
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// runLogin 交互式登录并保存令牌，首次连接时先确认服务器证书
+func runLogin() error {
+	if err := confirmServerCert(); err != nil {
+		return err
+	}
+	username, err := prompt("用户名: ")
+	if err != nil {
+		return err
+	}
+	password, err := promptPassword("密码: ")
+	if err != nil {
+		return err
+	}
+	client, err := api.NewClient()
+	if err != nil {
+		return err
+	}
+	challenge, err := client.Login(username, password)
+	if err != nil {
+		return fmt.Errorf("登录失败: %w", err)
+	}
+	if challenge != "" {
+		code, err := prompt("两步验证码（或恢复码）: ")
+		if err != nil {
+			return err
+		}
+		if err := client.LoginTOTP(challenge, code); err != nil {
+			return fmt.Errorf("登录失败: %w", err)
+		}
+	}
+	fmt.Println("登录成功，启动客户端后开始同步")
+	return nil
+}
+
+// runRegister 交互式注册，系统已有用户时需要管理员验证
+func runRegister() error {
+	if err := confirmServerCert(); err != nil {
+		return err
+	}
+	username, err := prompt("用户名: ")
+	if err != nil {
+		return err
+	}
+	password, err := promptPassword("密码: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := promptPassword("确认密码: ")
+	if err != nil {
+		return err
+	}
+	if password != confirm {
+		return fmt.Errorf("两次输入的密码不一致")
+	}
+	fmt.Println("请输入管理员用户名和密码进行验证（系统中尚无用户时直接回车）")
+	adminUsername, err := prompt("管理员用户名: ")
+	if err != nil {
+		return err
+	}
+	adminPassword := ""
+	if adminUsername != "" {
+		if adminPassword, err = promptPassword("管理员密码: "); err != nil {
+			return err
+		}
+	}
+	client, err := api.NewClient()
+	if err != nil {
+		return err
+	}
+	if err := client.Register(username, password, adminUsername, adminPassword); err != nil {
+		return fmt.Errorf("注册失败: %w", err)
+	}
+	fmt.Println("注册成功，请使用 login 登录")
+	return nil
+}
+
+// runLogout 在服务端吊销当前会话（all 时吊销所有设备上的会话）并删除本地令牌。
+// 服务端不可达时仍删除本地令牌，但令牌在过期前仍然有效
+func runLogout(args []string) error {
+	all := len(args) > 0 && strings.TrimPrefix(args[0], "--") == "all"
+	client, err := api.NewAuthedClient()
+	if err != nil {
+		return err
+	}
+	serverErr := client.Logout(all)
+	if err := api.RemoveTokens(); err != nil {
+		return err
+	}
+	switch {
+	case serverErr != nil:
+		fmt.Printf("已删除本地令牌，但服务端登出失败，令牌在过期前仍然有效: %v\n", serverErr)
+	case all:
+		fmt.Println("已登出所有设备上的会话")
+	default:
+		fmt.Println("已登出")
+	}
+	return nil
+}
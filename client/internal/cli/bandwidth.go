@@ -0,0 +1,83 @@
+// client/internal/cli/bandwidth.go
+package cli
+
+import (
+	"fmt"
+	"fsync/client/internal/bandwidth"
+	"fsync/client/internal/control"
+	"net/http"
+	"strings"
+)
+
+// runBandwidth 处理 `bandwidth` 子命令，在不重启的情况下调整运行中客户端的限速
+func runBandwidth(args []string) error {
+	usage := fmt.Errorf("用法: bandwidth status | set upload|download <速率> | metered on|off | reset")
+	if len(args) == 0 {
+		return usage
+	}
+	c := control.NewClient(control.SocketPath())
+
+	var req bandwidth.Request
+	switch args[0] {
+	case "status":
+		var status bandwidth.Status
+		if err := c.Call(http.MethodGet, control.RouteBandwidth, nil, &status); err != nil {
+			return err
+		}
+		printBandwidthStatus(&status)
+		return nil
+	case "set":
+		if len(args) != 3 {
+			return usage
+		}
+		value := strings.TrimSpace(args[2])
+		var bps int64
+		if value != "unlimited" {
+			var err error
+			if bps, err = bandwidth.ParseSize(value); err != nil {
+				return err
+			}
+		}
+		switch args[1] {
+		case "upload", "up":
+			req.Upload = &bps
+		case "download", "down":
+			req.Download = &bps
+		default:
+			return usage
+		}
+	case "metered":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			return usage
+		}
+		on := args[1] == "on"
+		req.Metered = &on
+	case "reset":
+		req.Reset = true
+	default:
+		return fmt.Errorf("未知的 bandwidth 子命令: %s", args[0])
+	}
+
+	var status bandwidth.Status
+	if err := c.Call(http.MethodPost, control.RouteBandwidthUpdate, req, &status); err != nil {
+		return err
+	}
+	printBandwidthStatus(&status)
+	return nil
+}
+
+// printBandwidthStatus 打印限速状态
+func printBandwidthStatus(status *bandwidth.Status) {
+	fmt.Printf("上传: %s，下载: %s\n", bandwidth.FormatRate(status.Upload), bandwidth.FormatRate(status.Download))
+	if status.Rule != "" {
+		fmt.Printf("生效的时段规则: %s\n", status.Rule)
+	}
+	if status.Metered {
+		fmt.Printf("计量模式: 开启，超过 %s 的传输被暂缓\n", bandwidth.FormatSize(status.MeteredLimit))
+	} else {
+		fmt.Println("计量模式: 关闭")
+	}
+	if status.Override != (bandwidth.Override{}) {
+		fmt.Println("存在运行时调整，执行 `bandwidth reset` 恢复配置")
+	}
+}
@@ -0,0 +1,108 @@
+// client/internal/cli/cli.go
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Run 执行命令行子命令，args 不包含程序名。兼容 `--login` 形式的旧参数写法
+func Run(args []string) error {
+	name := strings.TrimPrefix(args[0], "--")
+	switch name {
+	case "login":
+		return runLogin()
+	case "register":
+		return runRegister()
+	case "logout":
+		return runLogout(args[1:])
+	case "deletes":
+		return runDeletes(args[1:])
+	case "encryption":
+		return runEncryption(args[1:])
+	case "bandwidth":
+		return runBandwidth(args[1:])
+	case "status":
+		return runStatus()
+	case "pause", "resume":
+		return runQueueAction(name)
+	case "rescan":
+		return runRescan()
+	case "undo":
+		return runUndo(args[1:])
+	case "conflicts":
+		return runConflicts()
+	case "shutdown":
+		return runShutdown()
+	case "trust":
+		return runTrust(args[1:])
+	case "device":
+		return runDevice(args[1:])
+	case "totp":
+		return runTOTP(args[1:])
+	case "lockout":
+		return runLockout(args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "help", "h":
+		printUsage()
+		return nil
+	default:
+		printUsage()
+		return fmt.Errorf("未知命令: %s", args[0])
+	}
+}
+
+// printUsage 打印命令行帮助
+func printUsage() {
+	fmt.Println(`用法: fsync-client [命令]
+
+不带命令时启动文件同步。
+
+命令:
+  login              登录
+  register           注册
+  logout [all]       登出，服务端的令牌随即失效；all 登出所有设备上的会话
+  status             查看运行中客户端的状态：队列、进行中的传输、最近的错误
+  pause              暂停同步，正在执行的传输会先完成
+  resume             恢复同步
+  rescan             重新扫描同步目录，补传遗漏的变更
+  undo [all]         撤销最后一个（或所有）操作
+  conflicts          列出被远端版本覆盖的未同步本地修改
+  shutdown           让运行中的客户端退出
+  deletes status     查看大量删除保护状态
+  deletes confirm    确认并同步被暂停的删除
+  deletes discard    放弃被暂停的删除，不同步到服务器
+  encryption init    初始化端到端加密，服务器上已有密钥时用口令在本机解锁
+  encryption join [代码]  用其他设备生成的一次性代码在本机解锁
+  encryption enroll  生成新设备加入用的一次性代码
+  encryption rekey   更换口令，已加密的数据无需重新加密
+  encryption recover         忘记口令时用恢复密钥解锁并设置新口令
+  encryption recovery-key    生成新的恢复密钥，旧的恢复密钥失效
+  encryption status  查看端到端加密状态
+  encryption calibrate [时长]  测算本机派生耗时约为指定时长（默认 1s）的 Argon2id 参数
+  encryption encode-path <路径>      显示路径在服务器上的加密形式
+  encryption decode-path <加密路径>  将服务器上的加密路径还原为明文
+  bandwidth status   查看当前限速和计量模式
+  bandwidth set upload|download <速率>  调整限速，如 512KB、2MB，unlimited 或 0 表示不限速
+  bandwidth metered on|off  开启或关闭计量模式，关闭后继续被暂缓的传输
+  bandwidth reset    清除运行时调整，恢复配置文件和时间表
+  trust status       查看已信任的服务器证书指纹
+  trust reset        删除已信任的服务器证书，服务器合法更换证书后执行，下次 login 时重新确认
+  device list [all]  列出本用户的设备（all 列出所有用户的设备，需要管理员），* 标记本设备
+  device rename <设备ID> <名称>  重命名设备
+  device logout <设备ID>  远程登出设备：吊销它的令牌并断开连接
+  device enroll [名称]  为本设备申请双向 TLS 使用的设备证书
+  device status      查看本设备的 ID 和证书
+  device revoke <设备ID>  吊销设备证书并立即断开其连接（管理员）
+  totp setup         开启两步验证：用验证器应用扫描二维码后输入验证码，保存好显示的恢复码
+  totp status        查看两步验证状态和剩余恢复码数量
+  totp disable       关闭两步验证
+  totp recovery-codes  生成新的恢复码，旧的恢复码失效
+  totp reset <用户名>  关闭用户的两步验证，用于用户丢失验证器和恢复码时（管理员）
+  lockout list       列出因连续登录失败被临时锁定的用户和 IP（管理员）
+  lockout unlock <用户名|IP>  解除锁定（管理员）
+  lockout log [用户名]  查看登录、锁定、解锁的审计日志（管理员）
+  config check       检查配置文件和 FSYNC_ 开头的环境变量，列出所有问题
+  help               显示帮助`)
+}
@@ -0,0 +1,19 @@
+// client/internal/cli/config.go
+package cli
+
+import (
+	"fmt"
+	"fsync/client/configs"
+)
+
+// runConfig 处理 `config check` 子命令，一次列出配置文件和环境变量覆盖中的所有问题
+func runConfig(args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return fmt.Errorf("用法: config check")
+	}
+	if _, err := configs.Reload(); err != nil {
+		return fmt.Errorf("%s\n%w", configs.File(), err)
+	}
+	fmt.Printf("配置检查通过: %s\n", configs.File())
+	return nil
+}
@@ -0,0 +1,125 @@
+// client/internal/cli/daemon.go
+package cli
+
+import (
+	"fmt"
+	"fsync/client/internal/bandwidth"
+	"fsync/client/internal/control"
+	"net/http"
+)
+
+// runStatus 处理 `status` 子命令，显示运行中客户端的状态
+func runStatus() error {
+	var status control.Status
+	if err := control.NewClient(control.SocketPath()).Call(http.MethodGet, control.RouteStatus, nil, &status); err != nil {
+		return err
+	}
+	fmt.Printf("同步目录: %s（启动于 %s）\n", status.SyncDir, status.StartedAt.Format("2006-01-02 15:04:05"))
+	if !status.LoggedIn {
+		fmt.Println("未登录，只监控不上传")
+	}
+	state := "运行中"
+	if status.Queue.Paused {
+		state = "已暂停"
+	}
+	fmt.Printf("命令队列: %s，排队 %d，执行中 %d\n", state, status.Queue.Pending, len(status.Queue.Running))
+	for _, desc := range status.Queue.Running {
+		fmt.Printf("  %s\n", desc)
+	}
+	for _, t := range status.Transfers {
+		kind := "上传"
+		if t.Kind == "download" {
+			kind = "下载"
+		}
+		total := "未知"
+		if t.Size > 0 {
+			total = bandwidth.FormatSize(t.Size)
+		}
+		fmt.Printf("  %s %s: %s / %s\n", kind, t.Path, bandwidth.FormatSize(t.Done), total)
+	}
+	if status.PendingTransfers > 0 {
+		fmt.Printf("等待继续的传输: %d\n", status.PendingTransfers)
+	}
+	if status.Queue.LastError != nil {
+		e := status.Queue.LastError
+		fmt.Printf("最近的错误（%s）: %s: %s\n", e.At.Format("2006-01-02 15:04:05"), e.Command, e.Error)
+	}
+	if status.Conflicts > 0 {
+		fmt.Printf("冲突: %d，执行 `conflicts` 查看\n", status.Conflicts)
+	}
+	if status.Deletes != nil && (status.Deletes.Tripped || status.Deletes.RootLost) {
+		printBrakeStatus(status.Deletes)
+	}
+	fmt.Printf("限速: 上传 %s，下载 %s", bandwidth.FormatRate(status.Bandwidth.Upload), bandwidth.FormatRate(status.Bandwidth.Download))
+	if status.Bandwidth.Metered {
+		fmt.Print("，计量模式")
+	}
+	fmt.Println()
+	return nil
+}
+
+// runQueueAction 处理 `pause`、`resume` 子命令
+func runQueueAction(action string) error {
+	route := control.RoutePause
+	if action == "resume" {
+		route = control.RouteResume
+	}
+	if err := control.NewClient(control.SocketPath()).Call(http.MethodPost, route, nil, nil); err != nil {
+		return err
+	}
+	if action == "resume" {
+		fmt.Println("同步已恢复")
+	} else {
+		fmt.Println("同步已暂停，正在执行的传输会先完成；文件变化仍会记录，恢复后执行")
+	}
+	return nil
+}
+
+// runRescan 处理 `rescan` 子命令
+func runRescan() error {
+	var result control.RescanResult
+	if err := control.NewClient(control.SocketPath()).Call(http.MethodPost, control.RouteRescan, nil, &result); err != nil {
+		return err
+	}
+	fmt.Printf("已开始重新扫描，%d 个文件将依次检查上传\n", result.Files)
+	return nil
+}
+
+// runUndo 处理 `undo [all]` 子命令
+func runUndo(args []string) error {
+	req := control.UndoRequest{All: len(args) > 0 && args[0] == "all"}
+	if err := control.NewClient(control.SocketPath()).Call(http.MethodPost, control.RouteUndo, req, nil); err != nil {
+		return err
+	}
+	if req.All {
+		fmt.Println("已撤销所有操作")
+	} else {
+		fmt.Println("已撤销最后一个操作")
+	}
+	return nil
+}
+
+// runConflicts 处理 `conflicts` 子命令
+func runConflicts() error {
+	var conflicts []control.Conflict
+	if err := control.NewClient(control.SocketPath()).Call(http.MethodGet, control.RouteConflicts, nil, &conflicts); err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		fmt.Println("没有冲突")
+		return nil
+	}
+	for _, c := range conflicts {
+		fmt.Printf("%s  %s\n    本地版本: %s\n", c.At.Format("2006-01-02 15:04:05"), c.Path, c.LocalCopy)
+	}
+	return nil
+}
+
+// runShutdown 处理 `shutdown` 子命令
+func runShutdown() error {
+	if err := control.NewClient(control.SocketPath()).Call(http.MethodPost, control.RouteShutdown, nil, nil); err != nil {
+		return err
+	}
+	fmt.Println("客户端正在退出")
+	return nil
+}
@@ -0,0 +1,64 @@
+// client/internal/cli/deletes.go
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"fsync/client/internal/control"
+	"fsync/client/internal/safety"
+	"net/http"
+)
+
+// runDeletes 处理 `deletes` 子命令，通过控制接口与运行中的客户端交互
+func runDeletes(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: deletes status|confirm|discard")
+	}
+	c := control.NewClient(control.SocketPath())
+
+	switch args[0] {
+	case "status":
+		var status safety.BrakeStatus
+		if err := c.Call(http.MethodGet, control.RouteDeletes, nil, &status); err != nil {
+			return err
+		}
+		printBrakeStatus(&status)
+		return nil
+	case "confirm", "discard":
+		route := control.RouteDeletesConfirm
+		if args[0] == "discard" {
+			route = control.RouteDeletesDiscard
+		}
+		var result control.DeletesResult
+		if err := c.Call(http.MethodPost, route, nil, &result); err != nil {
+			return err
+		}
+		if args[0] == "confirm" {
+			fmt.Printf("已放行 %d 个删除\n", result.Count)
+			if result.Skipped > 0 {
+				fmt.Printf("%d 个文件已重新出现在本地，不再删除\n", result.Skipped)
+			}
+			if result.Error != "" {
+				return errors.New(result.Error)
+			}
+		} else {
+			fmt.Printf("已放弃 %d 个删除\n", result.Count)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的 deletes 子命令: %s", args[0])
+	}
+}
+
+// printBrakeStatus 打印删除保护状态
+func printBrakeStatus(status *safety.BrakeStatus) {
+	switch {
+	case status.RootLost:
+		fmt.Println("同步根目录不可用，所有删除已暂停")
+	case status.Tripped:
+		fmt.Printf("删除保护已触发（%s）: %s\n", status.TrippedAt.Format("2006-01-02 15:04:05"), status.Reason)
+	default:
+		fmt.Println("删除保护未触发")
+	}
+	fmt.Printf("暂停的删除: %d，跟踪的文件: %d\n", status.HeldDeletes, status.Tracked)
+}
@@ -0,0 +1,168 @@
+// client/internal/cli/device.go
+package cli
+
+import (
+	"fmt"
+	"fsync/client/global"
+	"fsync/client/internal/api"
+	"strings"
+	"time"
+)
+
+// runDevice 处理 `device` 子命令
+func runDevice(args []string) error {
+	usage := fmt.Errorf("用法: device list [all] | rename <设备ID> <名称> | logout <设备ID> | enroll [名称] | status | revoke <设备ID>")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "enroll":
+		return runDeviceEnroll(strings.TrimSpace(strings.Join(args[1:], " ")))
+	case "status":
+		fmt.Printf("设备 ID: %s\n", orDash(api.LocalDeviceID()))
+		cert, err := api.DeviceCert()
+		if err != nil {
+			return err
+		}
+		if cert == nil {
+			fmt.Println("设备证书: 未申请，执行 `device enroll` 申请")
+			return nil
+		}
+		fmt.Printf("设备证书有效期: %s 至 %s\n", cert.NotBefore.Format(time.DateOnly), cert.NotAfter.Format(time.DateOnly))
+		return nil
+	case "list":
+		all := len(args) > 1 && args[1] == "all"
+		c, err := api.NewAuthedClient()
+		if err != nil {
+			return err
+		}
+		var devices []api.Device
+		if all {
+			devices, err = c.ListAllDevices()
+		} else {
+			devices, err = c.ListDevices()
+		}
+		if err != nil {
+			return err
+		}
+		if len(devices) == 0 {
+			fmt.Println("没有设备")
+			return nil
+		}
+		for _, d := range devices {
+			printDevice(&d, all)
+		}
+		return nil
+	case "rename":
+		if len(args) < 3 {
+			return usage
+		}
+		c, err := api.NewAuthedClient()
+		if err != nil {
+			return err
+		}
+		d, err := c.RenameDevice(args[1], strings.Join(args[2:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("设备 %s 已重命名为 %s\n", d.ID, strings.Join(args[2:], " "))
+		return nil
+	case "logout":
+		if len(args) != 2 {
+			return usage
+		}
+		c, err := api.NewAuthedClient()
+		if err != nil {
+			return err
+		}
+		d, err := c.LogoutDevice(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已登出设备 %s（%s），它的令牌已失效，连接已断开\n", d.ID, d.Name)
+		if d.ID == api.LocalDeviceID() {
+			return api.RemoveTokens()
+		}
+		return nil
+	case "revoke":
+		if len(args) != 2 {
+			return usage
+		}
+		c, err := api.NewAuthedClient()
+		if err != nil {
+			return err
+		}
+		d, err := c.RevokeDevice(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已吊销设备 %s（%s 的 %s）的证书，该设备的连接已断开\n", d.ID, d.Username, d.Name)
+		return nil
+	default:
+		return usage
+	}
+}
+
+// printDevice 打印设备信息，withUser 时显示所属用户
+func printDevice(d *api.Device, withUser bool) {
+	mark := " "
+	if d.Current {
+		mark = "*"
+	}
+	name := d.Name
+	if withUser {
+		name = d.Username + " / " + name
+	}
+	fmt.Printf("%s %s  %s\n", mark, d.ID, name)
+	fmt.Printf("    %s，客户端 %s，最近活动 %s（%s）\n", orDash(d.Platform), orDash(d.ClientVersion),
+		d.LastSeenAt.Format("2006-01-02 15:04:05"), orDash(d.LastSeenIP))
+	switch {
+	case d.RevokedAt != nil:
+		fmt.Printf("    设备证书已吊销于 %s\n", d.RevokedAt.Format("2006-01-02 15:04:05"))
+	case d.CertNotAfter != nil:
+		fmt.Printf("    设备证书有效至 %s\n", d.CertNotAfter.Format(time.DateOnly))
+	}
+}
+
+// orDash 空字符串显示为 -
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// runDeviceEnroll 为本设备申请设备证书，name 非空时同时重命名设备
+func runDeviceEnroll(name string) error {
+	if global.Config().Client.Protocol != "https" {
+		return fmt.Errorf("设备证书用于双向 TLS，需要 protocol 为 https")
+	}
+	if err := confirmServerCert(); err != nil {
+		return err
+	}
+	existing, err := api.DeviceCert()
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		answer, err := prompt("本设备已有设备证书，重新申请会替换现有证书，是否继续？[y/N] ")
+		if err != nil {
+			return err
+		}
+		if answer = strings.ToLower(answer); answer != "y" && answer != "yes" {
+			return fmt.Errorf("已取消")
+		}
+	}
+
+	c, err := api.NewAuthedClient()
+	if err != nil {
+		return err
+	}
+	device, err := c.EnrollDevice(name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已为设备 %s（%s）签发证书，有效至 %s\n", device.Name, device.ID, device.CertNotAfter.Format(time.DateOnly))
+	fmt.Println("运行中的客户端需要重启后才会使用设备证书")
+	return nil
+}
@@ -0,0 +1,406 @@
+// client/internal/cli/encryption.go
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"fsync/client/global"
+	"fsync/client/internal/api"
+	"fsync/client/internal/e2e"
+	"fsync/pkg/crypto"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+)
+
+// defaultCalibrateTarget 测算 KDF 参数的默认目标耗时
+const defaultCalibrateTarget = time.Second
+
+// runEncryption 处理 `encryption` 子命令
+func runEncryption(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: encryption init|join|rekey|recover|recovery-key|enroll|status|calibrate|encode-path|decode-path")
+	}
+	root := global.Config().Client.SyncDir
+
+	switch args[0] {
+	case "init":
+		return runEncryptionInit(root)
+	case "join":
+		code := ""
+		if len(args) > 1 {
+			code = strings.Join(args[1:], "")
+		}
+		return runEncryptionJoin(code)
+	case "rekey":
+		return runEncryptionRekey()
+	case "recover":
+		return runEncryptionRecover()
+	case "recovery-key":
+		return runEncryptionRecoveryKey()
+	case "enroll":
+		return runEncryptionEnroll()
+	case "status":
+		return runEncryptionStatus()
+	case "calibrate":
+		target := defaultCalibrateTarget
+		if len(args) > 1 {
+			d, err := time.ParseDuration(args[1])
+			if err != nil {
+				return fmt.Errorf("目标时长格式错误（如 500ms、1s）: %w", err)
+			}
+			target = d
+		}
+		return runEncryptionCalibrate(target)
+	case "encode-path", "decode-path":
+		if len(args) < 2 {
+			return fmt.Errorf("用法: encryption %s <路径>", args[0])
+		}
+		keys, err := e2e.LoadKeys(e2e.RootID())
+		if err != nil {
+			return err
+		}
+		p := strings.Trim(filepath.ToSlash(args[1]), "/")
+		if args[0] == "encode-path" {
+			p, err = keys.EncryptPath(p)
+		} else {
+			p, err = keys.DecryptPath(p)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(p)
+		return nil
+	default:
+		return fmt.Errorf("未知的 encryption 子命令: %s", args[0])
+	}
+}
+
+// runEncryptionInit 首次启用加密：生成主密钥，用口令包装后把密钥文件保存到服务器。
+// 服务器上已有密钥文件时改为用口令加入
+func runEncryptionInit(root string) error {
+	client, kf, version, err := fetchKeyFile()
+	if err != nil {
+		return err
+	}
+	if kf != nil {
+		fmt.Println("服务器上已有密钥文件，使用口令在本机解锁")
+		passphrase, err := promptPassword("加密口令: ")
+		if err != nil {
+			return err
+		}
+		master, err := kf.UnlockPassphrase(passphrase)
+		if err != nil {
+			return err
+		}
+		return finishUnlock(master)
+	}
+
+	passphrase, err := promptNewPassphrase()
+	if err != nil {
+		return err
+	}
+	// 早期版本由口令直接派生密钥，迁移时沿用原密钥，已上传的数据无需重新加密
+	master, err := e2e.LegacyMasterKey(root, passphrase)
+	if err != nil {
+		return err
+	}
+	if master == nil {
+		if master, err = e2e.NewMasterKey(); err != nil {
+			return err
+		}
+	}
+	kf = e2e.NewKeyFile(master)
+	if err := kf.SetPassphrase(master, passphrase, kdfParams()); err != nil {
+		return err
+	}
+	recovery, err := kf.NewRecoveryKey(master)
+	if err != nil {
+		return err
+	}
+	if err := saveKeyFile(client, kf, version); err != nil {
+		return err
+	}
+	if err := e2e.SaveMasterKey(e2e.RootID(), master); err != nil {
+		return err
+	}
+	e2e.RemoveLegacyDescriptor(root)
+
+	fmt.Println("端到端加密已初始化，口令不会发送到服务器")
+	printRecoveryKey(recovery)
+	fmt.Println("在其他设备上执行 `encryption enroll` 生成加入代码，或直接用口令执行 `encryption init`")
+	printEnableHint()
+	return nil
+}
+
+// runEncryptionJoin 用其他设备生成的一次性代码加入
+func runEncryptionJoin(code string) error {
+	client, kf, version, err := fetchKeyFile()
+	if err != nil {
+		return err
+	}
+	if kf == nil {
+		return fmt.Errorf("服务器上没有密钥文件，请先在已有设备上执行 encryption init")
+	}
+	if code == "" {
+		if code, err = prompt("加入代码: "); err != nil {
+			return err
+		}
+	}
+	master, err := kf.RedeemEnrollCode(code)
+	if err != nil {
+		return err
+	}
+	// 代码只能使用一次，先从服务器移除再在本机保存
+	if err := saveKeyFile(client, kf, version); err != nil {
+		return err
+	}
+	return finishUnlock(master)
+}
+
+// runEncryptionRekey 更换口令：只重新包装主密钥，已加密的数据不变
+func runEncryptionRekey() error {
+	client, kf, version, err := fetchKeyFile()
+	if err != nil {
+		return err
+	}
+	if kf == nil {
+		return fmt.Errorf("服务器上没有密钥文件，请先执行 encryption init")
+	}
+	current, err := promptPassword("当前口令: ")
+	if err != nil {
+		return err
+	}
+	master, err := kf.UnlockPassphrase(current)
+	if err != nil {
+		return err
+	}
+	passphrase, err := promptNewPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := kf.SetPassphrase(master, passphrase, kdfParams()); err != nil {
+		return err
+	}
+	if err := saveKeyFile(client, kf, version); err != nil {
+		return err
+	}
+	fmt.Println("口令已更换，其他设备无需任何操作")
+	return nil
+}
+
+// runEncryptionRecover 忘记口令时用恢复密钥解锁，并设置新口令
+func runEncryptionRecover() error {
+	client, kf, version, err := fetchKeyFile()
+	if err != nil {
+		return err
+	}
+	if kf == nil {
+		return fmt.Errorf("服务器上没有密钥文件")
+	}
+	code, err := prompt("恢复密钥: ")
+	if err != nil {
+		return err
+	}
+	master, err := kf.UnlockRecoveryKey(code)
+	if err != nil {
+		return err
+	}
+	fmt.Println("恢复密钥正确，请设置新口令")
+	passphrase, err := promptNewPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := kf.SetPassphrase(master, passphrase, kdfParams()); err != nil {
+		return err
+	}
+	if err := saveKeyFile(client, kf, version); err != nil {
+		return err
+	}
+	return finishUnlock(master)
+}
+
+// runEncryptionRecoveryKey 生成新的恢复密钥，旧的恢复密钥随即失效
+func runEncryptionRecoveryKey() error {
+	master, err := e2e.LoadMasterKey(e2e.RootID())
+	if err != nil {
+		return err
+	}
+	client, kf, version, err := fetchKeyFile()
+	if err != nil {
+		return err
+	}
+	if kf == nil {
+		return fmt.Errorf("服务器上没有密钥文件")
+	}
+	recovery, err := kf.NewRecoveryKey(master)
+	if err != nil {
+		return err
+	}
+	if err := saveKeyFile(client, kf, version); err != nil {
+		return err
+	}
+	fmt.Println("已生成新的恢复密钥，旧的恢复密钥已失效")
+	printRecoveryKey(recovery)
+	return nil
+}
+
+// runEncryptionEnroll 在已解锁的设备上生成新设备加入用的一次性代码
+func runEncryptionEnroll() error {
+	master, err := e2e.LoadMasterKey(e2e.RootID())
+	if err != nil {
+		return err
+	}
+	client, kf, version, err := fetchKeyFile()
+	if err != nil {
+		return err
+	}
+	if kf == nil {
+		return fmt.Errorf("服务器上没有密钥文件")
+	}
+	code, err := kf.NewEnrollCode(master)
+	if err != nil {
+		return err
+	}
+	if err := saveKeyFile(client, kf, version); err != nil {
+		return err
+	}
+	fmt.Printf("在新设备上登录同一账号后执行 `encryption join`，输入或扫描以下代码（%s 内有效，仅能使用一次）:\n\n", e2e.EnrollTTL)
+	qrterminal.GenerateHalfBlock(code, qrterminal.L, os.Stdout)
+	fmt.Printf("\n  %s\n\n", code)
+	return nil
+}
+
+// runEncryptionStatus 显示加密状态
+func runEncryptionStatus() error {
+	if _, err := e2e.LoadKeys(e2e.RootID()); err != nil {
+		fmt.Printf("本机密钥不可用: %v\n", err)
+	} else {
+		fmt.Println("本机密钥可用")
+	}
+	fmt.Printf("配置中已启用: %v，加密根目录: %s\n", global.Config().Client.Encryption.Enabled, e2e.RootID())
+
+	_, kf, version, err := fetchKeyFile()
+	if err != nil {
+		fmt.Printf("无法读取服务器上的密钥文件: %v\n", err)
+		return nil
+	}
+	if kf == nil {
+		fmt.Println("服务器上没有密钥文件，尚未初始化端到端加密")
+		return nil
+	}
+	fmt.Printf("服务器密钥文件版本: %d，口令: %d，恢复密钥: %d，待使用的加入代码: %d\n", version,
+		kf.Count(e2e.WrapPassphrase), kf.Count(e2e.WrapRecovery), kf.Count(e2e.WrapEnroll))
+	return nil
+}
+
+// runEncryptionCalibrate 测算在本机派生耗时约为 target 的 Argon2id 参数
+func runEncryptionCalibrate(target time.Duration) error {
+	cfg := global.Config().Client.Encryption.KDF
+	fmt.Printf("正在测算，目标耗时 %s ...\n", target)
+	params, err := crypto.Calibrate(target, cfg.MemoryKiB, cfg.Threads)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if _, err := params.DeriveKey([]byte("calibration"), make([]byte, 16), 32); err != nil {
+		return err
+	}
+	fmt.Printf("建议参数（实测 %s），写入配置文件 client.encryption.kdf 后执行 `encryption rekey` 生效:\n", time.Since(start).Round(time.Millisecond))
+	fmt.Printf("  time: %d\n  memory_kib: %d\n  threads: %d\n", params.Time, params.MemoryKiB, params.Threads)
+	return nil
+}
+
+// kdfParams 返回配置的 Argon2id 参数，为 0 的项使用默认值
+func kdfParams() crypto.KDFParams {
+	cfg := global.Config().Client.Encryption.KDF
+	params := crypto.DefaultKDFParams
+	if cfg.Time != 0 {
+		params.Time = cfg.Time
+	}
+	if cfg.MemoryKiB != 0 {
+		params.MemoryKiB = cfg.MemoryKiB
+	}
+	if cfg.Threads != 0 {
+		params.Threads = cfg.Threads
+	}
+	return params
+}
+
+// fetchKeyFile 读取服务器上当前加密根目录（client.encryption.root_id）的密钥文件，不存在时返回 nil 和版本 0
+func fetchKeyFile() (*api.Client, *e2e.KeyFile, int, error) {
+	client, err := api.NewAuthedClient()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("请先登录: %w", err)
+	}
+	resp, err := client.GetKeyFile(e2e.RootID())
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) && statusErr.Status == http.StatusNotFound {
+		return client, nil, 0, nil
+	}
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	kf, err := e2e.ParseKeyFile(resp.Data)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return client, kf, resp.Version, nil
+}
+
+// saveKeyFile 将密钥文件保存到服务器
+func saveKeyFile(client *api.Client, kf *e2e.KeyFile, version int) error {
+	data, err := kf.Encode()
+	if err != nil {
+		return err
+	}
+	if _, err := client.PutKeyFile(e2e.RootID(), data, version); err != nil {
+		return fmt.Errorf("保存密钥文件失败: %w", err)
+	}
+	return nil
+}
+
+// finishUnlock 在本机保存主密钥
+func finishUnlock(master []byte) error {
+	if err := e2e.SaveMasterKey(e2e.RootID(), master); err != nil {
+		return err
+	}
+	fmt.Println("本机已解锁端到端加密")
+	printEnableHint()
+	return nil
+}
+
+// promptNewPassphrase 输入并确认新口令
+func promptNewPassphrase() (string, error) {
+	passphrase, err := promptPassword("新口令: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("口令不能为空")
+	}
+	confirm, err := promptPassword("确认口令: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != passphrase {
+		return "", fmt.Errorf("两次输入的口令不一致")
+	}
+	return passphrase, nil
+}
+
+// printRecoveryKey 打印恢复密钥
+func printRecoveryKey(recovery string) {
+	fmt.Printf("\n恢复密钥（请离线妥善保存，忘记口令时用 `encryption recover` 恢复，不会再次显示）:\n\n  %s\n\n", recovery)
+}
+
+// printEnableHint 提示在配置中启用加密
+func printEnableHint() {
+	if !global.Config().Client.Encryption.Enabled {
+		fmt.Println("请在配置文件中设置 client.encryption.enabled: true 后重启客户端")
+	}
+}
@@ -0,0 +1,81 @@
+// client/internal/cli/lockout.go
+package cli
+
+import (
+	"fmt"
+	"fsync/client/internal/api"
+	"net"
+	"time"
+)
+
+// runLockout 处理 `lockout` 子命令，均需要管理员权限
+func runLockout(args []string) error {
+	usage := fmt.Errorf("用法: lockout list | unlock <用户名|IP> | log [用户名]")
+	if len(args) == 0 {
+		return usage
+	}
+	c, err := api.NewAuthedClient()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		lockouts, err := c.ListLockouts()
+		if err != nil {
+			return err
+		}
+		if len(lockouts) == 0 {
+			fmt.Println("没有被锁定的用户或 IP")
+			return nil
+		}
+		for _, l := range lockouts {
+			target := "用户 " + l.Username
+			if l.IP != "" {
+				target = "IP " + l.IP
+			}
+			fmt.Printf("%s  锁定至 %s（累计 %d 次）\n", target, l.LockedUntil.Local().Format(time.DateTime), l.Lockouts)
+		}
+		return nil
+	case "unlock":
+		if len(args) < 2 {
+			return usage
+		}
+		if net.ParseIP(args[1]) != nil {
+			err = c.UnlockIP(args[1])
+		} else {
+			err = c.UnlockUser(args[1])
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已解除 %s 的锁定\n", args[1])
+		return nil
+	case "log":
+		username := ""
+		if len(args) > 1 {
+			username = args[1]
+		}
+		entries, err := c.AuditLog(username, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			fmt.Printf("%s  %-16s  %-12s  %-15s  %s\n", e.CreatedAt.Local().Format(time.DateTime), e.Event,
+				orDash(e.Username), orDash(e.IP), auditDetail(&e))
+		}
+		return nil
+	default:
+		return usage
+	}
+}
+
+// auditDetail 拼接审计记录的说明和操作人
+func auditDetail(e *api.AuditEntry) string {
+	if e.Actor == "" {
+		return e.Detail
+	}
+	if e.Detail == "" {
+		return "操作人 " + e.Actor
+	}
+	return e.Detail + "，操作人 " + e.Actor
+}
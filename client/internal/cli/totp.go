@@ -0,0 +1,102 @@
+// client/internal/cli/totp.go
+package cli
+
+import (
+	"fmt"
+	"fsync/client/internal/api"
+	"os"
+
+	"github.com/mdp/qrterminal/v3"
+)
+
+// runTOTP 处理 `totp` 子命令
+func runTOTP(args []string) error {
+	usage := fmt.Errorf("用法: totp setup | status | disable | recovery-codes | reset <用户名>")
+	if len(args) == 0 {
+		return usage
+	}
+	c, err := api.NewAuthedClient()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "setup":
+		return runTOTPSetup(c)
+	case "status":
+		status, err := c.TOTPStatus()
+		if err != nil {
+			return err
+		}
+		if !status.Enabled {
+			fmt.Println("两步验证: 未开启，执行 `totp setup` 开启")
+			return nil
+		}
+		fmt.Printf("两步验证: 已开启，剩余 %d 个恢复码\n", status.RecoveryCodes)
+		return nil
+	case "disable":
+		code, err := prompt("两步验证码（或恢复码）: ")
+		if err != nil {
+			return err
+		}
+		if err := c.DisableTOTP(code); err != nil {
+			return err
+		}
+		fmt.Println("两步验证已关闭")
+		return nil
+	case "recovery-codes":
+		code, err := prompt("两步验证码: ")
+		if err != nil {
+			return err
+		}
+		codes, err := c.RegenerateRecoveryCodes(code)
+		if err != nil {
+			return err
+		}
+		printRecoveryCodes(codes)
+		return nil
+	case "reset":
+		if len(args) < 2 {
+			return usage
+		}
+		if err := c.ResetTOTP(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("已关闭用户 %s 的两步验证\n", args[1])
+		return nil
+	default:
+		return usage
+	}
+}
+
+// runTOTPSetup 生成密钥并显示二维码，验证第一个验证码后开启两步验证
+func runTOTPSetup(c *api.Client) error {
+	setup, err := c.SetupTOTP()
+	if err != nil {
+		return err
+	}
+	fmt.Println("用验证器应用扫描以下二维码，或手动输入密钥:")
+	fmt.Println()
+	qrterminal.GenerateHalfBlock(setup.URI, qrterminal.L, os.Stdout)
+	fmt.Printf("\n  %s\n\n", setup.Secret)
+	code, err := prompt("验证器显示的验证码: ")
+	if err != nil {
+		return err
+	}
+	codes, err := c.EnableTOTP(code)
+	if err != nil {
+		return err
+	}
+	fmt.Println("两步验证已开启")
+	printRecoveryCodes(codes)
+	return nil
+}
+
+// printRecoveryCodes 显示恢复码，恢复码只在生成时显示一次
+func printRecoveryCodes(codes []string) {
+	fmt.Println("恢复码（每个只能使用一次，丢失验证器时代替验证码登录，请妥善保存，之后不会再显示）:")
+	fmt.Println()
+	for _, code := range codes {
+		fmt.Printf("  %s\n", code)
+	}
+	fmt.Println()
+}
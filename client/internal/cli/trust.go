@@ -0,0 +1,82 @@
+// client/internal/cli/trust.go
+package cli
+
+import (
+	"crypto/x509"
+	"fmt"
+	"fsync/client/global"
+	"fsync/client/internal/api"
+	"strings"
+	"time"
+)
+
+// confirmServerCert 首次连接服务器时显示证书指纹，用户确认后固定该证书；已信任过时不做任何事，由之后的请求校验
+func confirmServerCert() error {
+	if global.Config().Client.Protocol != "https" {
+		return nil
+	}
+	pinned, err := api.PinnedCert()
+	if err != nil || pinned != nil {
+		return err
+	}
+	cert, err := api.FetchServerCert()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("首次连接服务器 %s，请向管理员核实证书指纹后再确认:\n", global.Config().Client.ServerAddr)
+	printCert(cert)
+	answer, err := prompt("是否信任该证书？[y/N] ")
+	if err != nil {
+		return err
+	}
+	if answer = strings.ToLower(answer); answer != "y" && answer != "yes" {
+		return fmt.Errorf("未信任服务器证书，已取消")
+	}
+	if err := api.PinCert(cert); err != nil {
+		return err
+	}
+	fmt.Println("已信任该证书，之后只接受该证书（或由它签发的证书），证书变化时拒绝连接")
+	return nil
+}
+
+// runTrust 处理 `trust` 子命令
+func runTrust(args []string) error {
+	usage := fmt.Errorf("用法: trust status | reset")
+	if len(args) != 1 {
+		return usage
+	}
+	switch args[0] {
+	case "status":
+		pinned, err := api.PinnedCert()
+		if err != nil {
+			return err
+		}
+		if pinned == nil {
+			fmt.Printf("尚未信任服务器 %s 的证书，执行 login 时确认\n", global.Config().Client.ServerAddr)
+			return nil
+		}
+		fmt.Printf("已信任服务器 %s 的证书:\n", global.Config().Client.ServerAddr)
+		printCert(pinned)
+		return nil
+	case "reset":
+		removed, err := api.ResetTrust()
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Printf("尚未信任服务器 %s 的证书\n", global.Config().Client.ServerAddr)
+			return nil
+		}
+		fmt.Println("已删除信任的证书，请执行 login 确认服务器的新证书")
+		return nil
+	default:
+		return usage
+	}
+}
+
+// printCert 打印证书的指纹和基本信息
+func printCert(cert *x509.Certificate) {
+	fmt.Printf("  SHA-256 指纹: %s\n", api.Fingerprint(cert))
+	fmt.Printf("  主题: %s\n", cert.Subject)
+	fmt.Printf("  有效期: %s 至 %s\n", cert.NotBefore.Format(time.DateOnly), cert.NotAfter.Format(time.DateOnly))
+}
@@ -3,6 +3,8 @@ package command
 
 import (
 	"fsync/client/global"
+	"fsync/client/internal/transfer"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -13,6 +15,7 @@ type FileCommand struct {
 	FilePath    string
 	Description string
 	Logger      *zap.Logger
+	Transfer    *transfer.Manager // 为 nil 时（未登录）只记录不上传
 }
 
 // Execute 执行命令
@@ -25,14 +28,17 @@ func (fc *FileCommand) Execute() error {
 	case "create_dir":
 		global.Logger.Info("创建目录")
 
-	case "create_file":
-		global.Logger.Info("创建文件")
-
-	case "write":
-		global.Logger.Info("修改文件")
+	case "create_file", "write":
+		global.Logger.Info("上传文件")
+		if fc.Transfer != nil {
+			return fc.Transfer.Upload(fc.FilePath)
+		}
 
 	case "remove":
 		global.Logger.Info("删除文件")
+		if fc.Transfer != nil {
+			return fc.Transfer.Remove(fc.FilePath)
+		}
 
 	case "rename":
 		global.Logger.Info("重命名文件")
@@ -80,6 +86,14 @@ func (cm *CommandManager) AddCommand(cmd Command) {
 	cm.Logger.Info("添加命令到命令队列并准备异步执行", zap.String("description", cmd.GetDescription()))
 }
 
+// AddCommandWait 添加命令，队列已满时等待而不是丢弃，用于批量提交（如重新扫描）
+func (cm *CommandManager) AddCommandWait(cmd Command) bool {
+	cm.mutex.Lock()
+	cm.Commands = append(cm.Commands, cmd)
+	cm.mutex.Unlock()
+	return cm.CommandQueue.EnqueueWait(cmd)
+}
+
 // UndoLast 撤销最后一个命令
 func (cm *CommandManager) UndoLast() error {
 	cm.mutex.Lock()
@@ -125,6 +139,13 @@ func (cm *CommandManager) UndoAll() error {
 	return nil
 }
 
+// Shutdown 在 timeout 内执行完排队的命令后停止，返回未能执行的命令
+func (cm *CommandManager) Shutdown(timeout time.Duration) []Command {
+	remaining := cm.CommandQueue.Shutdown(timeout)
+	cm.Logger.Info("Command Manager stopped gracefully")
+	return remaining
+}
+
 // Stop 优雅地停止命令管理器（停止内部的队列）
 func (cm *CommandManager) Stop() {
 	cm.CommandQueue.Stop()
@@ -2,7 +2,9 @@
 package command
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -15,6 +17,29 @@ type CommandQueue struct {
 	// 用于优雅关闭
 	wg   sync.WaitGroup
 	quit chan struct{}
+
+	// 暂停时 gate 是一个未关闭的 channel，工作协程取到命令后等待它被关闭再执行
+	mutex     sync.Mutex
+	gate      chan struct{}
+	paused    bool
+	held      map[int]Command // 工作协程 ID -> 因暂停而等待执行的命令
+	running   map[int]string  // 工作协程 ID -> 正在执行的命令描述
+	lastError *CommandError
+}
+
+// CommandError 最近一次命令执行失败的信息
+type CommandError struct {
+	Command string    `json:"command"`
+	Error   string    `json:"error"`
+	At      time.Time `json:"at"`
+}
+
+// QueueStats 命令队列的运行状态
+type QueueStats struct {
+	Pending   int           `json:"pending"` // 排队等待执行的命令数量
+	Running   []string      `json:"running"` // 正在执行的命令
+	Paused    bool          `json:"paused"`  // 是否已暂停
+	LastError *CommandError `json:"last_error,omitempty"`
 }
 
 // NewCommandQueue 创建一个新的命令队列
@@ -24,6 +49,9 @@ func NewCommandQueue(logger *zap.Logger, bufferSize int, numWorkers int) *Comman
 		workers:  numWorkers,
 		logger:   logger,
 		quit:     make(chan struct{}),
+		gate:     closedGate(),
+		held:     make(map[int]Command),
+		running:  make(map[int]string),
 	}
 
 	// 启动工作协程
@@ -45,9 +73,19 @@ func (cq *CommandQueue) worker(workerID int) {
 				cq.logger.Info("工作线程接收到关闭信号", zap.Int("worker_id", workerID))
 				return
 			}
+			if !cq.awaitResume(workerID, cmd) {
+				cq.logger.Info("Worker shutting down", zap.Int("worker_id", workerID))
+				return
+			}
 			cq.logger.Info("工作线程开始执行命令", zap.Int("worker_id", workerID), zap.String("command_desc", cmd.GetDescription()))
-			if err := cmd.Execute(); err != nil {
+			cq.setRunning(workerID, cmd.GetDescription())
+			err := cmd.Execute()
+			cq.setRunning(workerID, "")
+			if err != nil {
 				cq.logger.Error("工作线程执行命令失败", zap.Int("worker_id", workerID), zap.Error(err))
+				cq.mutex.Lock()
+				cq.lastError = &CommandError{Command: cmd.GetDescription(), Error: err.Error(), At: time.Now()}
+				cq.mutex.Unlock()
 			} else {
 				cq.logger.Info("工作线程已完成命令执行", zap.Int("worker_id", workerID), zap.String("command_desc", cmd.GetDescription()))
 			}
@@ -58,6 +96,75 @@ func (cq *CommandQueue) worker(workerID int) {
 	}
 }
 
+// awaitResume 暂停期间等待恢复后再执行命令，正在执行的命令不受暂停影响。
+// 等待期间队列停止时返回 false，命令留在 held 中由 Shutdown 返回给调用方
+func (cq *CommandQueue) awaitResume(workerID int, cmd Command) bool {
+	cq.mutex.Lock()
+	gate := cq.gate
+	cq.held[workerID] = cmd
+	cq.mutex.Unlock()
+	select {
+	case <-gate:
+		cq.mutex.Lock()
+		delete(cq.held, workerID)
+		cq.mutex.Unlock()
+		return true
+	case <-cq.quit:
+		return false
+	}
+}
+
+// setRunning 记录工作协程正在执行的命令，desc 为空表示空闲
+func (cq *CommandQueue) setRunning(workerID int, desc string) {
+	cq.mutex.Lock()
+	defer cq.mutex.Unlock()
+	if desc == "" {
+		delete(cq.running, workerID)
+		return
+	}
+	cq.running[workerID] = desc
+}
+
+// Pause 暂停执行新命令，已在执行的命令会继续完成，新命令仍可入队
+func (cq *CommandQueue) Pause() {
+	cq.mutex.Lock()
+	defer cq.mutex.Unlock()
+	if cq.paused {
+		return
+	}
+	cq.paused = true
+	cq.gate = make(chan struct{})
+	cq.logger.Info("命令队列已暂停")
+}
+
+// Resume 恢复执行命令
+func (cq *CommandQueue) Resume() {
+	cq.mutex.Lock()
+	defer cq.mutex.Unlock()
+	if !cq.paused {
+		return
+	}
+	cq.paused = false
+	close(cq.gate)
+	cq.logger.Info("命令队列已恢复")
+}
+
+// Stats 返回命令队列的运行状态
+func (cq *CommandQueue) Stats() QueueStats {
+	cq.mutex.Lock()
+	defer cq.mutex.Unlock()
+	running := make([]string, 0, len(cq.running))
+	for _, desc := range cq.running {
+		running = append(running, desc)
+	}
+	return QueueStats{
+		Pending:   len(cq.commands) + len(cq.held),
+		Running:   running,
+		Paused:    cq.paused,
+		LastError: cq.lastError,
+	}
+}
+
 // Enqueue 将命令添加到队列中（异步）
 func (cq *CommandQueue) Enqueue(cmd Command) {
 	select {
@@ -70,18 +177,81 @@ func (cq *CommandQueue) Enqueue(cmd Command) {
 	}
 }
 
-// Stop 优雅地停止命令队列
+// EnqueueWait 将命令添加到队列中，队列已满时等待空位，队列停止时返回 false
+func (cq *CommandQueue) EnqueueWait(cmd Command) bool {
+	select {
+	case cq.commands <- cmd:
+		return true
+	case <-cq.quit:
+		return false
+	}
+}
+
+// Stop 优雅地停止命令队列：正在执行的命令会完成，排队中的命令不再执行
 func (cq *CommandQueue) Stop() {
 	close(cq.quit) // 发送退出信号给所有 worker
-	// 发送 nil 命令给每个 worker，作为一种更明确的关闭信号（可选，quit channel 通常足够）
-	for i := 0; i < cq.workers; i++ {
-		cq.Enqueue(nil) // 发送 nil 作为关闭信号
-	}
-	cq.wg.Wait()       // 等待所有 worker 结束
-	close(cq.commands) // 关闭命令 channel
+	cq.wg.Wait()   // 等待所有 worker 结束
 	cq.logger.Info("Command Queue stopped gracefully")
 }
 
+// closedGate 返回已关闭的 channel，表示未暂停
+func closedGate() chan struct{} {
+	gate := make(chan struct{})
+	close(gate)
+	return gate
+}
+
+// Shutdown 在 timeout 内执行完排队的命令后停止队列，返回未能执行的命令以便持久化。
+// 暂停状态下不再执行排队的命令；超时后仍在执行的命令不会被打断，由传输状态在下次启动时续传
+func (cq *CommandQueue) Shutdown(timeout time.Duration) []Command {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+drain:
+	for {
+		stats := cq.Stats()
+		if stats.Paused || (stats.Pending == 0 && len(stats.Running) == 0) {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			cq.logger.Warn("等待命令执行完成超时", zap.Int("pending", stats.Pending), zap.Int("running", len(stats.Running)))
+			break drain
+		}
+	}
+
+	close(cq.quit)
+	done := make(chan struct{})
+	go func() {
+		cq.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		cq.logger.Warn("仍有命令在执行，未完成的传输将在下次启动时续传")
+	}
+
+	cq.mutex.Lock()
+	remaining := make([]Command, 0, len(cq.held)+len(cq.commands))
+	for _, cmd := range cq.held {
+		remaining = append(remaining, cmd)
+	}
+	cq.mutex.Unlock()
+	for {
+		select {
+		case cmd := <-cq.commands:
+			remaining = append(remaining, cmd)
+		default:
+			cq.logger.Info("Command Queue stopped gracefully", zap.Int("remaining", len(remaining)))
+			return remaining
+		}
+	}
+}
+
 // GetCommandChannel 获取底层的命令 channel (如果需要从外部发送命令)
 func (cq *CommandQueue) GetCommandChannel() chan<- Command {
 	return cq.commands
@@ -0,0 +1,159 @@
+// client/internal/compress/compress.go
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 支持的压缩编码，名称会记录在服务端的文件元数据中，下载时据此解压
+const (
+	None = ""
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+const (
+	// sampleSize 判断压缩效果时读取的样本大小
+	sampleSize = 256 * 1024
+
+	// maxSampleRatio 样本压缩后与原始大小之比超过该值时认为不值得压缩
+	maxSampleRatio = 0.9
+)
+
+// skipExtensions 本身已压缩的文件类型，再压缩几乎没有收益
+var skipExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".heic": true, ".avif": true,
+	".mp3": true, ".aac": true, ".ogg": true, ".opus": true, ".flac": true, ".m4a": true,
+	".mp4": true, ".mkv": true, ".mov": true, ".avi": true, ".webm": true, ".m4v": true,
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".zst": true, ".7z": true, ".rar": true,
+	".jar": true, ".apk": true, ".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".epub": true,
+	".pdf": true, ".woff": true, ".woff2": true,
+}
+
+// zstdEncoder 的 EncodeAll 可以并发调用，复用以避免为每个分块重复分配
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+// Valid 判断编码名称是否受支持
+func Valid(codec string) bool {
+	return codec == None || codec == Gzip || codec == Zstd
+}
+
+// Choose 决定文件使用的压缩编码：未配置、文件过小、类型本身已压缩或样本压缩效果差时不压缩
+func Choose(path, codec string, minSize int64) (string, error) {
+	if codec == None {
+		return None, nil
+	}
+	if !Valid(codec) {
+		return None, fmt.Errorf("不支持的压缩编码: %s", codec)
+	}
+	if skipExtensions[strings.ToLower(filepath.Ext(path))] {
+		return None, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return None, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return None, err
+	}
+	if info.Size() < minSize {
+		return None, nil
+	}
+	sample := make([]byte, sampleSize)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return None, err
+	}
+	if n == 0 {
+		return None, nil
+	}
+	compressed, err := Bytes(codec, sample[:n])
+	if err != nil {
+		return None, err
+	}
+	if float64(len(compressed)) > float64(n)*maxSampleRatio {
+		return None, nil
+	}
+	return codec, nil
+}
+
+// Bytes 压缩一段数据。相同输入总是得到相同输出，增量同步按分块去重仍然有效
+func Bytes(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case None:
+		return data, nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("不支持的压缩编码: %s", codec)
+	}
+}
+
+// NewWriter 返回压缩写入器，Close 时写出剩余数据但不关闭 dst
+func NewWriter(codec string, dst io.Writer) (io.WriteCloser, error) {
+	switch codec {
+	case None:
+		return nopCloser{dst}, nil
+	case Zstd:
+		return zstd.NewWriter(dst, zstd.WithEncoderConcurrency(1))
+	case Gzip:
+		return gzip.NewWriter(dst), nil
+	default:
+		return nil, fmt.Errorf("不支持的压缩编码: %s", codec)
+	}
+}
+
+// Decode 解压 src 并写入 dst。分块压缩得到的多个连续帧会被依次解压
+func Decode(codec string, dst io.Writer, src io.Reader) error {
+	switch codec {
+	case None:
+		_, err := io.Copy(dst, src)
+		return err
+	case Zstd:
+		r, err := zstd.NewReader(src, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(dst, r)
+		return err
+	case Gzip:
+		r, err := gzip.NewReader(src)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(dst, r)
+		return err
+	default:
+		return fmt.Errorf("不支持的压缩编码: %s", codec)
+	}
+}
+
+// nopCloser 不压缩时的写入器
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,179 @@
+package compress
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// textData 返回容易压缩的重复文本
+func textData(n int) []byte {
+	line := []byte("fsync compresses repetitive text well\n")
+	return bytes.Repeat(line, n/len(line)+1)[:n]
+}
+
+// randomData 返回固定种子生成的无法压缩的数据
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// writeFile 在临时目录中写入测试文件并返回路径
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestChoose(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		codec   string
+		minSize int64
+		want    string
+	}{
+		{"not configured", "a.txt", textData(64 * 1024), None, 0, None},
+		{"compressible", "a.txt", textData(64 * 1024), Zstd, 1024, Zstd},
+		{"gzip", "a.txt", textData(64 * 1024), Gzip, 1024, Gzip},
+		{"below min size", "a.txt", textData(512), Zstd, 1024, None},
+		{"already compressed type", "a.JPG", textData(64 * 1024), Zstd, 0, None},
+		{"incompressible sample", "a.bin", randomData(64 * 1024), Zstd, 0, None},
+		{"empty file", "a.txt", nil, Zstd, 0, None},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Choose(writeFile(t, tt.file, tt.data), tt.codec, tt.minSize)
+			if err != nil {
+				t.Fatalf("Choose: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Choose() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChooseErrors(t *testing.T) {
+	if _, err := Choose(writeFile(t, "a.txt", textData(1024)), "brotli", 0); err == nil {
+		t.Error("Choose accepted an unsupported codec")
+	}
+	if _, err := Choose(filepath.Join(t.TempDir(), "missing.txt"), Zstd, 0); err == nil {
+		t.Error("Choose succeeded on a missing file")
+	}
+}
+
+func TestBytesRoundTrip(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":  {},
+		"text":   textData(300 * 1024),
+		"random": randomData(100 * 1024),
+	}
+	for _, codec := range []string{None, Gzip, Zstd} {
+		for name, data := range inputs {
+			t.Run(codec+"/"+name, func(t *testing.T) {
+				encoded, err := Bytes(codec, data)
+				if err != nil {
+					t.Fatalf("Bytes: %v", err)
+				}
+				// 相同输入必须得到相同输出，否则按分块哈希去重失效
+				again, err := Bytes(codec, data)
+				if err != nil {
+					t.Fatalf("Bytes: %v", err)
+				}
+				if !bytes.Equal(encoded, again) {
+					t.Error("Bytes is not deterministic")
+				}
+				var out bytes.Buffer
+				if err := Decode(codec, &out, bytes.NewReader(encoded)); err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				if !bytes.Equal(out.Bytes(), data) {
+					t.Error("decoded data differs from the input")
+				}
+			})
+		}
+	}
+}
+
+func TestBytesShrinksText(t *testing.T) {
+	data := textData(300 * 1024)
+	for _, codec := range []string{Gzip, Zstd} {
+		encoded, err := Bytes(codec, data)
+		if err != nil {
+			t.Fatalf("%s: Bytes: %v", codec, err)
+		}
+		if len(encoded) >= len(data)/10 {
+			t.Errorf("%s: %d bytes compressed to %d", codec, len(data), len(encoded))
+		}
+	}
+}
+
+func TestDecodeConcatenatedFrames(t *testing.T) {
+	parts := [][]byte{textData(10 * 1024), randomData(5 * 1024), textData(20 * 1024)}
+	for _, codec := range []string{Gzip, Zstd} {
+		t.Run(codec, func(t *testing.T) {
+			// 分块分别压缩后首尾相接，作为一个流解压
+			var stream, want bytes.Buffer
+			for _, p := range parts {
+				encoded, err := Bytes(codec, p)
+				if err != nil {
+					t.Fatalf("Bytes: %v", err)
+				}
+				stream.Write(encoded)
+				want.Write(p)
+			}
+			var out bytes.Buffer
+			if err := Decode(codec, &out, &stream); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), want.Bytes()) {
+				t.Error("decoded data differs from the concatenated input")
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if err := Decode("brotli", &bytes.Buffer{}, bytes.NewReader(nil)); err == nil {
+		t.Error("Decode accepted an unsupported codec")
+	}
+	for _, codec := range []string{Gzip, Zstd} {
+		if err := Decode(codec, &bytes.Buffer{}, bytes.NewReader([]byte("not compressed data"))); err == nil {
+			t.Errorf("%s: Decode accepted corrupt input", codec)
+		}
+	}
+}
+
+func TestWriterMatchesDecode(t *testing.T) {
+	data := textData(200 * 1024)
+	for _, codec := range []string{None, Gzip, Zstd} {
+		t.Run(codec, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(codec, &buf)
+			if err != nil {
+				t.Fatalf("NewWriter: %v", err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			var out bytes.Buffer
+			if err := Decode(codec, &out, &buf); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), data) {
+				t.Error("decoded data differs from the input")
+			}
+		})
+	}
+}
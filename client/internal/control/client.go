@@ -0,0 +1,83 @@
+// client/internal/control/client.go
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Client 通过 Unix 套接字访问运行中客户端的控制接口
+type Client struct {
+	path string
+	http *http.Client
+}
+
+// NewClient 创建连接到 path 的控制接口客户端
+func NewClient(path string) *Client {
+	return &Client{
+		path: path,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Call 调用控制接口，in 为 nil 时不发送请求体，out 为 nil 时忽略返回数据
+func (c *Client) Call(method, route string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	// 主机名只用于构造 URL，实际连接的是 Unix 套接字
+	req, err := http.NewRequest(method, "http://fsync"+route, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return fmt.Errorf("客户端未运行（%s）", c.path)
+		}
+		return fmt.Errorf("连接客户端失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var r Response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("解析控制接口响应失败: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		if r.Error == "" {
+			r.Error = resp.Status
+		}
+		return errors.New(r.Error)
+	}
+	if out != nil && len(r.Data) > 0 {
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			return fmt.Errorf("解析控制接口响应失败: %w", err)
+		}
+	}
+	return nil
+}
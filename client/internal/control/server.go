@@ -0,0 +1,112 @@
+// client/internal/control/server.go
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fsync/client/global"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// socketName 未配置 control_socket 时，控制套接字在同步目录内部数据目录中的文件名
+const socketName = "control.sock"
+
+// Response 控制接口的统一响应
+type Response struct {
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// SocketPath 返回控制套接字路径：优先使用配置，否则放在同步目录的 .fsync 下
+func SocketPath() string {
+	if p := global.Config().Client.ControlSocket; p != "" {
+		return p
+	}
+	return filepath.Join(global.Config().Client.SyncDir, global.MetaDirName, socketName)
+}
+
+// Server 监听 Unix 套接字的本地控制接口，只有当前用户可以连接
+type Server struct {
+	path   string
+	http   *http.Server
+	logger *zap.Logger
+}
+
+// Listen 在 path 上启动控制接口。已有客户端在监听时返回错误，残留的套接字文件会被清理
+func Listen(path string, handler http.Handler, logger *zap.Logger) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("创建控制套接字目录失败: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("已有客户端在运行（%s）", path)
+		}
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("监听控制套接字失败: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("设置控制套接字权限失败: %w", err)
+	}
+
+	s := &Server{
+		path:   path,
+		http:   &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second},
+		logger: logger,
+	}
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("控制接口异常退出", zap.Error(err))
+		}
+	}()
+	logger.Info("控制接口已启动", zap.String("socket", path))
+	return s, nil
+}
+
+// Close 停止控制接口并删除套接字文件，等待正在处理的请求完成
+func (s *Server) Close(ctx context.Context) error {
+	err := s.http.Shutdown(ctx)
+	os.Remove(s.path)
+	return err
+}
+
+// WriteJSON 写出成功响应
+func WriteJSON(w http.ResponseWriter, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Response{Data: raw})
+}
+
+// WriteError 写出错误响应
+func WriteError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Error: err.Error()})
+}
+
+// ReadJSON 解析请求体，请求体为空时保留 v 的零值
+func ReadJSON(r *http.Request, v interface{}) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("解析请求失败: %w", err)
+	}
+	return nil
+}
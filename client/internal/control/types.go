@@ -0,0 +1,63 @@
+// client/internal/control/types.go
+package control
+
+import (
+	"fsync/client/internal/bandwidth"
+	"fsync/client/internal/command"
+	"fsync/client/internal/safety"
+	"fsync/client/internal/transfer"
+	"time"
+)
+
+// 控制接口的路由，服务端和命令行共用
+const (
+	RouteStatus          = "/status"
+	RoutePause           = "/pause"
+	RouteResume          = "/resume"
+	RouteRescan          = "/rescan"
+	RouteUndo            = "/undo"
+	RouteConflicts       = "/conflicts"
+	RouteShutdown        = "/shutdown"
+	RouteDeletes         = "/deletes"
+	RouteDeletesConfirm  = "/deletes/confirm"
+	RouteDeletesDiscard  = "/deletes/discard"
+	RouteBandwidth       = "/bandwidth"
+	RouteBandwidthUpdate = "/bandwidth/update"
+)
+
+// Status 运行中客户端的状态
+type Status struct {
+	SyncDir          string              `json:"sync_dir"`
+	StartedAt        time.Time           `json:"started_at"`
+	LoggedIn         bool                `json:"logged_in"` // 未登录时只监控不上传
+	Queue            command.QueueStats  `json:"queue"`
+	Transfers        []transfer.Progress `json:"transfers"`         // 正在进行的传输
+	PendingTransfers int                 `json:"pending_transfers"` // 中断或被暂缓、等待继续的传输
+	Conflicts        int                 `json:"conflicts"`
+	Deletes          *safety.BrakeStatus `json:"deletes,omitempty"` // 未启用删除保护时为空
+	Bandwidth        bandwidth.Status    `json:"bandwidth"`
+}
+
+// UndoRequest 撤销请求
+type UndoRequest struct {
+	All bool `json:"all"` // 撤销所有记录的命令，否则只撤销最后一个
+}
+
+// RescanResult 重新扫描的结果
+type RescanResult struct {
+	Files int `json:"files"` // 提交上传的文件数量
+}
+
+// DeletesResult 确认或放弃暂停删除的结果
+type DeletesResult struct {
+	Count   int    `json:"count"`             // 放行或放弃的删除数量
+	Skipped int    `json:"skipped,omitempty"` // 确认时文件已重新出现、不再同步的删除数量
+	Error   string `json:"error,omitempty"`   // 部分删除未能放行的原因
+}
+
+// Conflict 本地修改尚未上传就被远端版本覆盖的文件，本地版本保留在回收站中
+type Conflict struct {
+	Path      string    `json:"path"`
+	LocalCopy string    `json:"local_copy"` // 回收站中的本地版本
+	At        time.Time `json:"at"`
+}
@@ -0,0 +1,150 @@
+// client/internal/e2e/content.go
+package e2e
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fsync/pkg/crypto"
+	"io"
+	"os"
+)
+
+// 分块加密记录格式: length(4，大端，不含自身) | nonce(12) | ciphertext+tag。
+// 记录自带长度，服务端拼装的下载流可以按顺序逐条解密
+const (
+	recordHeaderLen = 4
+	chunkNonceLen   = 12
+	maxRecordLen    = 64 * 1024 * 1024
+)
+
+// ContentMAC 计算明文的带密钥 MAC。服务端只能看到这个值而无法由它还原内容，
+// 用于在密文每次都不同的情况下判断内容是否变化
+func (k *Keys) ContentMAC(r io.Reader) (string, error) {
+	h := hmac.New(sha256.New, k.mac)
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ContentMACFile 计算文件明文的带密钥 MAC
+func (k *Keys) ContentMACFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return k.ContentMAC(f)
+}
+
+// aead 返回内容加密使用的 AES-256-GCM
+func (k *Keys) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.content)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealChunk 确定性地加密一个内容分块：nonce 由明文的带密钥 MAC 派生（SIV 方式），
+// 相同明文得到相同密文，服务端按密文哈希去重和增量同步仍然有效，只会暴露两个分块是否相同。
+// nonce 使用独立的子密钥，与提交给服务端的内容 MAC 无关；nonce 随记录保存，旧记录仍可解密
+func (k *Keys) SealChunk(plain []byte) ([]byte, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, k.nonce)
+	h.Write(plain)
+	nonce := h.Sum(nil)[:chunkNonceLen]
+
+	out := make([]byte, recordHeaderLen, recordHeaderLen+chunkNonceLen+len(plain)+aead.Overhead())
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, plain, nil)
+	binary.BigEndian.PutUint32(out[:recordHeaderLen], uint32(len(out)-recordHeaderLen))
+	return out, nil
+}
+
+// openRecord 解密一条分块记录（不含长度前缀）
+func (k *Keys) openRecord(aead cipher.AEAD, record []byte) ([]byte, error) {
+	if len(record) < chunkNonceLen+aead.Overhead() {
+		return nil, fmt.Errorf("加密分块过短")
+	}
+	plain, err := aead.Open(nil, record[:chunkNonceLen], record[chunkNonceLen:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("分块解密失败（密钥错误或内容被篡改）: %w", err)
+	}
+	return plain, nil
+}
+
+// OpenChunk 解密 SealChunk 生成的一条完整记录（含长度前缀）
+func (k *Keys) OpenChunk(sealed []byte) ([]byte, error) {
+	if len(sealed) < recordHeaderLen || int(binary.BigEndian.Uint32(sealed[:recordHeaderLen])) != len(sealed)-recordHeaderLen {
+		return nil, fmt.Errorf("加密分块长度异常")
+	}
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	return k.openRecord(aead, sealed[recordHeaderLen:])
+}
+
+// NewEncryptWriter 返回把明文加密为流式 v2 格式写入 dst 的写入器，用于非增量的上传。
+// Close 写出最后一段但不关闭 dst
+func (k *Keys) NewEncryptWriter(dst io.Writer) (io.WriteCloser, error) {
+	return crypto.NewEncryptWriterWithKey(dst, k.content)
+}
+
+// Decrypt 解密下载得到的密文并写入 dst，自动识别流式 v2 格式和分块记录格式
+func (k *Keys) Decrypt(dst io.Writer, src io.Reader) error {
+	br := bufio.NewReader(src)
+	prefix, _ := br.Peek(8)
+	if crypto.IsStream(prefix) {
+		r, err := crypto.NewDecryptReaderWithKey(br, k.content)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, r)
+		return err
+	}
+	return k.decryptRecords(dst, br)
+}
+
+// decryptRecords 逐条解密分块记录
+func (k *Keys) decryptRecords(dst io.Writer, src io.Reader) error {
+	aead, err := k.aead()
+	if err != nil {
+		return err
+	}
+	header := make([]byte, recordHeaderLen)
+	for {
+		if _, err := io.ReadFull(src, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("读取加密分块失败: %w", err)
+		}
+		n := binary.BigEndian.Uint32(header)
+		if n > maxRecordLen {
+			return fmt.Errorf("加密分块长度异常: %d", n)
+		}
+		record := make([]byte, n)
+		if _, err := io.ReadFull(src, record); err != nil {
+			return fmt.Errorf("读取加密分块失败: %w", err)
+		}
+		plain, err := k.openRecord(aead, record)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// client/internal/e2e/keyfile.go
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"fsync/pkg/crypto"
+	"strings"
+	"time"
+)
+
+// 密钥文件中主密钥的包装方式
+const (
+	WrapPassphrase = "passphrase" // 口令派生的 KEK
+	WrapRecovery   = "recovery"   // 恢复密钥
+	WrapEnroll     = "enroll"     // 新设备加入使用的一次性代码
+
+	keyFileVersion = 1
+	recoveryInfo   = "fsync e2e recovery"
+	enrollInfo     = "fsync e2e enroll"
+
+	recoverySecretLen = 32
+	enrollSecretLen   = 20
+
+	// EnrollTTL 一次性加入代码的有效期
+	EnrollTTL = 10 * time.Minute
+)
+
+// codeEncoding 恢复密钥和加入代码的编码，只含大写字母和数字，便于抄写
+var codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// KeyFile 保存在服务端的密钥文件：同一个主密钥被多种方式分别包装，任意一种都能解开。
+// 服务端看不到任何可以直接解密的内容
+type KeyFile struct {
+	Version int    `json:"version"`
+	Check   string `json:"check"` // 主密钥校验值
+	Wraps   []Wrap `json:"wraps"`
+}
+
+// Wrap 一份被包装的主密钥
+type Wrap struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`   // 恢复密钥和加入代码的标识，由秘密值派生
+	Salt string `json:"salt,omitempty"` // 口令派生 KEK 使用的盐
+	// KDF 口令派生 KEK 使用的 Argon2id 参数，缺省时为 crypto.LegacyKDFParams
+	KDF     *crypto.KDFParams `json:"kdf,omitempty"`
+	Expires int64             `json:"expires,omitempty"` // 加入代码的过期时间（Unix 秒）
+	Nonce   string            `json:"nonce"`
+	Data    string            `json:"data"`
+}
+
+// NewKeyFile 为主密钥创建密钥文件
+func NewKeyFile(master []byte) *KeyFile {
+	return &KeyFile{Version: keyFileVersion, Check: checkValue(master)}
+}
+
+// ParseKeyFile 解析服务端返回的密钥文件
+func ParseKeyFile(data string) (*KeyFile, error) {
+	var kf KeyFile
+	if err := json.Unmarshal([]byte(data), &kf); err != nil {
+		return nil, fmt.Errorf("解析密钥文件失败: %w", err)
+	}
+	if kf.Version != keyFileVersion {
+		return nil, fmt.Errorf("不支持的密钥文件版本: %d", kf.Version)
+	}
+	return &kf, nil
+}
+
+// Encode 序列化密钥文件，顺带清除过期的加入代码
+func (kf *KeyFile) Encode() (string, error) {
+	kf.pruneExpired()
+	data, err := json.Marshal(kf)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// SetPassphrase 用口令和给定的 Argon2id 参数重新包装主密钥，替换原有的口令包装。
+// 主密钥和已加密的数据都不变，因此也可用于提高 KDF 参数
+func (kf *KeyFile) SetPassphrase(master []byte, passphrase string, params crypto.KDFParams) error {
+	if err := kf.verify(master); err != nil {
+		return err
+	}
+	salt, err := crypto.GenerateSalt()
+	if err != nil {
+		return fmt.Errorf("生成盐失败: %w", err)
+	}
+	kek, err := params.DeriveKey([]byte(passphrase), salt, masterKeyLen)
+	if err != nil {
+		return err
+	}
+	w := Wrap{Type: WrapPassphrase, Salt: hex.EncodeToString(salt), KDF: &params}
+	if err := w.seal(kek, master); err != nil {
+		return err
+	}
+	kf.replace(WrapPassphrase, w)
+	return nil
+}
+
+// UnlockPassphrase 用口令解开主密钥
+func (kf *KeyFile) UnlockPassphrase(passphrase string) ([]byte, error) {
+	for _, w := range kf.Wraps {
+		if w.Type != WrapPassphrase {
+			continue
+		}
+		salt, err := hex.DecodeString(w.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("密钥文件已损坏")
+		}
+		params := crypto.LegacyKDFParams
+		if w.KDF != nil {
+			params = *w.KDF
+		}
+		kek, err := params.DeriveKey([]byte(passphrase), salt, masterKeyLen)
+		if err != nil {
+			return nil, fmt.Errorf("密钥文件中的 KDF 参数无效: %w", err)
+		}
+		master, err := w.open(kek)
+		if err != nil {
+			return nil, fmt.Errorf("口令错误")
+		}
+		return master, kf.verify(master)
+	}
+	return nil, fmt.Errorf("密钥文件中没有口令包装")
+}
+
+// NewRecoveryKey 生成新的恢复密钥并替换原有的恢复密钥，返回供用户离线保存的文本
+func (kf *KeyFile) NewRecoveryKey(master []byte) (string, error) {
+	if err := kf.verify(master); err != nil {
+		return "", err
+	}
+	secret := make([]byte, recoverySecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("生成恢复密钥失败: %w", err)
+	}
+	w, err := newSecretWrap(WrapRecovery, recoveryInfo, secret, master)
+	if err != nil {
+		return "", err
+	}
+	kf.replace(WrapRecovery, w)
+	return formatCode(secret), nil
+}
+
+// UnlockRecoveryKey 用恢复密钥解开主密钥
+func (kf *KeyFile) UnlockRecoveryKey(code string) ([]byte, error) {
+	master, _, err := kf.unlockSecret(WrapRecovery, recoveryInfo, code)
+	return master, err
+}
+
+// NewEnrollCode 生成新设备加入用的一次性代码，EnrollTTL 后失效
+func (kf *KeyFile) NewEnrollCode(master []byte) (string, error) {
+	if err := kf.verify(master); err != nil {
+		return "", err
+	}
+	secret := make([]byte, enrollSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("生成加入代码失败: %w", err)
+	}
+	w, err := newSecretWrap(WrapEnroll, enrollInfo, secret, master)
+	if err != nil {
+		return "", err
+	}
+	w.Expires = time.Now().Add(EnrollTTL).Unix()
+	kf.Wraps = append(kf.Wraps, w)
+	return formatCode(secret), nil
+}
+
+// RedeemEnrollCode 用一次性代码解开主密钥，并从密钥文件中移除该代码
+func (kf *KeyFile) RedeemEnrollCode(code string) ([]byte, error) {
+	kf.pruneExpired()
+	master, id, err := kf.unlockSecret(WrapEnroll, enrollInfo, code)
+	if err != nil {
+		return nil, err
+	}
+	kept := kf.Wraps[:0]
+	for _, w := range kf.Wraps {
+		if !(w.Type == WrapEnroll && w.ID == id) {
+			kept = append(kept, w)
+		}
+	}
+	kf.Wraps = kept
+	return master, nil
+}
+
+// Count 返回某种包装的数量
+func (kf *KeyFile) Count(kind string) int {
+	n := 0
+	for _, w := range kf.Wraps {
+		if w.Type == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// unlockSecret 用恢复密钥或加入代码解开主密钥，返回主密钥和包装标识
+func (kf *KeyFile) unlockSecret(kind, info, code string) ([]byte, string, error) {
+	secret, err := parseCode(code)
+	if err != nil {
+		return nil, "", err
+	}
+	id := secretID(secret)
+	for _, w := range kf.Wraps {
+		if w.Type != kind || w.ID != id {
+			continue
+		}
+		kek, err := expand(secret, info)
+		if err != nil {
+			return nil, "", err
+		}
+		master, err := w.open(kek)
+		if err != nil {
+			return nil, "", err
+		}
+		return master, id, kf.verify(master)
+	}
+	return nil, "", fmt.Errorf("代码无效或已过期")
+}
+
+// verify 确认主密钥与密钥文件一致
+func (kf *KeyFile) verify(master []byte) error {
+	if !hmac.Equal([]byte(checkValue(master)), []byte(kf.Check)) {
+		return fmt.Errorf("主密钥与密钥文件不一致")
+	}
+	return nil
+}
+
+// replace 用新包装替换同类型的所有包装
+func (kf *KeyFile) replace(kind string, w Wrap) {
+	kept := kf.Wraps[:0]
+	for _, existing := range kf.Wraps {
+		if existing.Type != kind {
+			kept = append(kept, existing)
+		}
+	}
+	kf.Wraps = append(kept, w)
+}
+
+// pruneExpired 移除已过期的加入代码
+func (kf *KeyFile) pruneExpired() {
+	now := time.Now().Unix()
+	kept := kf.Wraps[:0]
+	for _, w := range kf.Wraps {
+		if w.Type == WrapEnroll && w.Expires <= now {
+			continue
+		}
+		kept = append(kept, w)
+	}
+	kf.Wraps = kept
+}
+
+// newSecretWrap 用高熵秘密值派生的 KEK 包装主密钥
+func newSecretWrap(kind, info string, secret, master []byte) (Wrap, error) {
+	w := Wrap{Type: kind, ID: secretID(secret)}
+	kek, err := expand(secret, info)
+	if err != nil {
+		return w, err
+	}
+	if err := w.seal(kek, master); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+// seal 用 KEK 加密主密钥，包装类型和标识作为附加数据，防止包装被挪作他用
+func (w *Wrap) seal(kek, master []byte) error {
+	aead, err := newWrapAEAD(kek)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	w.Nonce = hex.EncodeToString(nonce)
+	w.Data = hex.EncodeToString(aead.Seal(nil, nonce, master, w.aad()))
+	return nil
+}
+
+// open 用 KEK 解开主密钥
+func (w *Wrap) open(kek []byte) ([]byte, error) {
+	aead, err := newWrapAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err1 := hex.DecodeString(w.Nonce)
+	data, err2 := hex.DecodeString(w.Data)
+	if err1 != nil || err2 != nil || len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("密钥文件已损坏")
+	}
+	master, err := aead.Open(nil, nonce, data, w.aad())
+	if err != nil {
+		return nil, fmt.Errorf("解开主密钥失败")
+	}
+	return master, nil
+}
+
+// aad 返回包装的附加认证数据
+func (w *Wrap) aad() []byte {
+	return []byte("fsync e2e keyfile|" + w.Type + "|" + w.ID + "|" + w.Salt)
+}
+
+// newWrapAEAD 返回包装主密钥使用的 AES-256-GCM
+func newWrapAEAD(kek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// secretID 由秘密值派生公开标识，用于在密钥文件中定位对应的包装
+func secretID(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:4])
+}
+
+// formatCode 将秘密值编码为每 4 个字符一组、以 - 分隔的文本
+func formatCode(secret []byte) string {
+	s := codeEncoding.EncodeToString(secret)
+	groups := make([]string, 0, len(s)/4+1)
+	for len(s) > 4 {
+		groups = append(groups, s[:4])
+		s = s[4:]
+	}
+	groups = append(groups, s)
+	return strings.Join(groups, "-")
+}
+
+// parseCode 解析用户输入的代码，忽略分隔符、空白和大小写
+func parseCode(code string) ([]byte, error) {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "", "\t", "").Replace(strings.TrimSpace(code)))
+	secret, err := codeEncoding.DecodeString(code)
+	if err != nil || len(secret) < enrollSecretLen {
+		return nil, fmt.Errorf("代码格式错误")
+	}
+	return secret, nil
+}
@@ -0,0 +1,215 @@
+// client/internal/e2e/keys.go
+package e2e
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"fsync/client/global"
+	"fsync/pkg/crypto"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// 主密钥派生子密钥时使用的 HKDF info，修改会导致已加密的数据无法解密
+const (
+	contentKeyInfo = "fsync e2e content"
+	macKeyInfo     = "fsync e2e mac"
+	nonceKeyInfo   = "fsync e2e chunk nonce"
+	checkKeyInfo   = "fsync e2e check"
+	nameEncInfo    = "fsync e2e name enc"
+	nameMACInfo    = "fsync e2e name mac"
+
+	masterKeyLen = 32
+
+	// DefaultRootID 未配置 client.encryption.root_id 时使用的根目录标识
+	DefaultRootID = "default"
+
+	// legacyKeyFile 早期版本不区分根目录时缓存主密钥的文件名，迁移到 DefaultRootID
+	legacyKeyFile = "e2e.key"
+
+	// legacyDescriptorFile 早期版本直接由口令派生密钥时写在同步目录中的描述文件
+	legacyDescriptorFile = "e2e.json"
+)
+
+// legacyDescriptor 早期版本的加密描述，迁移时用于校验口令并沿用原密钥
+type legacyDescriptor struct {
+	Version int    `json:"version"`
+	Salt    string `json:"salt"`
+	Check   string `json:"check"`
+}
+
+// Keys 由主密钥派生的子密钥
+type Keys struct {
+	content []byte // 文件内容加密
+	mac     []byte // 明文的带密钥 MAC，用于去重和冲突判断
+	nonce   []byte // 分块加密的合成 nonce，与内容 MAC 使用不同的密钥，MAC 值不会泄露 nonce
+	nameEnc []byte // 文件名加密
+	nameMAC []byte // 文件名合成 IV
+}
+
+// rootIDPattern 根目录标识的格式，用于文件名和请求参数
+var rootIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,63}$`)
+
+// ValidRootID 判断根目录标识是否合法
+func ValidRootID(id string) bool {
+	return rootIDPattern.MatchString(id)
+}
+
+// RootID 返回配置的加密根目录标识
+func RootID() string {
+	if id := global.Config().Client.Encryption.RootID; id != "" {
+		return id
+	}
+	return DefaultRootID
+}
+
+// keyPath 返回本地缓存某个根目录主密钥的文件路径
+func keyPath(rootID string) string {
+	return filepath.Join(global.Config().Client.TokenDir, "e2e-"+rootID+".key")
+}
+
+// NewMasterKey 生成随机主密钥。主密钥在同步目录的生命周期内不变，更换口令只需重新包装
+func NewMasterKey() ([]byte, error) {
+	master := make([]byte, masterKeyLen)
+	if _, err := rand.Read(master); err != nil {
+		return nil, fmt.Errorf("生成主密钥失败: %w", err)
+	}
+	return master, nil
+}
+
+// SaveMasterKey 将根目录的主密钥缓存在 TokenDir，仅本机用户可读。
+// 不同根目录的主密钥分别缓存，解锁一个根目录不会覆盖另一个
+func SaveMasterKey(rootID string, master []byte) error {
+	if !ValidRootID(rootID) {
+		return fmt.Errorf("无效的根目录标识: %q", rootID)
+	}
+	if err := os.MkdirAll(global.Config().Client.TokenDir, 0700); err != nil {
+		return fmt.Errorf("创建令牌目录失败: %w", err)
+	}
+	if err := os.WriteFile(keyPath(rootID), []byte(hex.EncodeToString(master)), 0600); err != nil {
+		return fmt.Errorf("保存主密钥失败: %w", err)
+	}
+	return nil
+}
+
+// LoadMasterKey 读取本机缓存的根目录主密钥。早期版本缓存的主密钥迁移为 DefaultRootID 的
+func LoadMasterKey(rootID string) ([]byte, error) {
+	if !ValidRootID(rootID) {
+		return nil, fmt.Errorf("无效的根目录标识: %q", rootID)
+	}
+	path := keyPath(rootID)
+	legacy := filepath.Join(global.Config().Client.TokenDir, legacyKeyFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) && rootID == DefaultRootID {
+		os.Rename(legacy, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取主密钥失败（请先执行 encryption init 或 encryption join）: %w", err)
+	}
+	master, err := hex.DecodeString(string(data))
+	if err != nil || len(master) != masterKeyLen {
+		return nil, fmt.Errorf("主密钥文件已损坏")
+	}
+	return master, nil
+}
+
+// RemoveMasterKey 删除本机缓存的根目录主密钥
+func RemoveMasterKey(rootID string) error {
+	if !ValidRootID(rootID) {
+		return fmt.Errorf("无效的根目录标识: %q", rootID)
+	}
+	if err := os.Remove(keyPath(rootID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// LoadKeys 读取本机缓存的根目录主密钥并派生子密钥
+func LoadKeys(rootID string) (*Keys, error) {
+	master, err := LoadMasterKey(rootID)
+	if err != nil {
+		return nil, err
+	}
+	return deriveKeys(master)
+}
+
+// LegacyMasterKey 若同步目录由早期版本初始化（密钥直接由口令派生），校验口令并返回原密钥，
+// 迁移后继续作为主密钥使用，已上传的数据无需重新加密。不存在旧描述时返回 nil
+func LegacyMasterKey(root, passphrase string) ([]byte, error) {
+	data, err := os.ReadFile(legacyDescriptorPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取旧版加密描述失败: %w", err)
+	}
+	var desc legacyDescriptor
+	if err := json.Unmarshal(data, &desc); err != nil {
+		return nil, fmt.Errorf("解析旧版加密描述失败: %w", err)
+	}
+	salt, err := hex.DecodeString(desc.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("旧版加密描述已损坏")
+	}
+	master := crypto.ReDeriveKey(passphrase, salt, masterKeyLen)
+	if !hmac.Equal([]byte(checkValue(master)), []byte(desc.Check)) {
+		return nil, fmt.Errorf("口令与旧版加密配置不一致")
+	}
+	return master, nil
+}
+
+// RemoveLegacyDescriptor 迁移完成后删除旧版描述文件
+func RemoveLegacyDescriptor(root string) {
+	os.Remove(legacyDescriptorPath(root))
+}
+
+// legacyDescriptorPath 返回旧版描述文件路径
+func legacyDescriptorPath(root string) string {
+	return filepath.Join(root, global.MetaDirName, legacyDescriptorFile)
+}
+
+// deriveKeys 由主密钥派生各用途的子密钥
+func deriveKeys(master []byte) (*Keys, error) {
+	var keys Keys
+	for _, sub := range []struct {
+		dst  *[]byte
+		info string
+	}{
+		{&keys.content, contentKeyInfo},
+		{&keys.mac, macKeyInfo},
+		{&keys.nonce, nonceKeyInfo},
+		{&keys.nameEnc, nameEncInfo},
+		{&keys.nameMAC, nameMACInfo},
+	} {
+		key, err := expand(master, sub.info)
+		if err != nil {
+			return nil, err
+		}
+		*sub.dst = key
+	}
+	return &keys, nil
+}
+
+// expand 使用 HKDF 从输入密钥派生 32 字节子密钥
+func expand(secret []byte, info string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(info)), key); err != nil {
+		return nil, fmt.Errorf("派生密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// checkValue 计算主密钥的校验值，用于确认解包得到的是同一个主密钥
+func checkValue(master []byte) string {
+	key, _ := expand(master, checkKeyInfo)
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
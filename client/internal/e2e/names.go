@@ -0,0 +1,105 @@
+// client/internal/e2e/names.go
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// 文件名加密采用 SIV 构造：合成 IV = HMAC(名称 MAC 密钥, 明文)[:16]，再以 AES-CTR 加密。
+// 相同的名称总是得到相同的密文，服务端可以按加密后的路径查找、列举和移动，
+// 但只能看出两个名称是否相同；IV 同时作为认证标签，篡改后的名称无法解密
+const (
+	nameIVLen = aes.BlockSize
+
+	// maxNameLen 明文名称的最大长度，保证加密编码后不超过常见文件系统 255 字节的限制
+	maxNameLen = 160
+)
+
+// nameEncoding 加密名称的编码，不含 / 且区分大小写
+var nameEncoding = base64.RawURLEncoding
+
+// EncryptName 确定性地加密单个路径段
+func (k *Keys) EncryptName(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", fmt.Errorf("无效的文件名: %q", name)
+	}
+	if len(name) > maxNameLen {
+		return "", fmt.Errorf("文件名过长，加密后将超出限制: %q", name)
+	}
+	iv := k.nameIV([]byte(name))
+	stream, err := k.nameStream(iv)
+	if err != nil {
+		return "", err
+	}
+	out := make([]byte, nameIVLen+len(name))
+	copy(out, iv)
+	stream.XORKeyStream(out[nameIVLen:], []byte(name))
+	return nameEncoding.EncodeToString(out), nil
+}
+
+// DecryptName 解密单个路径段并校验完整性
+func (k *Keys) DecryptName(encrypted string) (string, error) {
+	data, err := nameEncoding.DecodeString(encrypted)
+	if err != nil || len(data) <= nameIVLen {
+		return "", fmt.Errorf("不是加密的文件名: %q", encrypted)
+	}
+	iv := data[:nameIVLen]
+	stream, err := k.nameStream(iv)
+	if err != nil {
+		return "", err
+	}
+	plain := make([]byte, len(data)-nameIVLen)
+	stream.XORKeyStream(plain, data[nameIVLen:])
+	if !hmac.Equal(iv, k.nameIV(plain)) {
+		return "", fmt.Errorf("文件名解密失败（密钥错误或名称被篡改）: %q", encrypted)
+	}
+	return string(plain), nil
+}
+
+// EncryptPath 逐段加密以 / 分隔的相对路径，目录结构保持不变
+func (k *Keys) EncryptPath(p string) (string, error) {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		enc, err := k.EncryptName(s)
+		if err != nil {
+			return "", err
+		}
+		segments[i] = enc
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// DecryptPath 逐段解密以 / 分隔的加密路径
+func (k *Keys) DecryptPath(p string) (string, error) {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		plain, err := k.DecryptName(s)
+		if err != nil {
+			return "", err
+		}
+		segments[i] = plain
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// nameIV 计算名称的合成 IV
+func (k *Keys) nameIV(name []byte) []byte {
+	h := hmac.New(sha256.New, k.nameMAC)
+	h.Write(name)
+	return h.Sum(nil)[:nameIVLen]
+}
+
+// nameStream 返回名称加密使用的 AES-CTR 流
+func (k *Keys) nameStream(iv []byte) (cipher.Stream, error) {
+	block, err := aes.NewCipher(k.nameEnc)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewCTR(block, iv), nil
+}
@@ -0,0 +1,57 @@
+// client/internal/ignore/ignore.go
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Matcher 忽略规则。模式语法同 path.Match：不含 / 的模式匹配任意层级的文件或目录名，
+// 含 / 的模式匹配相对同步根目录的路径；目录被忽略时其下所有文件都被忽略
+type Matcher struct {
+	patterns []string
+}
+
+// New 创建忽略规则，模式语法错误时返回错误
+func New(patterns []string) (*Matcher, error) {
+	m := &Matcher{patterns: make([]string, 0, len(patterns))}
+	for _, p := range patterns {
+		p = strings.Trim(strings.TrimSpace(filepath.ToSlash(p)), "/")
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("无效的忽略规则 %q: %w", p, err)
+		}
+		m.patterns = append(m.patterns, p)
+	}
+	return m, nil
+}
+
+// Match 判断相对同步根目录的路径是否被忽略
+func (m *Matcher) Match(rel string) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	parts := strings.Split(rel, "/")
+	for _, p := range m.patterns {
+		if strings.Contains(p, "/") {
+			// 依次匹配路径的每一级前缀，匹配到的目录下的文件同样被忽略
+			for i := range parts {
+				if ok, _ := path.Match(p, strings.Join(parts[:i+1], "/")); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for _, part := range parts {
+			if ok, _ := path.Match(p, part); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
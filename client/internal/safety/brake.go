@@ -0,0 +1,292 @@
+// client/internal/safety/brake.go
+package safety
+
+import (
+	"fmt"
+	"fsync/client/global"
+	"fsync/client/internal/command"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// minPercentDeletes 删除数量低于该值时不按百分比触发，避免小目录中误报
+const minPercentDeletes = 10
+
+// BrakeStatus 删除保护的当前状态
+type BrakeStatus struct {
+	Tripped     bool      `json:"tripped"`      // 是否已触发，待确认
+	RootLost    bool      `json:"root_lost"`    // 同步根目录是否已消失
+	HeldDeletes int       `json:"held_deletes"` // 暂停中的删除命令数量
+	Tracked     int       `json:"tracked"`      // 当前跟踪的文件数量
+	TrippedAt   time.Time `json:"tripped_at,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// DeleteBrake 大量删除保护：时间窗口内的删除超过阈值时暂停上传删除，等待用户确认
+type DeleteBrake struct {
+	root       string
+	window     time.Duration
+	maxCount   int
+	maxPercent float64
+	release    func(command.Command) bool
+	logger     *zap.Logger
+
+	mutex     sync.Mutex
+	tracked   int
+	recent    []time.Time
+	held      []command.Command
+	tripped   bool
+	rootLost  bool
+	trippedAt time.Time
+	reason    string
+}
+
+// NewDeleteBrake 创建删除保护，release 用于放行删除命令，返回 false 表示命令未能进入队列
+func NewDeleteBrake(root string, window time.Duration, maxCount int, maxPercent float64, release func(command.Command) bool, logger *zap.Logger) *DeleteBrake {
+	return &DeleteBrake{
+		root:       root,
+		window:     window,
+		maxCount:   maxCount,
+		maxPercent: maxPercent,
+		release:    release,
+		logger:     logger,
+	}
+}
+
+// CountTracked 扫描同步目录，统计当前跟踪的文件数量
+func (b *DeleteBrake) CountTracked() error {
+	count := 0
+	err := filepath.WalkDir(b.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == global.MetaDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("统计同步目录文件失败: %w", err)
+	}
+
+	b.mutex.Lock()
+	b.tracked = count
+	b.mutex.Unlock()
+	return nil
+}
+
+// FileAdded 记录新增的文件
+func (b *DeleteBrake) FileAdded() {
+	b.mutex.Lock()
+	b.tracked++
+	b.mutex.Unlock()
+}
+
+// RootLost 标记同步根目录已消失（被删除、移走或所在磁盘被卸载）
+func (b *DeleteBrake) RootLost() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.markRootLost()
+}
+
+// WatchRoot 周期性检查同步根目录是否仍然可用，直到 quit 关闭
+func (b *DeleteBrake) WatchRoot(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := os.Stat(b.root); err != nil {
+				b.RootLost()
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Submit 提交删除命令：未触发保护时直接放行，否则暂停等待确认
+func (b *DeleteBrake) Submit(cmd command.Command) {
+	if b.admit(cmd) {
+		b.release(cmd)
+	}
+}
+
+// Hold 直接暂停删除命令并触发保护，用于恢复上次退出时仍在等待确认的删除
+func (b *DeleteBrake) Hold(cmd command.Command, reason string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.held = append(b.held, cmd)
+	if !b.tripped {
+		b.trip(reason)
+	}
+}
+
+// Held 返回暂停中的删除命令，退出时随命令队列一起保存
+func (b *DeleteBrake) Held() []command.Command {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]command.Command(nil), b.held...)
+}
+
+// admit 判断删除命令能否放行，不能放行时将其暂停。放行由调用方在释放锁之后进行
+func (b *DeleteBrake) admit(cmd command.Command) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, err := os.Stat(b.root); err != nil {
+		b.markRootLost()
+	}
+	if b.rootLost || b.tripped {
+		b.held = append(b.held, cmd)
+		return false
+	}
+
+	now := time.Now()
+	kept := b.recent[:0]
+	for _, at := range b.recent {
+		if now.Sub(at) <= b.window {
+			kept = append(kept, at)
+		}
+	}
+	b.recent = append(kept, now)
+
+	deletes := len(b.recent)
+	if b.maxCount > 0 && deletes > b.maxCount {
+		b.trip(fmt.Sprintf("%s 内删除了 %d 个文件，超过上限 %d", b.window, deletes, b.maxCount))
+		b.held = append(b.held, cmd)
+		return false
+	}
+	// 以窗口开始时的文件数为基准计算比例
+	base := b.tracked + deletes - 1
+	if b.maxPercent > 0 && deletes >= minPercentDeletes && base > 0 {
+		percent := float64(deletes) * 100 / float64(base)
+		if percent > b.maxPercent {
+			b.trip(fmt.Sprintf("%s 内删除了 %.1f%% 的文件，超过上限 %.1f%%", b.window, percent, b.maxPercent))
+			b.held = append(b.held, cmd)
+			return false
+		}
+	}
+
+	b.tracked--
+	return true
+}
+
+// Confirm 用户确认删除：放行暂停的删除命令并解除保护，返回放行数量和因文件重新出现而跳过的数量。
+// 根目录恢复（如重新挂载）后被暂停删除的文件可能又出现在本地，这些删除不再同步。
+// 放行在释放锁之后进行，队列已满时 release 等待空位也不会卡住 Submit 和 Status
+func (b *DeleteBrake) Confirm() (released, skipped int, err error) {
+	b.mutex.Lock()
+	if _, err := os.Stat(b.root); err != nil {
+		b.markRootLost()
+		b.mutex.Unlock()
+		return 0, 0, fmt.Errorf("同步根目录不可用，拒绝将其视为删除全部文件: %s", b.root)
+	}
+	b.rootLost = false
+	held := b.held
+	b.reset()
+	b.mutex.Unlock()
+
+	var unreleased []command.Command
+	for i, cmd := range held {
+		if path, ok := commandPath(cmd); ok {
+			if _, err := os.Lstat(path); err == nil {
+				b.logger.Info("文件已重新出现，不再同步删除", zap.String("file", path))
+				skipped++
+				continue
+			}
+		}
+		if !b.release(cmd) {
+			unreleased = held[i:]
+			break
+		}
+		released++
+	}
+
+	b.mutex.Lock()
+	b.tracked -= released
+	if b.tracked < 0 {
+		b.tracked = 0
+	}
+	// 命令队列已停止时未放行的删除继续暂停，退出时随队列一起保存
+	b.held = append(unreleased, b.held...)
+	b.mutex.Unlock()
+
+	b.logger.Warn("用户确认删除，已放行暂停的删除命令",
+		zap.Int("released", released), zap.Int("skipped", skipped), zap.Int("unreleased", len(unreleased)))
+	if len(unreleased) > 0 {
+		return released, skipped, fmt.Errorf("命令队列已停止，%d 个删除未放行", len(unreleased))
+	}
+	return released, skipped, nil
+}
+
+// Discard 放弃暂停的删除命令，这些删除不会同步到服务器，返回丢弃数量
+func (b *DeleteBrake) Discard() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	count := len(b.held)
+	b.reset()
+	b.logger.Warn("用户放弃删除，暂停的删除命令已丢弃", zap.Int("count", count))
+	return count
+}
+
+// Status 返回当前保护状态
+func (b *DeleteBrake) Status() BrakeStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return BrakeStatus{
+		Tripped:     b.tripped,
+		RootLost:    b.rootLost,
+		HeldDeletes: len(b.held),
+		Tracked:     b.tracked,
+		TrippedAt:   b.trippedAt,
+		Reason:      b.reason,
+	}
+}
+
+// trip 触发保护，调用方需持有锁
+func (b *DeleteBrake) trip(reason string) {
+	b.tripped = true
+	b.trippedAt = time.Now()
+	b.reason = reason
+	b.logger.Warn("检测到大量删除，已暂停同步删除，请使用 `deletes confirm` 确认或 `deletes discard` 放弃",
+		zap.String("reason", reason))
+}
+
+// markRootLost 标记根目录消失，调用方需持有锁
+func (b *DeleteBrake) markRootLost() {
+	if b.rootLost {
+		return
+	}
+	b.rootLost = true
+	b.trip("同步根目录已消失")
+	b.logger.Error("同步根目录不可用，暂停所有删除，不会将其视为删除全部文件", zap.String("root", b.root))
+}
+
+// commandPath 返回文件命令操作的本地路径
+func commandPath(cmd command.Command) (string, bool) {
+	fc, ok := cmd.(*command.FileCommand)
+	if !ok {
+		return "", false
+	}
+	return fc.FilePath, true
+}
+
+// reset 清空暂停的命令与触发状态，调用方需持有锁
+func (b *DeleteBrake) reset() {
+	b.held = nil
+	b.recent = nil
+	b.tripped = false
+	b.trippedAt = time.Time{}
+	b.reason = ""
+}
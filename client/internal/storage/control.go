@@ -0,0 +1,202 @@
+// client/internal/storage/control.go
+package storage
+
+import (
+	"fmt"
+	"fsync/client/global"
+	"fsync/client/internal/bandwidth"
+	"fsync/client/internal/command"
+	"fsync/client/internal/control"
+	"fsync/client/internal/watcher"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxConflicts 内存中保留的冲突记录上限，超出时丢弃最旧的
+const maxConflicts = 200
+
+var (
+	conflicts      []control.Conflict
+	conflictsMutex sync.Mutex
+)
+
+// newControlHandler 创建本地控制接口的路由
+func newControlHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+control.RouteStatus, handleStatus)
+	mux.HandleFunc("POST "+control.RoutePause, func(w http.ResponseWriter, r *http.Request) {
+		commandManager.CommandQueue.Pause()
+		control.WriteJSON(w, commandManager.CommandQueue.Stats())
+	})
+	mux.HandleFunc("POST "+control.RouteResume, func(w http.ResponseWriter, r *http.Request) {
+		commandManager.CommandQueue.Resume()
+		control.WriteJSON(w, commandManager.CommandQueue.Stats())
+	})
+	mux.HandleFunc("POST "+control.RouteRescan, handleRescan)
+	mux.HandleFunc("POST "+control.RouteUndo, handleUndo)
+	mux.HandleFunc("GET "+control.RouteConflicts, func(w http.ResponseWriter, r *http.Request) {
+		control.WriteJSON(w, listConflicts())
+	})
+	mux.HandleFunc("POST "+control.RouteShutdown, func(w http.ResponseWriter, r *http.Request) {
+		global.Logger.Info("收到控制接口的退出请求")
+		control.WriteJSON(w, nil)
+		requestShutdown()
+	})
+	mux.HandleFunc("GET "+control.RouteDeletes, func(w http.ResponseWriter, r *http.Request) {
+		if deleteBrake == nil {
+			control.WriteError(w, http.StatusNotFound, fmt.Errorf("删除保护未启用"))
+			return
+		}
+		control.WriteJSON(w, deleteBrake.Status())
+	})
+	mux.HandleFunc("POST "+control.RouteDeletesConfirm, func(w http.ResponseWriter, r *http.Request) {
+		if deleteBrake == nil {
+			control.WriteError(w, http.StatusNotFound, fmt.Errorf("删除保护未启用"))
+			return
+		}
+		released, skipped, err := deleteBrake.Confirm()
+		if err != nil && released == 0 && skipped == 0 {
+			control.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		result := control.DeletesResult{Count: released, Skipped: skipped}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		control.WriteJSON(w, result)
+	})
+	mux.HandleFunc("POST "+control.RouteDeletesDiscard, func(w http.ResponseWriter, r *http.Request) {
+		if deleteBrake == nil {
+			control.WriteError(w, http.StatusNotFound, fmt.Errorf("删除保护未启用"))
+			return
+		}
+		control.WriteJSON(w, control.DeletesResult{Count: deleteBrake.Discard()})
+	})
+	mux.HandleFunc("GET "+control.RouteBandwidth, func(w http.ResponseWriter, r *http.Request) {
+		control.WriteJSON(w, bandwidthCtl.Status())
+	})
+	mux.HandleFunc("POST "+control.RouteBandwidthUpdate, func(w http.ResponseWriter, r *http.Request) {
+		var req bandwidth.Request
+		if err := control.ReadJSON(r, &req); err != nil {
+			control.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		control.WriteJSON(w, bandwidthCtl.Update(req))
+	})
+	return mux
+}
+
+// handleStatus 返回客户端的运行状态
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := control.Status{
+		SyncDir:   syncRoot,
+		StartedAt: startedAt,
+		LoggedIn:  transferManager != nil,
+		Queue:     commandManager.CommandQueue.Stats(),
+		Conflicts: len(listConflicts()),
+		Bandwidth: bandwidthCtl.Status(),
+	}
+	if transferManager != nil {
+		status.Transfers = transferManager.Active()
+		status.PendingTransfers = transferManager.PendingCount()
+	}
+	if deleteBrake != nil {
+		brake := deleteBrake.Status()
+		status.Deletes = &brake
+	}
+	control.WriteJSON(w, status)
+}
+
+// handleRescan 遍历同步目录并为每个文件提交上传，补上监控遗漏（如队列已满时丢弃）的变更
+func handleRescan(w http.ResponseWriter, r *http.Request) {
+	if transferManager == nil {
+		control.WriteError(w, http.StatusConflict, fmt.Errorf("尚未登录，无法上传"))
+		return
+	}
+	files := make([]string, 0)
+	err := filepath.WalkDir(syncRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == global.MetaDirName || (path != syncRoot && isIgnored(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isIgnored(path) {
+			return nil
+		}
+		if d.Type().IsRegular() && !watcher.IsMetaPath(syncRoot, path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		control.WriteError(w, http.StatusInternalServerError, fmt.Errorf("扫描同步目录失败: %w", err))
+		return
+	}
+
+	global.Logger.Info("重新扫描同步目录", zap.Int("files", len(files)))
+	go transferManager.ResumePending(ApplyRemoteWrite)
+	// 队列容量有限，在后台逐个等待空位提交，避免像监控事件那样在队列满时丢弃
+	go func() {
+		for _, path := range files {
+			ok := commandManager.AddCommandWait(&command.FileCommand{
+				Action:      "write",
+				FilePath:    path,
+				Description: fmt.Sprintf("重新扫描: %s", path),
+				Logger:      global.Logger,
+				Transfer:    transferManager,
+			})
+			if !ok {
+				return
+			}
+		}
+	}()
+	control.WriteJSON(w, control.RescanResult{Files: len(files)})
+}
+
+// handleUndo 撤销最后一个或所有命令
+func handleUndo(w http.ResponseWriter, r *http.Request) {
+	var req control.UndoRequest
+	if err := control.ReadJSON(r, &req); err != nil {
+		control.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	var err error
+	if req.All {
+		err = UndoAllActions()
+	} else {
+		err = UndoLastAction()
+	}
+	if err != nil {
+		control.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	control.WriteJSON(w, nil)
+}
+
+// recordConflict 记录一个被远端版本覆盖的未同步本地修改
+func recordConflict(path, localCopy string) {
+	conflictsMutex.Lock()
+	defer conflictsMutex.Unlock()
+	global.Logger.Warn("本地修改尚未上传就被远端版本覆盖，本地版本已移入回收站",
+		zap.String("file", path), zap.String("local_copy", localCopy))
+	conflicts = append(conflicts, control.Conflict{Path: path, LocalCopy: localCopy, At: time.Now()})
+	if len(conflicts) > maxConflicts {
+		conflicts = conflicts[len(conflicts)-maxConflicts:]
+	}
+}
+
+// listConflicts 返回记录的冲突
+func listConflicts() []control.Conflict {
+	conflictsMutex.Lock()
+	defer conflictsMutex.Unlock()
+	return append([]control.Conflict{}, conflicts...)
+}
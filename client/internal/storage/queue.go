@@ -0,0 +1,108 @@
+// client/internal/storage/queue.go
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"fsync/client/global"
+	"fsync/client/internal/command"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// queueFile 退出时未执行的命令保存在同步目录内部数据目录中的文件名
+const queueFile = "queue.json"
+
+// queuedCommand 持久化的文件命令
+type queuedCommand struct {
+	Action      string `json:"action"`
+	FilePath    string `json:"file_path"`
+	Description string `json:"description"`
+	Held        bool   `json:"held,omitempty"` // 被删除保护暂停、等待用户确认的删除
+}
+
+// saveQueue 保存退出时未执行的命令和删除保护暂停的删除，没有命令时删除旧文件
+func saveQueue(root string, cmds, held []command.Command) error {
+	path := filepath.Join(root, global.MetaDirName, queueFile)
+	queued := make([]queuedCommand, 0, len(cmds)+len(held))
+	add := func(cmd command.Command, isHeld bool) {
+		if fc, ok := cmd.(*command.FileCommand); ok {
+			queued = append(queued, queuedCommand{Action: fc.Action, FilePath: fc.FilePath, Description: fc.Description, Held: isHeld})
+		}
+	}
+	for _, cmd := range cmds {
+		add(cmd, false)
+	}
+	for _, cmd := range held {
+		add(cmd, true)
+	}
+	if len(queued) == 0 {
+		os.Remove(path)
+		return nil
+	}
+	data, err := json.Marshal(queued)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入命令队列失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	global.Logger.Info("未执行的命令已保存，下次启动时继续", zap.Int("count", len(queued)))
+	return nil
+}
+
+// restoreQueue 在后台重新提交上次退出时保存的命令。删除命令先检查文件是否已在重启前重新出现，
+// 再交给删除保护：上次暂停的删除继续等待确认，其余删除重新参与计数
+func restoreQueue(root string) error {
+	path := filepath.Join(root, global.MetaDirName, queueFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取命令队列失败: %w", err)
+	}
+	os.Remove(path)
+	var queued []queuedCommand
+	if err := json.Unmarshal(data, &queued); err != nil {
+		return fmt.Errorf("解析命令队列失败: %w", err)
+	}
+
+	global.Logger.Info("恢复上次未执行的命令", zap.Int("count", len(queued)))
+	brake, manager, tm := deleteBrake, commandManager, transferManager
+	go func() {
+		for _, q := range queued {
+			cmd := &command.FileCommand{
+				Action:      q.Action,
+				FilePath:    q.FilePath,
+				Description: q.Description,
+				Logger:      global.Logger,
+				Transfer:    tm,
+			}
+			if q.Action == "remove" {
+				if _, err := os.Lstat(q.FilePath); err == nil {
+					global.Logger.Info("文件已重新出现，不再同步删除", zap.String("file", q.FilePath))
+					continue
+				}
+				if brake != nil {
+					if q.Held {
+						brake.Hold(cmd, "上次退出时仍有待确认的删除")
+					} else {
+						brake.Submit(cmd)
+					}
+					continue
+				}
+			}
+			if !manager.AddCommandWait(cmd) {
+				return
+			}
+		}
+	}()
+	return nil
+}
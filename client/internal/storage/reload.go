@@ -0,0 +1,109 @@
+// client/internal/storage/reload.go
+package storage
+
+import (
+	"fmt"
+	"fsync/client/global"
+	"fsync/client/internal/api"
+	"fsync/client/internal/e2e"
+	"fsync/client/internal/ignore"
+	"fsync/client/models"
+	"fsync/pkg/utils"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ignoreRules 当前生效的忽略规则，重新加载配置时整体替换
+var ignoreRules atomic.Pointer[ignore.Matcher]
+
+// setIgnore 替换忽略规则
+func setIgnore(patterns []string) error {
+	m, err := ignore.New(patterns)
+	if err != nil {
+		return err
+	}
+	ignoreRules.Store(m)
+	return nil
+}
+
+// isIgnored 判断同步目录中的路径是否被忽略规则排除
+func isIgnored(path string) bool {
+	rel, err := filepath.Rel(syncRoot, path)
+	if err != nil {
+		return false
+	}
+	return ignoreRules.Load().Match(rel)
+}
+
+// ApplyLive 应用发生变化的配置项，调用前新配置已通过 global.SetConfig 发布，previous 为之前的配置。
+// 同步目录变化时按退出流程停止当前同步（排队的命令保存到原同步目录）再在新目录启动；
+// 新目录无法使用时不停止当前同步，启动失败时恢复之前的配置并在原目录重新启动
+func ApplyLive(previous *models.Config, changed []string, timeout time.Duration) error {
+	cfg := global.Config().Client
+	for _, key := range changed {
+		if utils.KeyMatches(key, []string{"client.sync_dir"}) {
+			return switchSyncDir(previous, timeout)
+		}
+	}
+	for _, key := range changed {
+		switch {
+		case utils.KeyMatches(key, []string{"client.ignore"}):
+			if err := setIgnore(cfg.Ignore); err != nil {
+				return err
+			}
+			global.Logger.Info("已更新忽略规则", zap.Strings("patterns", cfg.Ignore))
+		case utils.KeyMatches(key, []string{"client.bandwidth"}):
+			if bandwidthCtl != nil {
+				if err := bandwidthCtl.Reconfigure(cfg.Bandwidth); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// switchSyncDir 停止当前同步并在新同步目录启动，失败时回退到 previous
+func switchSyncDir(previous *models.Config, timeout time.Duration) error {
+	cfg := global.Config().Client
+	if err := checkSyncDir(cfg); err != nil {
+		global.SetConfig(previous)
+		return fmt.Errorf("新同步目录无法使用，继续同步原目录: %w", err)
+	}
+
+	global.Logger.Info("同步目录已变化，重新启动同步", zap.String("from", syncRoot), zap.String("to", cfg.SyncDir))
+	StopFileSync(timeout)
+	err := StartFileSync()
+	if err == nil {
+		return nil
+	}
+	global.Logger.Error("在新同步目录启动同步失败，恢复之前的配置", zap.Error(err))
+	global.SetConfig(previous)
+	if restartErr := StartFileSync(); restartErr != nil {
+		return fmt.Errorf("在新同步目录启动失败: %w；恢复原同步目录也失败: %v", err, restartErr)
+	}
+	return fmt.Errorf("在新同步目录启动失败，已恢复原同步目录: %w", err)
+}
+
+// checkSyncDir 在停止当前同步之前检查新同步目录能否启动：目录存在，启用端到端加密且已登录时密钥可用
+func checkSyncDir(cfg models.ClientConfig) error {
+	info, err := os.Stat(cfg.SyncDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("不是目录: %s", cfg.SyncDir)
+	}
+	if cfg.Encryption.Enabled {
+		if _, err := api.NewAuthedClient(); err == nil {
+			if _, err := e2e.LoadKeys(e2e.RootID()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -2,24 +2,84 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"fsync/client/global"
+	"fsync/client/internal/api"
+	"fsync/client/internal/bandwidth"
 	"fsync/client/internal/command"
+	"fsync/client/internal/control"
+	"fsync/client/internal/e2e"
+	"fsync/client/internal/safety"
+	"fsync/client/internal/transfer"
+	"fsync/client/internal/trash"
 	"fsync/client/internal/watcher"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"go.uber.org/zap"
 )
 
+const (
+	// remoteTempPrefix 写入远端内容时使用的临时文件名前缀，这些文件的事件不同步
+	remoteTempPrefix = ".fsync-remote-"
+	// remoteStateTTL 远端变更记录的保留时长，只用于清理，过期后的事件按本地变更处理
+	remoteStateTTL = time.Minute
+)
+
+// remoteState 远端变更落地后本地路径应有的状态。之后的本地事件只有在路径状态与之一致时
+// 才被视为回传而忽略，期间用户对该路径的修改仍会同步
+type remoteState struct {
+	deleted bool
+	size    int64
+	modTime time.Time
+	at      time.Time
+}
+
+var (
+	commandManager  *command.CommandManager
+	localTrash      *trash.Trash
+	transferManager *transfer.Manager
+	deleteBrake     *safety.DeleteBrake
+	bandwidthCtl    *bandwidth.Controller
+	controlServer   *control.Server
+
+	syncRoot  string
+	startedAt time.Time
+
+	// stopWatch 关闭后监控协程退出，退出后关闭 stopped；backgroundQuit 停止其他后台协程
+	stopWatch      chan struct{}
+	stopped        chan struct{}
+	backgroundQuit chan struct{}
+
+	// shutdown 通过控制接口请求退出时关闭
+	shutdown     = make(chan struct{})
+	shutdownOnce sync.Once
 
-var commandManager *command.CommandManager
+	// remoteApplied 记录最近由远端变更写入或删除的本地路径及其落地后的状态
+	remoteApplied      = make(map[string]remoteState)
+	remoteAppliedMutex sync.Mutex
+)
 
-// StartFileSync 启动文件同步功能
+// StartFileSync 启动文件同步功能，失败时清理已经初始化的部分，可以再次调用
 func StartFileSync() error {
+	if err := startFileSync(); err != nil {
+		abortStart()
+		return err
+	}
+	return nil
+}
+
+// startFileSync 依次初始化同步所需的组件并启动监控
+func startFileSync() error {
 	var dir string
-	if global.Configs.Client.SyncDir != "" {
-		dir = global.Configs.Client.SyncDir
+	if global.Config().Client.SyncDir != "" {
+		dir = global.Config().Client.SyncDir
 		info, err := os.Stat(dir)
 		if err != nil {
 			if os.IsNotExist(err) {
@@ -32,15 +92,110 @@ func StartFileSync() error {
 		return fmt.Errorf("错误: 必须在配置文件中指定同步目录")
 	}
 
+	// 初始化本地回收站，远端发起的删除和覆盖会先把本地副本移入回收站
+	trashCfg := global.Config().Client.Trash
+	t, err := trash.New(dir, trashCfg.MaxAge, trashCfg.MaxSizeMB, global.Logger)
+	if err != nil {
+		return err
+	}
+	localTrash = t
+	localTrash.StartCleaner(trashCfg.CleanInterval)
+
+	if err := setIgnore(global.Config().Client.Ignore); err != nil {
+		return err
+	}
+
+	// 初始化限速，所有命令队列工作协程的传输共用同一组令牌桶
+	bandwidthCtl, err = bandwidth.NewController(global.Config().Client.Bandwidth, global.Logger)
+	if err != nil {
+		return err
+	}
+	backgroundQuit = make(chan struct{})
+	go bandwidthCtl.Run(10*time.Second, backgroundQuit)
+
+	// 初始化传输管理器，未登录时只监控不上传
+	if client, err := api.NewAuthedClient(); err != nil {
+		global.Logger.Warn("未加载到登录令牌，文件变更不会上传", zap.Error(err))
+	} else {
+		// 启用端到端加密时必须能加载密钥，否则拒绝启动，避免以明文上传
+		var keys *e2e.Keys
+		if global.Config().Client.Encryption.Enabled {
+			if keys, err = e2e.LoadKeys(e2e.RootID()); err != nil {
+				return err
+			}
+		}
+		client.SetThrottle(bandwidthCtl)
+		transferCfg := global.Config().Client.Transfer
+		transferManager, err = transfer.NewManager(client, dir, transfer.Options{
+			DeltaSync:       transferCfg.DeltaSync,
+			Keys:            keys,
+			Codec:           transferCfg.Compression,
+			CompressMinSize: transferCfg.CompressMinSize,
+			Bandwidth:       bandwidthCtl,
+		}, global.Logger)
+		if err != nil {
+			return err
+		}
+		// 计量模式关闭后继续被暂缓的传输
+		bandwidthCtl.OnResume(func() { transferManager.ResumePending(ApplyRemoteWrite) })
+		go transferManager.ResumePending(ApplyRemoteWrite)
+		if interval := transferCfg.PollInterval; interval > 0 {
+			go pullRemoteChanges(interval, backgroundQuit)
+		}
+	}
 
 	// 创建命令管理器，包含异步队列, bufferSize: 队列大小，numWorkers: 工作协程数量
 	commandManager = command.NewCommandManager(global.Logger, 100, 2)
 
+	// 初始化大量删除保护，删除命令先经过保护再进入命令队列
+	if brakeCfg := global.Config().Client.DeleteBrake; brakeCfg.Enabled {
+		deleteBrake = safety.NewDeleteBrake(dir, brakeCfg.Window, brakeCfg.MaxCount, brakeCfg.MaxPercent,
+			commandManager.AddCommandWait, global.Logger)
+		if err := deleteBrake.CountTracked(); err != nil {
+			return err
+		}
+		go deleteBrake.WatchRoot(time.Second, backgroundQuit)
+	}
+
+	root := filepath.Clean(dir)
+	syncRoot = root
+	startedAt = time.Now()
+
+	// 启动本地控制接口，命令行通过它与运行中的客户端交互
+	controlServer, err = control.Listen(control.SocketPath(), newControlHandler(), global.Logger)
+	if err != nil {
+		return err
+	}
+
+	// 重新提交上次退出时未执行的命令，删除命令经过删除保护。放在最后一步可能失败的初始化之后，
+	// 启动失败时命令仍保存在文件中
+	if err := restoreQueue(dir); err != nil {
+		global.Logger.Error("恢复上次未执行的命令失败", zap.Error(err))
+	}
+
+	stopWatch = make(chan struct{})
+	stopped = make(chan struct{})
 	go func() {
-		defer global.Logger.Sync() // 刷新缓冲区
-		defer commandManager.Stop() // 确保在监控退出时停止队列
+		defer close(stopped)
 
 		err := watcher.WatchDirRecursive(dir, func(event fsnotify.Event) {
+			if isRemoteEcho(event.Name) {
+				global.Logger.Debug("忽略远端变更引起的本地事件", zap.String("file", event.Name))
+				return
+			}
+			if isIgnored(event.Name) {
+				return
+			}
+
+			// 同步根目录本身被删除或移走时，不能当作删除全部文件处理
+			if filepath.Clean(event.Name) == root && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				global.Logger.Error("同步根目录已消失", zap.String("root", root))
+				if deleteBrake != nil {
+					deleteBrake.RootLost()
+				}
+				return
+			}
+
 			var cmd command.Command
 			switch {
 			case event.Op&fsnotify.Create == fsnotify.Create:
@@ -50,13 +205,14 @@ func StartFileSync() error {
 					global.Logger.Error("无法获取文件信息", zap.String("file", event.Name), zap.Error(err))
 					return
 				}
-				
+
 				if fileInfo.IsDir() {
 					cmd = &command.FileCommand{
 						Action:      "create_dir",
 						FilePath:    event.Name,
 						Description: fmt.Sprintf("创建目录: %s", event.Name),
 						Logger:      global.Logger,
+						Transfer:    transferManager,
 					}
 				} else {
 					cmd = &command.FileCommand{
@@ -64,15 +220,17 @@ func StartFileSync() error {
 						FilePath:    event.Name,
 						Description: fmt.Sprintf("创建文件: %s", event.Name),
 						Logger:      global.Logger,
+						Transfer:    transferManager,
 					}
 				}
 			case event.Op&fsnotify.Write == fsnotify.Write:
-				
+
 				cmd = &command.FileCommand{
 					Action:      "write",
 					FilePath:    event.Name,
 					Description: fmt.Sprintf("修改文件: %s", event.Name),
 					Logger:      global.Logger,
+					Transfer:    transferManager,
 				}
 			case event.Op&fsnotify.Remove == fsnotify.Remove:
 				cmd = &command.FileCommand{
@@ -80,6 +238,7 @@ func StartFileSync() error {
 					FilePath:    event.Name,
 					Description: fmt.Sprintf("删除文件: %s", event.Name),
 					Logger:      global.Logger,
+					Transfer:    transferManager,
 				}
 			case event.Op&fsnotify.Rename == fsnotify.Rename:
 				cmd = &command.FileCommand{
@@ -96,12 +255,23 @@ func StartFileSync() error {
 					Logger:      global.Logger,
 				}
 			}
-			
-			// 将命令添加到管理器内部异步执行
-			if cmd != nil {
-				commandManager.AddCommand(cmd)
+
+			if cmd == nil {
+				return
 			}
-		})
+			if deleteBrake != nil {
+				switch cmd.(*command.FileCommand).Action {
+				case "remove":
+					// 删除命令交给删除保护决定放行或暂停
+					deleteBrake.Submit(cmd)
+					return
+				case "create_file":
+					deleteBrake.FileAdded()
+				}
+			}
+			// 将命令添加到管理器内部异步执行
+			commandManager.AddCommand(cmd)
+		}, stopWatch)
 		if err != nil {
 			global.Logger.Error("监控目录失败:", zap.Error(err))
 		}
@@ -109,6 +279,76 @@ func StartFileSync() error {
 	return nil
 }
 
+// ShutdownRequested 返回在控制接口请求退出时关闭的 channel
+func ShutdownRequested() <-chan struct{} {
+	return shutdown
+}
+
+// requestShutdown 请求客户端退出，可重复调用
+func requestShutdown() {
+	shutdownOnce.Do(func() { close(shutdown) })
+}
+
+// StopFileSync 优雅退出：先停止监控不再接收新的变更，在 timeout 内执行完排队的命令，
+// 未执行的命令保存到同步目录中下次启动时继续，最后关闭控制接口并刷新日志
+func StopFileSync(timeout time.Duration) {
+	if stopWatch == nil {
+		return
+	}
+	close(stopWatch)
+	<-stopped
+	close(backgroundQuit)
+
+	remaining := commandManager.Shutdown(timeout)
+	// 超时后仍在执行的传输不再等待限速令牌，尽快退出，下次启动时续传
+	bandwidthCtl.Stop()
+	var held []command.Command
+	if deleteBrake != nil {
+		held = deleteBrake.Held()
+	}
+	if err := saveQueue(syncRoot, remaining, held); err != nil {
+		global.Logger.Error("保存未执行的命令失败", zap.Error(err))
+	}
+	localTrash.Stop()
+	if controlServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		controlServer.Close(ctx)
+	}
+	resetRun()
+	global.Logger.Sync()
+}
+
+// abortStart 停止启动失败前已经启动的后台协程和命令队列
+func abortStart() {
+	if backgroundQuit != nil {
+		close(backgroundQuit)
+	}
+	if commandManager != nil {
+		commandManager.Stop()
+	}
+	if bandwidthCtl != nil {
+		bandwidthCtl.Stop()
+	}
+	if localTrash != nil {
+		localTrash.Stop()
+	}
+	resetRun()
+}
+
+// resetRun 清空本次运行的组件，重新启动时按新配置创建，不会沿用绑定在已停止队列上的旧实例
+func resetRun() {
+	commandManager = nil
+	localTrash = nil
+	transferManager = nil
+	deleteBrake = nil
+	bandwidthCtl = nil
+	controlServer = nil
+	stopWatch = nil
+	stopped = nil
+	backgroundQuit = nil
+}
+
 // 提供一个外部接口来撤销上一个操作
 func UndoLastAction() error {
 	if commandManager != nil {
@@ -123,4 +363,124 @@ func UndoAllActions() error {
 		return commandManager.UndoAll()
 	}
 	return fmt.Errorf("command manager not initialized")
-}
\ No newline at end of file
+}
+
+// pullRemoteChanges 周期性拉取其他设备的变更，远端删除和覆盖都先把本地副本移入回收站
+func pullRemoteChanges(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := transferManager.PullChanges(ApplyRemoteRemove, ApplyRemoteWrite); err != nil {
+			global.Logger.Error("同步远端变更失败", zap.Error(err))
+		}
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// ApplyRemoteRemove 处理其他设备发起的删除：本地副本移入回收站而不是直接删除
+func ApplyRemoteRemove(path string) error {
+	if localTrash == nil {
+		return fmt.Errorf("回收站未初始化")
+	}
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	markRemoteApplied(path, nil)
+	_, err := localTrash.MoveToTrash(path)
+	return err
+}
+
+// ApplyRemoteWrite 处理其他设备发起的覆盖：先将现有本地副本移入回收站，再原子地写入新内容
+func ApplyRemoteWrite(path string, content io.Reader) error {
+	if localTrash == nil {
+		return fmt.Errorf("回收站未初始化")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), remoteTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入远端内容失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入远端内容失败: %w", err)
+	}
+
+	// 重命名保留大小和修改时间，替换前记录新内容的状态，替换引起的本地事件据此识别
+	info, err := os.Lstat(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("读取临时文件信息失败: %w", err)
+	}
+	markRemoteApplied(path, info)
+	if _, err := os.Lstat(path); err == nil {
+		// 本地修改尚未上传完成就被远端版本覆盖时记为冲突，本地版本保留在回收站中
+		unsynced := transferManager != nil && transferManager.HasPendingUpload(path)
+		trashPath, err := localTrash.MoveToTrash(path)
+		if err != nil {
+			return err
+		}
+		if unsynced {
+			recordConflict(path, trashPath)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("替换本地文件失败: %w", err)
+	}
+	return nil
+}
+
+// markRemoteApplied 记录远端变更落地后路径的状态，info 为 nil 表示路径被删除
+func markRemoteApplied(path string, info os.FileInfo) {
+	state := remoteState{deleted: info == nil, at: time.Now()}
+	if info != nil {
+		state.size = info.Size()
+		state.modTime = info.ModTime()
+	}
+	remoteAppliedMutex.Lock()
+	defer remoteAppliedMutex.Unlock()
+	remoteApplied[path] = state
+}
+
+// isRemoteEcho 判断本地事件是否由远端变更引起：路径当前的状态与远端变更落地后的状态一致。
+// 状态不一致说明用户之后又修改了该路径，记录作废，事件按本地变更同步
+func isRemoteEcho(path string) bool {
+	if strings.HasPrefix(filepath.Base(path), remoteTempPrefix) {
+		return true
+	}
+
+	remoteAppliedMutex.Lock()
+	defer remoteAppliedMutex.Unlock()
+
+	now := time.Now()
+	for p, state := range remoteApplied {
+		if now.Sub(state.at) > remoteStateTTL {
+			delete(remoteApplied, p)
+		}
+	}
+	state, ok := remoteApplied[path]
+	if !ok {
+		return false
+	}
+	info, err := os.Lstat(path)
+	var echo bool
+	if state.deleted {
+		echo = os.IsNotExist(err)
+	} else {
+		echo = err == nil && info.Size() == state.size && info.ModTime().Equal(state.modTime)
+	}
+	if !echo {
+		delete(remoteApplied, path)
+	}
+	return echo
+}
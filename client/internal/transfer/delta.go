@@ -0,0 +1,189 @@
+// client/internal/transfer/delta.go
+package transfer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fsync/client/internal/api"
+	"fsync/client/internal/compress"
+	"fsync/pkg/chunker"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// missingBatch 每次查询缺失分块的哈希数量，需不超过服务端限制
+const missingBatch = 1000
+
+// uploadDelta 增量上传：按内容定义分块，只上传服务端缺失的分块，再提交分块清单。
+// 中断后重新调用时已上传的分块不再缺失，天然支持续传。
+// 每个明文分块先单独压缩再确定性地加密，清单和哈希都基于最终上传的内容。
+// 压缩不能缩小的分块原样上传，清单记录每个分块的压缩编码，下载时按清单逐块还原
+func (m *Manager) uploadDelta(localPath, remote string, meta api.UploadMeta) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+
+	// 第一遍：计算分块清单和整体哈希
+	var size int64
+	refs := make([]api.ChunkRef, 0)
+	hashes := make([]string, 0)
+	fileHash := sha256.New()
+	c := chunker.New(f)
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("文件分块失败: %w", err)
+		}
+		payload, hash, codec, err := m.encodeChunk(chunk, meta.Codec)
+		if err != nil {
+			return err
+		}
+		fileHash.Write(payload)
+		refs = append(refs, api.ChunkRef{Hash: hash, Size: int64(len(payload)), Codec: codec})
+		hashes = append(hashes, hash)
+		size += int64(len(payload))
+	}
+	hash := hex.EncodeToString(fileHash.Sum(nil))
+
+	missing := make(map[string]bool)
+	for start := 0; start < len(hashes); start += missingBatch {
+		end := start + missingBatch
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		list, err := m.client.MissingChunks(hashes[start:end])
+		if err != nil {
+			return fmt.Errorf("查询缺失分块失败: %w", err)
+		}
+		for _, h := range list {
+			missing[h] = true
+		}
+	}
+
+	// 第二遍：只上传缺失的分块
+	if len(missing) > 0 {
+		var missingSize int64
+		counted := make(map[string]bool, len(missing))
+		for _, ref := range refs {
+			if missing[ref.Hash] && !counted[ref.Hash] {
+				counted[ref.Hash] = true
+				missingSize += ref.Size
+			}
+		}
+		p := m.begin(kindUpload, remote, missingSize, 0)
+		defer m.end(p)
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		var uploaded int64
+		c := chunker.New(f)
+		for {
+			chunk, err := c.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("文件分块失败: %w", err)
+			}
+			payload, hash, _, err := m.encodeChunk(chunk, meta.Codec)
+			if err != nil {
+				return err
+			}
+			if !missing[hash] {
+				continue
+			}
+			if err := m.client.PutContentChunk(hash, payload); err != nil {
+				return fmt.Errorf("上传分块失败: %w", err)
+			}
+			delete(missing, hash)
+			uploaded += int64(len(payload))
+			p.Write(payload)
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("文件在上传过程中发生变化，稍后重试")
+		}
+		m.logger.Info("增量上传分块", zap.String("path", m.DisplayPath(remote)), zap.Int64("uploaded", uploaded), zap.Int64("size", size))
+	}
+
+	if err := m.client.CommitFile(remote, size, hash, refs, meta); err != nil {
+		return fmt.Errorf("提交文件失败: %w", err)
+	}
+	m.finishUpload(remote)
+	m.logger.Info("文件同步完成", zap.String("path", m.DisplayPath(remote)), zap.Int("chunks", len(refs)))
+	return nil
+}
+
+// encodeChunk 返回实际上传的分块内容、哈希及分块使用的压缩编码：按需压缩，启用端到端加密时再加密为记录。
+// 文件的压缩编码只根据开头的样本决定，后面无法压缩的分块压缩后反而变大，此时不压缩
+func (m *Manager) encodeChunk(chunk *chunker.Chunk, codec string) ([]byte, string, string, error) {
+	if m.keys == nil && codec == compress.None {
+		return chunk.Data, chunk.Hash, compress.None, nil
+	}
+	payload, err := compress.Bytes(codec, chunk.Data)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("压缩分块失败: %w", err)
+	}
+	if len(payload) >= len(chunk.Data) {
+		payload, codec = chunk.Data, compress.None
+	}
+	if m.keys != nil {
+		if payload, err = m.keys.SealChunk(payload); err != nil {
+			return nil, "", "", fmt.Errorf("加密分块失败: %w", err)
+		}
+	}
+	if len(payload) > chunker.MaxEncodedSize {
+		return nil, "", "", fmt.Errorf("编码后的分块大小 %d 超过上限 %d", len(payload), chunker.MaxEncodedSize)
+	}
+	if m.keys == nil && codec == compress.None {
+		return payload, chunk.Hash, codec, nil
+	}
+	sum := sha256.Sum256(payload)
+	return payload, hex.EncodeToString(sum[:]), codec, nil
+}
+
+// decodeChunks 按分块清单逐块校验、解密和解压 src 写入 dst。清单与下载内容不一致时
+// （例如下载后文件又被更新）分块哈希校验失败，下次重新下载
+func (m *Manager) decodeChunks(dst io.Writer, src io.Reader, chunks []api.ChunkRef, codec string) error {
+	buf := make([]byte, 0, chunker.MaxEncodedSize)
+	for _, ref := range chunks {
+		if ref.Size < 0 || ref.Size > chunker.MaxEncodedSize {
+			return fmt.Errorf("分块大小异常: %d", ref.Size)
+		}
+		if ref.Codec != compress.None && ref.Codec != codec {
+			return fmt.Errorf("分块压缩编码 %s 与文件压缩编码 %s 不一致", ref.Codec, codec)
+		}
+		payload := buf[:ref.Size]
+		if _, err := io.ReadFull(src, payload); err != nil {
+			return fmt.Errorf("下载内容与分块清单不一致: %w", err)
+		}
+		sum := sha256.Sum256(payload)
+		if hex.EncodeToString(sum[:]) != ref.Hash {
+			return fmt.Errorf("分块校验失败: %s", ref.Hash)
+		}
+		if m.keys != nil {
+			plain, err := m.keys.OpenChunk(payload)
+			if err != nil {
+				return err
+			}
+			payload = plain
+		}
+		if err := compress.Decode(ref.Codec, dst, bytes.NewReader(payload)); err != nil {
+			return err
+		}
+	}
+	if n, _ := io.CopyN(io.Discard, src, 1); n > 0 {
+		return fmt.Errorf("下载内容与分块清单不一致: 存在多余数据")
+	}
+	return nil
+}
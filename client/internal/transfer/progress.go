@@ -0,0 +1,79 @@
+// client/internal/transfer/progress.go
+package transfer
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Progress 正在进行的传输
+type Progress struct {
+	Kind      string    `json:"kind"` // upload 或 download
+	Path      string    `json:"path"` // 明文远端路径
+	Size      int64     `json:"size"` // 本次需要传输的总字节数，未知时为 0
+	Done      int64     `json:"done"` // 已传输的字节数，续传时包含之前完成的部分
+	StartedAt time.Time `json:"started_at"`
+}
+
+// progress 传输进度的内部记录，done 由传输协程原子更新
+type progress struct {
+	Progress
+	key  string
+	done atomic.Int64
+}
+
+// Write 累加已传输的字节数，便于和 io.TeeReader 配合使用
+func (p *progress) Write(b []byte) (int, error) {
+	p.done.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// begin 登记一个开始的传输
+func (m *Manager) begin(kind, remote string, size, done int64) *progress {
+	p := &progress{
+		Progress: Progress{Kind: kind, Path: m.DisplayPath(remote), Size: size, StartedAt: time.Now()},
+		key:      stateKey(kind, remote),
+	}
+	p.done.Store(done)
+	m.active.Store(p.key, p)
+	return p
+}
+
+// end 注销已结束（完成或失败）的传输
+func (m *Manager) end(p *progress) {
+	m.active.Delete(p.key)
+}
+
+// Active 返回正在进行的传输，按开始时间排序
+func (m *Manager) Active() []Progress {
+	list := make([]Progress, 0)
+	m.active.Range(func(_, v interface{}) bool {
+		p := v.(*progress)
+		snapshot := p.Progress
+		snapshot.Done = p.done.Load()
+		list = append(list, snapshot)
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+	return list
+}
+
+// PendingCount 返回未完成（中断或被暂缓）的传输数量
+func (m *Manager) PendingCount() int {
+	states, err := m.pendingStates()
+	if err != nil {
+		return 0
+	}
+	return len(states)
+}
+
+// HasPendingUpload 判断本地文件是否有尚未完成的上传，即本地修改还没有同步到服务端
+func (m *Manager) HasPendingUpload(localPath string) bool {
+	remote, err := m.RemotePath(localPath)
+	if err != nil {
+		return false
+	}
+	st, err := m.loadState(kindUpload, remote)
+	return err == nil && st != nil
+}
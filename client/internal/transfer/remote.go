@@ -0,0 +1,134 @@
+// client/internal/transfer/remote.go
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"fsync/client/internal/api"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// cursorFile 已应用的远端变更游标，保存在传输状态目录中
+const cursorFile = "changes.cursor"
+
+// RemoveFunc 将远端删除落地到本地路径，由调用方决定删除策略（如移入回收站）
+type RemoveFunc func(localPath string) error
+
+// Remove 删除本地文件对应的远端文件，放弃该路径未完成的上传。远端不存在时视为成功
+func (m *Manager) Remove(localPath string) error {
+	remote, err := m.RemotePath(localPath)
+	if err != nil {
+		return err
+	}
+	defer m.lock(remote)()
+
+	m.removeState(kindUpload, remote)
+	os.Remove(m.encPath(remote))
+	if err := m.client.RemoveFile(remote); err != nil && !isNotFound(err) {
+		return fmt.Errorf("删除远端文件失败: %w", err)
+	}
+	m.logger.Info("远端文件已删除", zap.String("path", m.DisplayPath(remote)))
+	return nil
+}
+
+// PullChanges 拉取其他设备的文件变更并应用到本地：删除交给 remove，覆盖下载后交给 apply。
+// 首次拉取只记录服务端当前游标，不重放之前的历史。应用失败时游标停在失败的变更之前，下次重试
+func (m *Manager) PullChanges(remove RemoveFunc, apply ApplyFunc) error {
+	cursor, ok, err := m.loadCursor()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		latest, err := m.client.LatestChange()
+		if err != nil {
+			return fmt.Errorf("查询远端变更失败: %w", err)
+		}
+		return m.saveCursor(latest)
+	}
+
+	for {
+		page, err := m.client.Changes(cursor)
+		if err != nil {
+			return fmt.Errorf("拉取远端变更失败: %w", err)
+		}
+		for _, change := range page.Changes {
+			if err := m.applyChange(change, remove, apply); err != nil {
+				if saveErr := m.saveCursor(cursor); saveErr != nil {
+					m.logger.Error("保存远端变更游标失败", zap.Error(saveErr))
+				}
+				return err
+			}
+			cursor = change.ID
+		}
+		cursor = page.Cursor
+		if err := m.saveCursor(cursor); err != nil {
+			return err
+		}
+		if !page.More {
+			return nil
+		}
+	}
+}
+
+// applyChange 应用一条远端变更。下载时文件已被再次删除（404）则跳过，后续的删除变更会处理它
+func (m *Manager) applyChange(change api.FileChange, remove RemoveFunc, apply ApplyFunc) error {
+	localPath, err := m.LocalPath(change.Path)
+	if err != nil {
+		m.logger.Error("跳过无法还原路径的远端变更", zap.String("path", change.Path), zap.Error(err))
+		return nil
+	}
+	if change.Deleted {
+		m.logger.Info("应用远端删除", zap.String("path", m.DisplayPath(change.Path)))
+		if err := remove(localPath); err != nil {
+			return fmt.Errorf("应用远端删除失败: %w", err)
+		}
+		return nil
+	}
+
+	m.logger.Info("应用远端修改", zap.String("path", m.DisplayPath(change.Path)))
+	if err := m.Download(change.Path, apply); err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// loadCursor 读取已应用的远端变更游标，ok 为 false 表示从未拉取过
+func (m *Manager) loadCursor() (cursor uint64, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(m.stateDir, cursorFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("读取远端变更游标失败: %w", err)
+	}
+	cursor, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("解析远端变更游标失败: %w", err)
+	}
+	return cursor, true, nil
+}
+
+// saveCursor 原子地保存远端变更游标
+func (m *Manager) saveCursor(cursor uint64) error {
+	path := filepath.Join(m.stateDir, cursorFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(cursor, 10)+"\n"), 0600); err != nil {
+		return fmt.Errorf("保存远端变更游标失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// isNotFound 判断是否为服务端返回的 404
+func isNotFound(err error) bool {
+	var statusErr *api.StatusError
+	return errors.As(err, &statusErr) && statusErr.Status == http.StatusNotFound
+}
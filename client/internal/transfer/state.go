@@ -0,0 +1,118 @@
+// client/internal/transfer/state.go
+package transfer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 传输状态文件的类型前缀
+const (
+	kindUpload   = "upload"
+	kindDownload = "download"
+)
+
+// state 持久化的传输进度，客户端重启后据此续传
+type state struct {
+	Kind      string `json:"kind"`
+	Remote    string `json:"remote"`               // 相对同步根目录的路径，使用 / 分隔
+	Size      int64  `json:"size"`                 // 文件总大小
+	Hash      string `json:"hash"`                 // 文件整体 SHA256
+	SessionID string `json:"session_id,omitempty"` // 上传会话 ID
+	ChunkSize int64  `json:"chunk_size,omitempty"`
+	Confirmed int64  `json:"confirmed"` // 服务端已确认的连续偏移量
+
+	// Source 编码前明文的指纹，与 Codec 都未变化时复用已压缩或加密的临时文件续传
+	Source string `json:"source,omitempty"`
+	Codec  string `json:"codec,omitempty"`
+}
+
+// stateKey 根据类型和远端路径生成状态文件名
+func stateKey(kind, remote string) string {
+	sum := sha1.Sum([]byte(remote))
+	return kind + "-" + hex.EncodeToString(sum[:])
+}
+
+// statePath 返回状态文件路径
+func (m *Manager) statePath(kind, remote string) string {
+	return filepath.Join(m.stateDir, stateKey(kind, remote)+".json")
+}
+
+// partPath 返回下载中的临时文件路径
+func (m *Manager) partPath(remote string) string {
+	return filepath.Join(m.stateDir, stateKey(kindDownload, remote)+".part")
+}
+
+// encPath 返回压缩或加密上传时编码后内容的临时文件路径
+func (m *Manager) encPath(remote string) string {
+	return filepath.Join(m.stateDir, stateKey(kindUpload, remote)+".enc")
+}
+
+// plainPath 返回下载后解密和解压得到的临时文件路径
+func (m *Manager) plainPath(remote string) string {
+	return filepath.Join(m.stateDir, stateKey(kindDownload, remote)+".plain")
+}
+
+// loadState 读取传输状态，不存在时返回 nil
+func (m *Manager) loadState(kind, remote string) (*state, error) {
+	data, err := os.ReadFile(m.statePath(kind, remote))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取传输状态失败: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("解析传输状态失败: %w", err)
+	}
+	return &s, nil
+}
+
+// saveState 原子地保存传输状态
+func (m *Manager) saveState(s *state) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	path := m.statePath(s.Kind, s.Remote)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("保存传输状态失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeState 删除传输状态
+func (m *Manager) removeState(kind, remote string) {
+	os.Remove(m.statePath(kind, remote))
+}
+
+// pendingStates 列出所有未完成的传输
+func (m *Manager) pendingStates() ([]*state, error) {
+	entries, err := os.ReadDir(m.stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取传输状态目录失败: %w", err)
+	}
+	states := make([]*state, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(m.stateDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var s state
+		if err := json.Unmarshal(data, &s); err != nil {
+			continue
+		}
+		states = append(states, &s)
+	}
+	return states, nil
+}
@@ -0,0 +1,606 @@
+// client/internal/transfer/transfer.go
+package transfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fsync/client/global"
+	"fsync/client/internal/api"
+	"fsync/client/internal/bandwidth"
+	"fsync/client/internal/compress"
+	"fsync/client/internal/e2e"
+	"fsync/pkg/utils"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ApplyFunc 下载完成后将内容落地到本地路径，由调用方决定覆盖策略（如先移入回收站）
+type ApplyFunc func(localPath string, content io.Reader) error
+
+// Options 传输管理器的可选功能
+type Options struct {
+	// DeltaSync 为 true 时使用内容定义分块的增量上传，否则使用固定大小分块的上传会话
+	DeltaSync bool
+
+	// Keys 非空时启用端到端加密，上传前加密、下载后解密，服务端只保存密文
+	Keys *e2e.Keys
+
+	// Codec 上传前使用的压缩编码，为空时不压缩；压缩在加密之前进行
+	Codec string
+
+	// CompressMinSize 小于该大小的文件不压缩
+	CompressMinSize int64
+
+	// Bandwidth 非空时按其计量模式暂缓大文件传输；限速本身由 api.Client 的 Throttle 完成
+	Bandwidth *bandwidth.Controller
+}
+
+// Manager 分块上传与断点续传下载管理器
+type Manager struct {
+	client   *api.Client
+	root     string
+	stateDir string
+	logger   *zap.Logger
+
+	deltaSync       bool
+	keys            *e2e.Keys
+	codec           string
+	compressMinSize int64
+	bandwidth       *bandwidth.Controller
+
+	// 同一路径的传输串行执行，避免并发事件重复上传
+	locks sync.Map
+
+	// active 正在进行的传输进度
+	active sync.Map
+}
+
+// NewManager 创建传输管理器，传输状态保存在 root/.fsync/transfers
+func NewManager(client *api.Client, root string, opts Options, logger *zap.Logger) (*Manager, error) {
+	if !compress.Valid(opts.Codec) {
+		return nil, fmt.Errorf("不支持的压缩编码: %s", opts.Codec)
+	}
+	stateDir := filepath.Join(root, global.MetaDirName, "transfers")
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, fmt.Errorf("创建传输状态目录失败: %w", err)
+	}
+	return &Manager{
+		client:          client,
+		root:            root,
+		stateDir:        stateDir,
+		logger:          logger,
+		deltaSync:       opts.DeltaSync,
+		keys:            opts.Keys,
+		codec:           opts.Codec,
+		compressMinSize: opts.CompressMinSize,
+		bandwidth:       opts.Bandwidth,
+	}, nil
+}
+
+// RemotePath 将本地路径转换为相对同步根目录、以 / 分隔的远端路径。
+// 启用端到端加密时每个路径段都会被加密，服务端看不到明文文件名
+func (m *Manager) RemotePath(localPath string) (string, error) {
+	rel, err := filepath.Rel(m.root, localPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("路径不在同步目录内: %s", localPath)
+	}
+	remote := filepath.ToSlash(rel)
+	if m.keys != nil {
+		if remote, err = m.keys.EncryptPath(remote); err != nil {
+			return "", fmt.Errorf("加密路径失败: %w", err)
+		}
+	}
+	return remote, nil
+}
+
+// LocalPath 将远端路径转换为本地路径，启用端到端加密时先解密路径
+func (m *Manager) LocalPath(remote string) (string, error) {
+	if m.keys != nil {
+		var err error
+		if remote, err = m.keys.DecryptPath(remote); err != nil {
+			return "", fmt.Errorf("解密路径失败: %w", err)
+		}
+	}
+	return filepath.Join(m.root, filepath.FromSlash(remote)), nil
+}
+
+// DisplayPath 返回用于日志和命令行输出的明文远端路径，无法解密时原样返回
+func (m *Manager) DisplayPath(remote string) string {
+	if m.keys == nil {
+		return remote
+	}
+	if plain, err := m.keys.DecryptPath(remote); err == nil {
+		return plain
+	}
+	return remote
+}
+
+// lock 获取某个远端路径的传输锁
+func (m *Manager) lock(remote string) func() {
+	v, _ := m.locks.LoadOrStore(remote, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Upload 上传本地文件。中断后再次调用（包括重启后）会从服务端已确认的分块继续
+func (m *Manager) Upload(localPath string) error {
+	remote, err := m.RemotePath(localPath)
+	if err != nil {
+		return err
+	}
+	defer m.lock(remote)()
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("获取文件信息失败: %w", err)
+	}
+	if info.IsDir() {
+		m.finishUpload(remote)
+		return nil
+	}
+	if m.deferred(info.Size()) {
+		m.logger.Info("计量模式下暂缓上传大文件", zap.String("path", m.DisplayPath(remote)), zap.Int64("size", info.Size()))
+		return m.keepPending(&state{Kind: kindUpload, Remote: remote, Size: info.Size()})
+	}
+
+	// 端到端加密时密文每次都不同，用明文的带密钥 MAC 判断服务端内容是否已是最新
+	var meta api.UploadMeta
+	if m.keys != nil {
+		if meta.ContentMAC, err = m.keys.ContentMACFile(localPath); err != nil {
+			return fmt.Errorf("计算内容 MAC 失败: %w", err)
+		}
+		if remoteMeta, err := m.client.GetFileMeta(remote); err == nil && remoteMeta.ContentMAC == meta.ContentMAC {
+			m.logger.Debug("内容未变化，跳过上传", zap.String("path", m.DisplayPath(remote)))
+			m.finishUpload(remote)
+			return nil
+		}
+	}
+	if meta.Codec, err = compress.Choose(localPath, m.codec, m.compressMinSize); err != nil {
+		return fmt.Errorf("检测压缩效果失败: %w", err)
+	}
+	if m.deltaSync {
+		return m.uploadDelta(localPath, remote, meta)
+	}
+
+	// 需要压缩或加密时先编码到临时文件，上传会话和续传都基于编码后的内容
+	src := localPath
+	size := info.Size()
+	fingerprint := ""
+	if m.keys != nil || meta.Codec != compress.None {
+		if fingerprint, err = sourceFingerprint(localPath, meta); err != nil {
+			return err
+		}
+		if src, err = m.prepareEncoded(localPath, remote, fingerprint, meta.Codec); err != nil {
+			return err
+		}
+		encoded, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer encoded.Close()
+		f = encoded
+		if info, err = encoded.Stat(); err != nil {
+			return err
+		}
+		size = info.Size()
+	}
+	hash, err := utils.HashFileSHA256(src)
+	if err != nil {
+		return err
+	}
+
+	session, err := m.openSession(remote, size, hash, meta)
+	if err != nil {
+		return err
+	}
+	st := &state{
+		Kind:      kindUpload,
+		Remote:    remote,
+		Size:      size,
+		Hash:      hash,
+		SessionID: session.SessionID,
+		ChunkSize: session.ChunkSize,
+		Source:    fingerprint,
+		Codec:     meta.Codec,
+	}
+
+	received := make(map[int]bool, len(session.Received))
+	for _, idx := range session.Received {
+		received[idx] = true
+	}
+	st.Confirmed = confirmedOffset(received, session)
+	if err := m.saveState(st); err != nil {
+		return err
+	}
+	if st.Confirmed > 0 {
+		m.logger.Info("续传文件", zap.String("path", m.DisplayPath(remote)), zap.Int64("offset", st.Confirmed))
+	}
+	p := m.begin(kindUpload, remote, size, st.Confirmed)
+	defer m.end(p)
+
+	buf := make([]byte, session.ChunkSize)
+	for idx := 0; idx < session.TotalChunks; idx++ {
+		if received[idx] {
+			continue
+		}
+		n, err := f.ReadAt(buf, int64(idx)*session.ChunkSize)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("读取分块 %d 失败: %w", idx, err)
+		}
+		sum := sha256.Sum256(buf[:n])
+		if err := m.client.PutChunk(session.SessionID, idx, hex.EncodeToString(sum[:]), buf[:n]); err != nil {
+			return fmt.Errorf("上传分块 %d 失败: %w", idx, err)
+		}
+		received[idx] = true
+		st.Confirmed = confirmedOffset(received, session)
+		p.Write(buf[:n])
+		if err := m.saveState(st); err != nil {
+			return err
+		}
+	}
+
+	if err := m.client.CompleteUpload(session.SessionID); err != nil {
+		return fmt.Errorf("完成上传失败: %w", err)
+	}
+	m.finishUpload(remote)
+	m.logger.Info("文件上传完成", zap.String("path", m.DisplayPath(remote)), zap.Int64("size", size))
+	return nil
+}
+
+// finishUpload 在上传完成或无需上传时清理续传状态和编码后的临时文件，
+// 否则暂缓或中断时留下的状态会让 ResumePending 反复重试
+func (m *Manager) finishUpload(remote string) {
+	m.removeState(kindUpload, remote)
+	os.Remove(m.encPath(remote))
+}
+
+// prepareEncoded 返回压缩和加密后的临时文件。明文和压缩编码都未变化时复用上次的结果，
+// 这样中断的上传在重启后仍能按编码后内容的哈希续传
+func (m *Manager) prepareEncoded(localPath, remote, fingerprint, codec string) (string, error) {
+	encPath := m.encPath(remote)
+	st, err := m.loadState(kindUpload, remote)
+	if err != nil {
+		return "", err
+	}
+	if st != nil && st.Source == fingerprint && st.Codec == codec {
+		if _, err := os.Stat(encPath); err == nil {
+			return encPath, nil
+		}
+	}
+
+	in, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(encPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	err = m.encode(out, in, codec)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(encPath)
+		return "", fmt.Errorf("编码文件失败: %w", err)
+	}
+	return encPath, nil
+}
+
+// encode 依次压缩和加密 src 写入 dst
+func (m *Manager) encode(dst io.Writer, src io.Reader, codec string) error {
+	out := dst
+	var encrypter io.WriteCloser
+	if m.keys != nil {
+		var err error
+		if encrypter, err = m.keys.NewEncryptWriter(dst); err != nil {
+			return err
+		}
+		out = encrypter
+	}
+	compressor, err := compress.NewWriter(codec, out)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(compressor, src); err != nil {
+		return err
+	}
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+	if encrypter != nil {
+		return encrypter.Close()
+	}
+	return nil
+}
+
+// decode 依次解密和解压 src 写入 dst，是 encode 的逆过程
+func (m *Manager) decode(dst io.Writer, src io.Reader, codec string) error {
+	if m.keys == nil {
+		return compress.Decode(codec, dst, src)
+	}
+	if codec == compress.None {
+		return m.keys.Decrypt(dst, src)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(m.keys.Decrypt(pw, src))
+	}()
+	err := compress.Decode(codec, dst, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// sourceFingerprint 返回标识明文内容的指纹：端到端加密时为内容 MAC，否则为明文哈希
+func sourceFingerprint(localPath string, meta api.UploadMeta) (string, error) {
+	if meta.ContentMAC != "" {
+		return meta.ContentMAC, nil
+	}
+	return utils.HashFileSHA256(localPath)
+}
+
+// openSession 优先复用本地记录的上传会话，内容已变化或会话失效时创建新会话
+func (m *Manager) openSession(remote string, size int64, hash string, meta api.UploadMeta) (*api.UploadSession, error) {
+	st, err := m.loadState(kindUpload, remote)
+	if err != nil {
+		return nil, err
+	}
+	if st != nil && st.SessionID != "" && st.Hash == hash && st.Size == size {
+		session, err := m.client.GetUpload(st.SessionID)
+		if err == nil && !session.Completed {
+			return session, nil
+		}
+		var statusErr *api.StatusError
+		if err != nil && !(errors.As(err, &statusErr) && statusErr.Status == http.StatusNotFound) {
+			return nil, fmt.Errorf("查询上传会话失败: %w", err)
+		}
+	}
+	session, err := m.client.CreateUpload(remote, size, hash, meta)
+	if err != nil {
+		return nil, fmt.Errorf("创建上传会话失败: %w", err)
+	}
+	return session, nil
+}
+
+// confirmedOffset 计算从文件开头起连续确认的字节偏移量
+func confirmedOffset(received map[int]bool, session *api.UploadSession) int64 {
+	idx := 0
+	for idx < session.TotalChunks && received[idx] {
+		idx++
+	}
+	offset := int64(idx) * session.ChunkSize
+	if offset > session.Size {
+		offset = session.Size
+	}
+	return offset
+}
+
+// Download 下载远端文件，中断后再次调用会通过 HTTP Range 从已下载的位置继续。
+// 下载完成并校验哈希后交给 apply 落地到本地
+func (m *Manager) Download(remote string, apply ApplyFunc) error {
+	defer m.lock(remote)()
+
+	st, err := m.loadState(kindDownload, remote)
+	if err != nil {
+		return err
+	}
+	partPath := m.partPath(remote)
+	var offset int64
+	ifRange := ""
+	if st != nil {
+		if info, err := os.Stat(partPath); err == nil {
+			offset = info.Size()
+			ifRange = st.Hash
+		}
+	}
+
+	resp, err := m.client.Download(remote, offset, ifRange)
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) && statusErr.Status == http.StatusRequestedRangeNotSatisfiable {
+		// 临时文件与远端不一致，丢弃后重新下载
+		offset = 0
+		resp, err = m.client.Download(remote, 0, "")
+	}
+	if err != nil {
+		return fmt.Errorf("下载失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	hash := resp.Header.Get(api.FileHashHeader)
+	meta := api.UploadMeta{
+		ContentMAC: resp.Header.Get(api.ContentMACHeader),
+		Codec:      resp.Header.Get(api.ContentCodecHeader),
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if resp.StatusCode != http.StatusPartialContent {
+		// 服务端返回完整内容（首次下载或文件已变化），从头写入
+		offset = 0
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+	if resp.ContentLength > 0 && m.deferred(offset+resp.ContentLength) {
+		m.logger.Info("计量模式下暂缓下载大文件", zap.String("path", m.DisplayPath(remote)),
+			zap.Int64("size", offset+resp.ContentLength))
+		if offset == 0 {
+			os.Remove(partPath)
+		}
+		return m.saveState(&state{Kind: kindDownload, Remote: remote, Hash: hash, Confirmed: offset})
+	}
+	st = &state{Kind: kindDownload, Remote: remote, Hash: hash, Confirmed: offset}
+	if err := m.saveState(st); err != nil {
+		return err
+	}
+
+	part, err := os.OpenFile(partPath, flags, 0600)
+	if err != nil {
+		return fmt.Errorf("打开临时文件失败: %w", err)
+	}
+	var total int64
+	if resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+	p := m.begin(kindDownload, remote, total, offset)
+	defer m.end(p)
+	if _, err := io.Copy(part, io.TeeReader(resp.Body, p)); err != nil {
+		part.Close()
+		return fmt.Errorf("下载中断，可稍后续传: %w", err)
+	}
+	if err := part.Close(); err != nil {
+		return err
+	}
+
+	got, err := utils.HashFileSHA256(partPath)
+	if err != nil {
+		return err
+	}
+	if hash != "" && got != hash {
+		os.Remove(partPath)
+		m.removeState(kindDownload, remote)
+		return fmt.Errorf("下载内容校验失败: 期望 %s，实际 %s", hash, got)
+	}
+
+	contentPath := partPath
+	if m.keys != nil || meta.Codec != compress.None {
+		if contentPath, err = m.decodeDownload(remote, partPath, meta); err != nil {
+			os.Remove(partPath)
+			m.removeState(kindDownload, remote)
+			return err
+		}
+		defer os.Remove(contentPath)
+	}
+
+	part, err = os.Open(contentPath)
+	if err != nil {
+		return err
+	}
+	localPath, err := m.LocalPath(remote)
+	if err != nil {
+		part.Close()
+		return err
+	}
+	err = apply(localPath, part)
+	part.Close()
+	if err != nil {
+		return err
+	}
+	os.Remove(partPath)
+	m.removeState(kindDownload, remote)
+	m.logger.Info("文件下载完成", zap.String("path", m.DisplayPath(remote)))
+	return nil
+}
+
+// deferred 判断计量模式下是否应暂缓该大小的传输
+func (m *Manager) deferred(size int64) bool {
+	return m.bandwidth != nil && m.bandwidth.Deferred(size)
+}
+
+// keepPending 记录被暂缓的上传，计量模式关闭后由 ResumePending 继续。已有续传进度时保留原状态
+func (m *Manager) keepPending(st *state) error {
+	existing, err := m.loadState(st.Kind, st.Remote)
+	if err != nil || existing != nil {
+		return err
+	}
+	return m.saveState(st)
+}
+
+// decodeDownload 解密并解压下载的内容。端到端加密时再用明文 MAC 校验：服务端无法伪造 MAC，
+// 因此拼接、截断或替换为其他文件的密文都会被发现
+func (m *Manager) decodeDownload(remote, partPath string, meta api.UploadMeta) (string, error) {
+	if m.keys != nil && meta.ContentMAC == "" {
+		return "", fmt.Errorf("远端文件未加密或缺少内容 MAC，拒绝在加密同步目录中使用: %s", m.DisplayPath(remote))
+	}
+	if !compress.Valid(meta.Codec) {
+		return "", fmt.Errorf("不支持的压缩编码: %s", meta.Codec)
+	}
+	// 增量上传的压缩文件中可能有未压缩的分块，需要按清单逐块还原
+	var chunks []api.ChunkRef
+	if meta.Codec != compress.None {
+		var err error
+		if chunks, err = m.client.GetManifest(remote); err != nil {
+			return "", fmt.Errorf("获取分块清单失败: %w", err)
+		}
+	}
+	part, err := os.Open(partPath)
+	if err != nil {
+		return "", err
+	}
+	defer part.Close()
+
+	plainPath := m.plainPath(remote)
+	out, err := os.OpenFile(plainPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	if len(chunks) > 0 {
+		err = m.decodeChunks(out, part, chunks, meta.Codec)
+	} else {
+		err = m.decode(out, part, meta.Codec)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(plainPath)
+		return "", fmt.Errorf("还原下载内容失败: %w", err)
+	}
+
+	if m.keys != nil {
+		got, err := m.keys.ContentMACFile(plainPath)
+		if err != nil {
+			os.Remove(plainPath)
+			return "", err
+		}
+		if got != meta.ContentMAC {
+			os.Remove(plainPath)
+			return "", fmt.Errorf("解密后内容校验失败: %s", m.DisplayPath(remote))
+		}
+	}
+	return plainPath, nil
+}
+
+// ResumePending 恢复上次未完成的上传和下载
+func (m *Manager) ResumePending(apply ApplyFunc) {
+	states, err := m.pendingStates()
+	if err != nil {
+		m.logger.Error("读取未完成的传输失败", zap.Error(err))
+		return
+	}
+	for _, st := range states {
+		var err error
+		switch st.Kind {
+		case kindUpload:
+			localPath, pathErr := m.LocalPath(st.Remote)
+			if pathErr != nil {
+				m.logger.Error("恢复传输失败", zap.String("path", m.DisplayPath(st.Remote)), zap.Error(pathErr))
+				m.removeState(kindUpload, st.Remote)
+				continue
+			}
+			if _, statErr := os.Stat(localPath); os.IsNotExist(statErr) {
+				m.removeState(kindUpload, st.Remote)
+				continue
+			}
+			m.logger.Info("恢复未完成的上传", zap.String("path", m.DisplayPath(st.Remote)), zap.Int64("offset", st.Confirmed))
+			err = m.Upload(localPath)
+		case kindDownload:
+			m.logger.Info("恢复未完成的下载", zap.String("path", m.DisplayPath(st.Remote)))
+			err = m.Download(st.Remote, apply)
+		}
+		if err != nil {
+			m.logger.Error("恢复传输失败", zap.String("path", m.DisplayPath(st.Remote)), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// client/internal/trash/trash.go
+package trash
+
+import (
+	"fmt"
+	"fsync/client/global"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// batchLayout 回收站批次目录的命名格式，按名称排序即按时间排序
+const batchLayout = "20060102-150405.000000000"
+
+// Trash 本地回收站，保存被远端删除或覆盖前的本地副本
+type Trash struct {
+	syncDir string
+	dir     string
+	maxAge  time.Duration
+	maxSize int64
+	logger  *zap.Logger
+
+	mutex sync.Mutex
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New 创建回收站实例，回收站位于 syncDir/.fsync/trash
+func New(syncDir string, maxAge time.Duration, maxSizeMB int64, logger *zap.Logger) (*Trash, error) {
+	dir := filepath.Join(syncDir, global.MetaDirName, "trash")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建回收站目录失败 %s: %w", dir, err)
+	}
+	return &Trash{
+		syncDir: syncDir,
+		dir:     dir,
+		maxAge:  maxAge,
+		maxSize: maxSizeMB * 1024 * 1024,
+		logger:  logger,
+		quit:    make(chan struct{}),
+	}, nil
+}
+
+// Dir 返回回收站目录
+func (t *Trash) Dir() string {
+	return t.dir
+}
+
+// MoveToTrash 将同步目录中的文件或目录移入回收站，保留其相对路径，返回回收站中的新路径
+func (t *Trash) MoveToTrash(path string) (string, error) {
+	rel, err := filepath.Rel(t.syncDir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("路径不在同步目录内: %s", path)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	dest := filepath.Join(t.dir, time.Now().Format(batchLayout), rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return "", fmt.Errorf("创建回收站子目录失败: %w", err)
+	}
+	if err := os.Rename(path, dest); err != nil {
+		return "", fmt.Errorf("移动文件到回收站失败: %w", err)
+	}
+
+	t.logger.Info("文件已移入回收站", zap.String("file", path), zap.String("trash", dest))
+	return dest, nil
+}
+
+// Cleanup 按保留时长和总大小清理回收站，优先删除最旧的批次
+func (t *Trash) Cleanup() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return fmt.Errorf("读取回收站目录失败: %w", err)
+	}
+
+	type batch struct {
+		path    string
+		created time.Time
+		size    int64
+	}
+
+	batches := make([]batch, 0, len(entries))
+	var total int64
+	for _, entry := range entries {
+		path := filepath.Join(t.dir, entry.Name())
+		created, err := time.ParseInLocation(batchLayout, entry.Name(), time.Local)
+		if err != nil {
+			// 不是回收站创建的批次，回退到修改时间
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			created = info.ModTime()
+		}
+		size := dirSize(path)
+		total += size
+		batches = append(batches, batch{path: path, created: created, size: size})
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].created.Before(batches[j].created) })
+
+	now := time.Now()
+	for _, b := range batches {
+		expired := t.maxAge > 0 && now.Sub(b.created) > t.maxAge
+		oversize := t.maxSize > 0 && total > t.maxSize
+		if !expired && !oversize {
+			break
+		}
+		if err := os.RemoveAll(b.path); err != nil {
+			t.logger.Error("清理回收站失败", zap.String("path", b.path), zap.Error(err))
+			continue
+		}
+		total -= b.size
+		t.logger.Info("已清理回收站批次", zap.String("path", b.path), zap.Bool("expired", expired))
+	}
+	return nil
+}
+
+// StartCleaner 启动后台协程，按 interval 周期清理回收站
+func (t *Trash) StartCleaner(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		if err := t.Cleanup(); err != nil {
+			t.logger.Error("清理回收站失败", zap.Error(err))
+		}
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Cleanup(); err != nil {
+					t.logger.Error("清理回收站失败", zap.Error(err))
+				}
+			case <-t.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理协程
+func (t *Trash) Stop() {
+	close(t.quit)
+	t.wg.Wait()
+}
+
+// dirSize 统计目录下所有普通文件的大小
+func dirSize(root string) int64 {
+	var size int64
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/fsnotify/fsnotify"
 	"go.uber.org/zap"
@@ -17,6 +18,9 @@ func AddRecursive(watcher *fsnotify.Watcher, root string) error {
 			return nil
 		}
 		if d.IsDir() {
+			if d.Name() == global.MetaDirName {
+				return filepath.SkipDir
+			}
 			err := watcher.Add(path)
 			if err != nil {
 				global.Logger.Error("无法监控目录", zap.String("path", path), zap.Error(err))
@@ -28,8 +32,22 @@ func AddRecursive(watcher *fsnotify.Watcher, root string) error {
 	})
 }
 
-// WatchDirRecursive 递归监控目录，并自动监控新创建的子目录
-func WatchDirRecursive(root string, onChange func(event fsnotify.Event)) error {
+// IsMetaPath 判断路径是否位于内部数据目录（.fsync）中，与 AddRecursive 一致，任意层级的 .fsync 目录都不参与同步
+func IsMetaPath(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if part == global.MetaDirName {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchDirRecursive 递归监控目录，并自动监控新创建的子目录，直到 quit 关闭
+func WatchDirRecursive(root string, onChange func(event fsnotify.Event), quit <-chan struct{}) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
@@ -44,11 +62,18 @@ func WatchDirRecursive(root string, onChange func(event fsnotify.Event)) error {
 	// 2. 监听事件
 	for {
 		select {
+		case <-quit:
+			return nil
+
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return nil
 			}
 
+			if IsMetaPath(root, event.Name) {
+				continue
+			}
+
 			onChange(event)
 
 			if event.Op&fsnotify.Create == fsnotify.Create {
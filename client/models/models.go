@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Config 是整个应用的配置根结构体
 type Config struct {
 	Logger LoggerConfig `mapstructure:"logger"`
@@ -18,8 +20,82 @@ type LoggerConfig struct {
 }
 
 type ClientConfig struct {
-	SyncDir    string `mapstructure:"sync_dir"`
-	ServerAddr string `mapstructure:"server_addr"`
-	TokenDir   string `mapstructure:"token_dir"`
-	Protocol   string `mapstructure:"protocol"`
+	SyncDir         string            `mapstructure:"sync_dir"`
+	ServerAddr      string            `mapstructure:"server_addr"`
+	TokenDir        string            `mapstructure:"token_dir"`
+	Protocol        string            `mapstructure:"protocol"`
+	ControlSocket   string            `mapstructure:"control_socket"`   // 本地控制接口的 Unix 套接字，为空时使用 <sync_dir>/.fsync/control.sock
+	ShutdownTimeout time.Duration     `mapstructure:"shutdown_timeout"` // 退出时等待排队命令执行完成的最长时间
+	Ignore          []string          `mapstructure:"ignore"`           // 不同步的文件，模式语法同 path.Match
+	Trash           TrashConfig       `mapstructure:"trash"`
+	DeleteBrake     DeleteBrakeConfig `mapstructure:"delete_brake"`
+	Transfer        TransferConfig    `mapstructure:"transfer"`
+	Encryption      EncryptionConfig  `mapstructure:"encryption"`
+	Bandwidth       BandwidthConfig   `mapstructure:"bandwidth"`
+}
+
+// TrashConfig 本地回收站配置
+type TrashConfig struct {
+	MaxAge        time.Duration `mapstructure:"max_age"`        // 超过该时长的回收站条目会被清理
+	MaxSizeMB     int64         `mapstructure:"max_size_mb"`    // 回收站总大小上限，超出时从最旧的开始清理
+	CleanInterval time.Duration `mapstructure:"clean_interval"` // 后台清理周期
+}
+
+// DeleteBrakeConfig 大量删除保护配置
+type DeleteBrakeConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Window     time.Duration `mapstructure:"window"`      // 统计删除数量的时间窗口
+	MaxCount   int           `mapstructure:"max_count"`   // 窗口内允许的最大删除数量，0 表示不限制
+	MaxPercent float64       `mapstructure:"max_percent"` // 窗口内允许删除的跟踪文件百分比，0 表示不限制
+}
+
+// TransferConfig 文件传输配置
+type TransferConfig struct {
+	DeltaSync bool `mapstructure:"delta_sync"` // 使用内容定义分块的增量上传，只发送服务端缺失的分块
+
+	// PollInterval 拉取其他设备变更（覆盖和删除）的周期，0 表示不拉取
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+
+	// Compression 上传前的压缩编码：zstd、gzip 或空（不压缩）。已压缩的文件类型和压缩效果差的文件自动跳过
+	Compression     string `mapstructure:"compression"`
+	CompressMinSize int64  `mapstructure:"compress_min_size"` // 小于该字节数的文件不压缩
+}
+
+// EncryptionConfig 端到端加密配置，密钥通过 `encryption init` 初始化
+type EncryptionConfig struct {
+	Enabled bool      `mapstructure:"enabled"` // 上传前在本地加密，服务端只保存密文
+	KDF     KDFConfig `mapstructure:"kdf"`     // 口令派生密钥的 Argon2id 参数，仅影响之后设置的口令
+
+	// RootID 加密同步根目录的标识，同一根目录的所有设备使用相同的值，本机缓存的主密钥按它区分。
+	// 为空时使用 default
+	RootID string `mapstructure:"root_id"`
+}
+
+// KDFConfig Argon2id 参数，为 0 的项使用默认值，可用 `encryption calibrate` 测算
+type KDFConfig struct {
+	Time      uint32 `mapstructure:"time"`
+	MemoryKiB uint32 `mapstructure:"memory_kib"`
+	Threads   uint8  `mapstructure:"threads"`
+}
+
+// BandwidthConfig 传输限速配置，速率和大小写作 512KB、2MB 等，留空或 0 表示不限制
+type BandwidthConfig struct {
+	Upload   string          `mapstructure:"upload"`   // 上传每秒字节数
+	Download string          `mapstructure:"download"` // 下载每秒字节数
+	Schedule []BandwidthRule `mapstructure:"schedule"` // 按时段覆盖上面的速率，第一条匹配的规则生效
+	Metered  MeteredConfig   `mapstructure:"metered"`
+}
+
+// BandwidthRule 时段限速规则，End 早于 Start 时表示跨越午夜
+type BandwidthRule struct {
+	Start    string `mapstructure:"start"` // HH:MM
+	End      string `mapstructure:"end"`   // HH:MM
+	Upload   string `mapstructure:"upload"`
+	Download string `mapstructure:"download"`
+}
+
+// MeteredConfig 计量模式配置，开启时超过 MaxSize 的传输被暂缓，关闭后自动继续
+type MeteredConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	MaxSize string `mapstructure:"max_size"`
 }
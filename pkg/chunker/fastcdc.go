@@ -0,0 +1,206 @@
+// pkg/chunker/fastcdc.go
+package chunker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// 默认分块参数：平均 1MB，适合大文件的增量同步
+const (
+	DefaultMinSize = 256 * 1024
+	DefaultAvgSize = 1024 * 1024
+	DefaultMaxSize = 4 * 1024 * 1024
+)
+
+// 分块上传前可能经过压缩和端到端加密，客户端和服务端都按 MaxEncodedSize 限制单个分块的大小
+const (
+	// CodecOverhead 压缩无法压缩的数据时帧头和存储块带来的额外字节上限，
+	// 最大分块经 zstd 约增加 110 字节、经 gzip 约增加 350 字节
+	CodecOverhead = 4 * 1024
+	// SealOverhead 端到端加密记录的额外字节：长度前缀 4、nonce 12、GCM 认证标签 16
+	SealOverhead = 4 + 12 + 16
+	// MaxEncodedSize 默认参数下编码后单个分块的最大字节数
+	MaxEncodedSize = DefaultMaxSize + CodecOverhead + SealOverhead
+)
+
+// gearSeed 生成 gear 表的固定种子。修改种子或生成方式会改变所有文件的分块边界，导致已有分块无法复用
+const gearSeed = 0x66737963 // "fsyc"
+
+var gear [256]uint64
+
+func init() {
+	// splitmix64，保证各平台生成相同的 gear 表
+	state := uint64(gearSeed)
+	for i := range gear {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// Chunk 一个内容定义的分块
+type Chunk struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Hash   string `json:"hash"` // 分块内容 SHA256
+	Data   []byte `json:"-"`    // 分块内容，仅在下一次调用 Next 之前有效
+}
+
+// Chunker 基于 FastCDC（gear 滚动哈希 + 归一化分块）的流式分块器
+type Chunker struct {
+	r       io.Reader
+	minSize int
+	avgSize int
+	maxSize int
+	maskS   uint64 // 小于平均大小时使用的严格掩码
+	maskL   uint64 // 超过平均大小后使用的宽松掩码
+
+	buf    []byte
+	start  int // buf 中未消费数据的起始位置
+	end    int // buf 中有效数据的结束位置
+	eof    bool
+	offset int64
+}
+
+// New 使用默认参数创建分块器
+func New(r io.Reader) *Chunker {
+	c, _ := NewWithSizes(r, DefaultMinSize, DefaultAvgSize, DefaultMaxSize)
+	return c
+}
+
+// NewWithSizes 使用自定义参数创建分块器，avgSize 必须是 2 的幂且 minSize <= avgSize <= maxSize
+func NewWithSizes(r io.Reader, minSize, avgSize, maxSize int) (*Chunker, error) {
+	if minSize <= 0 || minSize > avgSize || avgSize > maxSize {
+		return nil, fmt.Errorf("invalid chunk sizes: min=%d avg=%d max=%d", minSize, avgSize, maxSize)
+	}
+	if avgSize&(avgSize-1) != 0 {
+		return nil, fmt.Errorf("average chunk size must be a power of two: %d", avgSize)
+	}
+	avgBits := bits.TrailingZeros(uint(avgSize))
+	return &Chunker{
+		r:       r,
+		minSize: minSize,
+		avgSize: avgSize,
+		maxSize: maxSize,
+		maskS:   topMask(avgBits + 2),
+		maskL:   topMask(avgBits - 2),
+		buf:     make([]byte, maxSize*2),
+	}, nil
+}
+
+// topMask 返回最高 n 位为 1 的掩码。gear 哈希左移累积，高位受更多字节影响
+func topMask(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	return ^uint64(0) << (64 - n)
+}
+
+// Next 返回下一个分块，数据读完时返回 io.EOF
+func (c *Chunker) Next() (*Chunk, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	data := c.buf[c.start:c.end]
+	n := c.cutPoint(data)
+	chunk := data[:n]
+	sum := sha256.Sum256(chunk)
+
+	result := &Chunk{
+		Offset: c.offset,
+		Size:   int64(n),
+		Hash:   hex.EncodeToString(sum[:]),
+		Data:   chunk,
+	}
+	c.start += n
+	c.offset += int64(n)
+	return result, nil
+}
+
+// fill 保证缓冲区中至少有 maxSize 字节（或已读到末尾）
+func (c *Chunker) fill() error {
+	if c.eof || c.end-c.start >= c.maxSize {
+		return nil
+	}
+	// 将未消费的数据移到缓冲区开头
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end -= c.start
+	c.start = 0
+
+	for c.end < len(c.buf) {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c.end-c.start >= c.maxSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// cutPoint 计算分块边界
+func (c *Chunker) cutPoint(data []byte) int {
+	n := len(data)
+	if n <= c.minSize {
+		return n
+	}
+	if n > c.maxSize {
+		n = c.maxSize
+	}
+	normal := c.avgSize
+	if normal > n {
+		normal = n
+	}
+
+	var fp uint64
+	i := c.minSize
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// ChunkAll 对 r 的全部内容分块，返回不含数据的分块列表和整体 SHA256
+func ChunkAll(r io.Reader) ([]Chunk, string, error) {
+	whole := sha256.New()
+	c := New(io.TeeReader(r, whole))
+	chunks := make([]Chunk, 0)
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		chunk.Data = nil
+		chunks = append(chunks, *chunk)
+	}
+	return chunks, hex.EncodeToString(whole.Sum(nil)), nil
+}
@@ -0,0 +1,226 @@
+package chunker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+// 测试使用较小的分块参数，保持数据量小而分块数量足够多
+const (
+	testMinSize = 2 * 1024
+	testAvgSize = 8 * 1024
+	testMaxSize = 32 * 1024
+)
+
+// randomData 返回固定种子生成的伪随机数据
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// chunkBytes 以测试参数对 r 分块，返回带数据副本的分块列表
+func chunkBytes(t *testing.T, r io.Reader) []Chunk {
+	t.Helper()
+	c, err := NewWithSizes(r, testMinSize, testAvgSize, testMaxSize)
+	if err != nil {
+		t.Fatalf("NewWithSizes: %v", err)
+	}
+	var chunks []Chunk
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		chunk.Data = bytes.Clone(chunk.Data)
+		chunks = append(chunks, *chunk)
+	}
+}
+
+// hashes 返回分块哈希列表
+func hashes(chunks []Chunk) []string {
+	list := make([]string, len(chunks))
+	for i, c := range chunks {
+		list[i] = c.Hash
+	}
+	return list
+}
+
+// shared 返回 b 中同样出现在 a 中的分块数量
+func shared(a, b []Chunk) int {
+	seen := make(map[string]bool, len(a))
+	for _, c := range a {
+		seen[c.Hash] = true
+	}
+	n := 0
+	for _, c := range b {
+		if seen[c.Hash] {
+			n++
+		}
+	}
+	return n
+}
+
+func TestChunksCoverInputWithinLimits(t *testing.T) {
+	data := randomData(1, 1<<20)
+	chunks := chunkBytes(t, bytes.NewReader(data))
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+
+	var offset int64
+	for i, c := range chunks {
+		if c.Offset != offset {
+			t.Fatalf("chunk %d: offset = %d, want %d", i, c.Offset, offset)
+		}
+		if c.Size > testMaxSize {
+			t.Errorf("chunk %d: size %d exceeds max %d", i, c.Size, testMaxSize)
+		}
+		if i < len(chunks)-1 && c.Size < testMinSize {
+			t.Errorf("chunk %d: size %d below min %d", i, c.Size, testMinSize)
+		}
+		sum := sha256.Sum256(data[c.Offset : c.Offset+c.Size])
+		if c.Hash != hex.EncodeToString(sum[:]) {
+			t.Errorf("chunk %d: hash does not match its content", i)
+		}
+		if !bytes.Equal(c.Data, data[c.Offset:c.Offset+c.Size]) {
+			t.Errorf("chunk %d: data does not match the input", i)
+		}
+		offset += c.Size
+	}
+	if offset != int64(len(data)) {
+		t.Fatalf("chunks cover %d bytes, want %d", offset, len(data))
+	}
+}
+
+func TestLowEntropyDataCutsAtMax(t *testing.T) {
+	data := make([]byte, 5*testMaxSize+100)
+	chunks := chunkBytes(t, bytes.NewReader(data))
+	if len(chunks) != 6 {
+		t.Fatalf("got %d chunks, want 6", len(chunks))
+	}
+	for i, c := range chunks[:5] {
+		if c.Size != testMaxSize {
+			t.Errorf("chunk %d: size = %d, want %d", i, c.Size, testMaxSize)
+		}
+	}
+	if last := chunks[5]; last.Size != 100 {
+		t.Errorf("last chunk: size = %d, want 100", last.Size)
+	}
+}
+
+func TestSmallInputs(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want int
+	}{
+		{"empty", 0, 0},
+		{"one byte", 1, 1},
+		{"at min size", testMinSize, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkBytes(t, bytes.NewReader(randomData(2, tt.size)))
+			if len(chunks) != tt.want {
+				t.Fatalf("got %d chunks, want %d", len(chunks), tt.want)
+			}
+			if tt.want == 1 && chunks[0].Size != int64(tt.size) {
+				t.Errorf("size = %d, want %d", chunks[0].Size, tt.size)
+			}
+		})
+	}
+}
+
+func TestBoundariesIndependentOfReadSize(t *testing.T) {
+	data := randomData(3, 256*1024)
+	want := hashes(chunkBytes(t, bytes.NewReader(data)))
+	got := hashes(chunkBytes(t, iotest.HalfReader(iotest.OneByteReader(bytes.NewReader(data)))))
+	if len(got) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("chunk %d differs with one-byte reads", i)
+		}
+	}
+}
+
+func TestBoundariesStableUnderEdits(t *testing.T) {
+	data := randomData(4, 1<<20)
+	original := chunkBytes(t, bytes.NewReader(data))
+	insert := randomData(5, 100)
+	mid := len(data) / 2
+
+	tests := []struct {
+		name   string
+		edited []byte
+	}{
+		{"insert in the middle", append(append(bytes.Clone(data[:mid]), insert...), data[mid:]...)},
+		{"shift by prepending", append(bytes.Clone(insert), data...)},
+		{"delete in the middle", append(bytes.Clone(data[:mid]), data[mid+100:]...)},
+		{"overwrite in the middle", append(append(bytes.Clone(data[:mid]), insert...), data[mid+100:]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edited := chunkBytes(t, bytes.NewReader(tt.edited))
+			// 编辑只影响所在位置附近的分块，前后的边界重新对齐
+			if changed := len(edited) - shared(original, edited); changed > 3 {
+				t.Errorf("%d of %d chunks changed, want at most 3", changed, len(edited))
+			}
+		})
+	}
+}
+
+func TestNewWithSizesRejectsInvalidSizes(t *testing.T) {
+	tests := []struct {
+		name                      string
+		minSize, avgSize, maxSize int
+	}{
+		{"zero min", 0, 8192, 32768},
+		{"min above avg", 16384, 8192, 32768},
+		{"avg above max", 2048, 65536, 32768},
+		{"avg not a power of two", 2048, 10000, 32768},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWithSizes(bytes.NewReader(nil), tt.minSize, tt.avgSize, tt.maxSize); err == nil {
+				t.Fatal("NewWithSizes accepted invalid sizes")
+			}
+		})
+	}
+}
+
+func TestChunkAll(t *testing.T) {
+	data := randomData(6, 3*DefaultMaxSize)
+	chunks, whole, err := ChunkAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ChunkAll: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if whole != hex.EncodeToString(sum[:]) {
+		t.Errorf("whole hash = %s, want %x", whole, sum)
+	}
+	var total int64
+	for i, c := range chunks {
+		if c.Data != nil {
+			t.Errorf("chunk %d: data retained", i)
+		}
+		if c.Size > DefaultMaxSize || (i < len(chunks)-1 && c.Size < DefaultMinSize) {
+			t.Errorf("chunk %d: size %d outside [%d, %d]", i, c.Size, DefaultMinSize, DefaultMaxSize)
+		}
+		total += c.Size
+	}
+	if total != int64(len(data)) {
+		t.Errorf("chunks cover %d bytes, want %d", total, len(data))
+	}
+}
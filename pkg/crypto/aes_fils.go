@@ -3,85 +3,117 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-
-	"golang.org/x/crypto/argon2"
 )
 
 const (
-	saltLen   = 16
-	nonceLen  = 12
-	keyLen    = 32 // AES-256
-	timeCost  = 1
-	memoryKiB = 64 * 1024
-	threads   = 4
+	saltLen  = 16
+	nonceLen = 12
+	keyLen   = 32 // AES-256
 )
 
-// EncryptFile encrypts a file using the given password and salt.
-// The output file format is: [salt][nonce][ciphertext+tag]
+// EncryptFile encrypts a file using the given password and salt with
+// DefaultKDFParams. See EncryptFileWithParams.
 func EncryptFile(inputPath, outputPath string, password string, salt []byte) error {
+	return EncryptFileWithParams(inputPath, outputPath, password, salt, DefaultKDFParams)
+}
+
+// EncryptFileWithParams encrypts a file using the given password, salt and
+// Argon2id parameters. The output uses the streaming v2 format (see stream.go),
+// so files of any size are encrypted with constant memory. The salt and KDF
+// parameters are stored in the header, and decryption reads them from there.
+func EncryptFileWithParams(inputPath, outputPath string, password string, salt []byte, params KDFParams) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
 	if len(salt) != saltLen {
 		return fmt.Errorf("invalid salt length: expected %d bytes, got %d", saltLen, len(salt))
 	}
 
-	// Read plaintext
-	plaintext, err := os.ReadFile(inputPath)
+	inFile, err := os.Open(inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to read input file: %w", err)
 	}
+	defer inFile.Close()
 
-	// Derive key from password and salt
-	key := argon2.IDKey([]byte(password), salt, timeCost, memoryKiB, threads, keyLen)
-
-	// AES-GCM setup
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return fmt.Errorf("failed to create cipher: %w", err)
-	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return fmt.Errorf("failed to create GCM: %w", err)
-	}
-
-	// Generate random nonce
-	nonce := make([]byte, nonceLen)
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return fmt.Errorf("failed to generate nonce: %w", err)
-	}
-
-	// Encrypt
-	ciphertext := gcm.Seal(nil, nonce, plaintext, nil) // includes auth tag
-
-	// Write output: salt + nonce + ciphertext
 	outFile, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outFile.Close()
 
-	if _, err := outFile.Write(salt); err != nil {
-		return fmt.Errorf("failed to write salt: %w", err)
+	w, err := newStreamWriter(outFile, newArgon2Header(params, salt), []byte(password), DefaultSegmentSize)
+	if err != nil {
+		return err
 	}
-	if _, err := outFile.Write(nonce); err != nil {
-		return fmt.Errorf("failed to write nonce: %w", err)
+	if _, err := io.Copy(w, inFile); err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
 	}
-	if _, err := outFile.Write(ciphertext); err != nil {
-		return fmt.Errorf("failed to write ciphertext: %w", err)
+	if err := w.Close(); err != nil {
+		return err
 	}
-
-	return nil
+	return outFile.Close()
 }
 
 // DecryptFile decrypts a file and verifies that the salt in the file matches expectedSalt.
-// The input file format must be: [salt][nonce][ciphertext+tag]
+// Both the streaming v2 format and the legacy v1 format ([salt][nonce][ciphertext+tag])
+// are accepted. For v2 files expectedSalt may be nil, since the salt is read from the header.
+// The plaintext is written to a temporary file and only renamed to outputPath once the
+// whole input has been authenticated.
 func DecryptFile(inputPath, outputPath string, password string, expectedSalt []byte) error {
-	if len(expectedSalt) != saltLen {
+	if expectedSalt != nil && len(expectedSalt) != saltLen {
 		return fmt.Errorf("invalid expectedSalt length: expected %d bytes, got %d", saltLen, len(expectedSalt))
 	}
 
+	inFile, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read encrypted file: %w", err)
+	}
+	defer inFile.Close()
+
+	h, _, err := readStreamHeader(inFile)
+	if errors.Is(err, ErrNotStream) {
+		if expectedSalt == nil {
+			return fmt.Errorf("expectedSalt is required for v1 encrypted files")
+		}
+		return decryptFileV1(inputPath, outputPath, password, expectedSalt)
+	}
+	if err != nil {
+		return err
+	}
+	if expectedSalt != nil && !equal(h.salt, expectedSalt) {
+		return fmt.Errorf("salt mismatch: file contains different salt (possible wrong password or corrupted file)")
+	}
+	if _, err := inFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r, err := NewDecryptReader(inFile, password)
+	if err != nil {
+		return err
+	}
+	tmpPath := outputPath + ".tmp"
+	outFile, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write decrypted file: %w", err)
+	}
+	if _, err := io.Copy(outFile, r); err != nil {
+		outFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := outFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write decrypted file: %w", err)
+	}
+	return os.Rename(tmpPath, outputPath)
+}
+
+// decryptFileV1 decrypts the legacy single-message format: [salt][nonce][ciphertext+tag].
+func decryptFileV1(inputPath, outputPath string, password string, expectedSalt []byte) error {
 	// Read entire encrypted file
 	data, err := os.ReadFile(inputPath)
 	if err != nil {
@@ -104,8 +136,12 @@ func DecryptFile(inputPath, outputPath string, password string, expectedSalt []b
 	nonce := data[saltLen : saltLen+nonceLen]
 	ciphertext := data[saltLen+nonceLen:]
 
-	// Derive key using expectedSalt (same as fileSalt)
-	key := argon2.IDKey([]byte(password), expectedSalt, timeCost, memoryKiB, threads, keyLen)
+	// Derive key using expectedSalt (same as fileSalt); v1 files have no header,
+	// so they were always made with the legacy parameters
+	key, err := LegacyKDFParams.DeriveKey([]byte(password), expectedSalt, keyLen)
+	if err != nil {
+		return err
+	}
 
 	// AES-GCM setup
 	block, err := aes.NewCipher(key)
@@ -26,14 +26,9 @@ func GenerateSalt() ([]byte, error) {
 	return salt, err
 }
 
-
-
-// ReDeriveKey 使用已知 salt 和密码重新派生密钥（用于解密）
+// ReDeriveKey 使用已知 salt 和密码重新派生密钥（用于解密），参数固定为 LegacyKDFParams。
+// 新代码应使用 KDFParams.DeriveKey 并把参数与密文一起保存
 func ReDeriveKey(password string, salt []byte, keyLen int) []byte {
-	const (
-		time    = 1
-		memory  = 64 * 1024
-		threads = 4
-	)
-	return argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(keyLen))
+	p := LegacyKDFParams
+	return argon2.IDKey([]byte(password), salt, p.Time, p.MemoryKiB, p.Threads, uint32(keyLen))
 }
@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// KDFParams are the Argon2id cost parameters used to derive a key from a
+// password. They are stored next to everything they protect (in the v2 stream
+// header, or alongside a wrapped key), so the defaults can be raised over time
+// while existing ciphertexts stay readable with the parameters they were made with.
+type KDFParams struct {
+	Time      uint32 `json:"time"`
+	MemoryKiB uint32 `json:"memory_kib"`
+	Threads   uint8  `json:"threads"`
+}
+
+var (
+	// LegacyKDFParams are the fixed parameters of the v1 file format and of
+	// ReDeriveKey. They must never change.
+	LegacyKDFParams = KDFParams{Time: 1, MemoryKiB: 64 * 1024, Threads: 4}
+
+	// DefaultKDFParams are used for new ciphertexts when the caller does not
+	// choose parameters (RFC 9106, second recommended option).
+	DefaultKDFParams = KDFParams{Time: 3, MemoryKiB: 64 * 1024, Threads: 4}
+)
+
+// Bounds enforced on parameters read from untrusted headers, so a crafted file
+// cannot make decryption allocate unbounded memory or spin for hours.
+const (
+	minKDFMemoryKiB = 8 * 1024
+	maxKDFMemoryKiB = 4 * 1024 * 1024
+	maxKDFTime      = 64
+)
+
+// Validate reports whether the parameters are within the accepted bounds.
+func (p KDFParams) Validate() error {
+	if p.Time == 0 || p.Time > maxKDFTime {
+		return fmt.Errorf("invalid Argon2id time cost: %d (allowed 1-%d)", p.Time, maxKDFTime)
+	}
+	if p.MemoryKiB < minKDFMemoryKiB || p.MemoryKiB > maxKDFMemoryKiB {
+		return fmt.Errorf("invalid Argon2id memory: %d KiB (allowed %d-%d)", p.MemoryKiB, minKDFMemoryKiB, maxKDFMemoryKiB)
+	}
+	if p.Threads == 0 {
+		return fmt.Errorf("invalid Argon2id threads: %d", p.Threads)
+	}
+	return nil
+}
+
+// DeriveKey derives a keyLen-byte key from password and salt with these parameters.
+func (p KDFParams) DeriveKey(password, salt []byte, keyLen int) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return argon2.IDKey(password, salt, p.Time, p.MemoryKiB, p.Threads, uint32(keyLen)), nil
+}
+
+// Calibrate picks parameters whose derivation takes roughly target on the
+// current machine. Memory and threads are kept as given (falling back to the
+// defaults when zero) and the time cost is scaled; if a single pass is already
+// slower than target, memory is halved down to the minimum instead.
+func Calibrate(target time.Duration, memoryKiB uint32, threads uint8) (KDFParams, error) {
+	p := DefaultKDFParams
+	if memoryKiB != 0 {
+		p.MemoryKiB = memoryKiB
+	}
+	if threads != 0 {
+		p.Threads = threads
+	}
+	p.Time = 1
+	if err := p.Validate(); err != nil {
+		return p, err
+	}
+
+	salt := make([]byte, saltLen)
+	for {
+		elapsed := measureKDF(p, salt)
+		if elapsed > target && p.MemoryKiB/2 >= minKDFMemoryKiB {
+			p.MemoryKiB /= 2
+			continue
+		}
+		if elapsed > 0 {
+			t := int64(target / elapsed)
+			if t < 1 {
+				t = 1
+			}
+			if t > maxKDFTime {
+				t = maxKDFTime
+			}
+			p.Time = uint32(t)
+		}
+		return p, nil
+	}
+}
+
+// measureKDF times one derivation with p.
+func measureKDF(p KDFParams, salt []byte) time.Duration {
+	start := time.Now()
+	argon2.IDKey([]byte("calibration"), salt, p.Time, p.MemoryKiB, p.Threads, keyLen)
+	return time.Since(start)
+}
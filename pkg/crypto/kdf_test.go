@@ -0,0 +1,217 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fastKDFParams are the cheapest parameters Validate accepts, to keep tests quick.
+var fastKDFParams = KDFParams{Time: 1, MemoryKiB: minKDFMemoryKiB, Threads: 1}
+
+// testArgon2Header returns a marshaled Argon2id header with params, after
+// letting edit change any field.
+func testArgon2Header(params KDFParams, edit func(h *streamHeader)) []byte {
+	h := newArgon2Header(params, bytes.Repeat([]byte{7}, saltLen))
+	h.segmentSize = DefaultSegmentSize
+	h.noncePrefix = bytes.Repeat([]byte{9}, noncePrefixLen)
+	if edit != nil {
+		edit(h)
+	}
+	return h.marshal()
+}
+
+func TestKDFParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  KDFParams
+		wantErr string
+	}{
+		{"default", DefaultKDFParams, ""},
+		{"legacy", LegacyKDFParams, ""},
+		{"lower bounds", KDFParams{Time: 1, MemoryKiB: minKDFMemoryKiB, Threads: 1}, ""},
+		{"upper bounds", KDFParams{Time: maxKDFTime, MemoryKiB: maxKDFMemoryKiB, Threads: 255}, ""},
+		{"zero time", KDFParams{Time: 0, MemoryKiB: 64 * 1024, Threads: 4}, "time cost"},
+		{"time too high", KDFParams{Time: maxKDFTime + 1, MemoryKiB: 64 * 1024, Threads: 4}, "time cost"},
+		{"memory too low", KDFParams{Time: 1, MemoryKiB: minKDFMemoryKiB - 1, Threads: 4}, "memory"},
+		{"memory too high", KDFParams{Time: 1, MemoryKiB: maxKDFMemoryKiB + 1, Threads: 4}, "memory"},
+		{"zero threads", KDFParams{Time: 1, MemoryKiB: 64 * 1024, Threads: 0}, "threads"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStreamHeaderRoundTrip(t *testing.T) {
+	params := KDFParams{Time: 5, MemoryKiB: 12345, Threads: 3}
+	raw := testArgon2Header(params, nil)
+
+	h, got, err := readStreamHeader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("readStreamHeader: %v", err)
+	}
+	if !bytes.Equal(got, raw) {
+		t.Errorf("raw header = %x, want %x", got, raw)
+	}
+	if h.kdf != kdfArgon2id {
+		t.Errorf("kdf = %d, want %d", h.kdf, kdfArgon2id)
+	}
+	if h.kdfParams() != params {
+		t.Errorf("kdfParams() = %+v, want %+v", h.kdfParams(), params)
+	}
+	if !bytes.Equal(h.salt, bytes.Repeat([]byte{7}, saltLen)) {
+		t.Errorf("salt = %x", h.salt)
+	}
+	if h.segmentSize != DefaultSegmentSize {
+		t.Errorf("segmentSize = %d, want %d", h.segmentSize, DefaultSegmentSize)
+	}
+	if !bytes.Equal(h.noncePrefix, bytes.Repeat([]byte{9}, noncePrefixLen)) {
+		t.Errorf("noncePrefix = %x", h.noncePrefix)
+	}
+}
+
+func TestEncryptWithCustomParams(t *testing.T) {
+	params := KDFParams{Time: 2, MemoryKiB: minKDFMemoryKiB, Threads: 2}
+	plain := []byte("custom Argon2id parameters")
+
+	var buf bytes.Buffer
+	w, err := NewEncryptWriterWithParams(&buf, "password", params)
+	if err != nil {
+		t.Fatalf("NewEncryptWriterWithParams: %v", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	h, _, err := readStreamHeader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("readStreamHeader: %v", err)
+	}
+	if h.kdfParams() != params {
+		t.Fatalf("header params = %+v, want %+v", h.kdfParams(), params)
+	}
+
+	r, err := NewDecryptReader(bytes.NewReader(buf.Bytes()), "password")
+	if err != nil {
+		t.Fatalf("NewDecryptReader: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("decrypted %q, want %q", got, plain)
+	}
+
+	if _, err := NewEncryptWriterWithParams(io.Discard, "password", KDFParams{}); err == nil {
+		t.Fatal("NewEncryptWriterWithParams accepted zero parameters")
+	}
+}
+
+func TestInvalidStreamHeaders(t *testing.T) {
+	valid := testArgon2Header(fastKDFParams, nil)
+
+	tests := []struct {
+		name    string
+		header  []byte
+		wantIs  error
+		wantErr string
+	}{
+		{
+			name:   "bad magic",
+			header: append([]byte("FSYNCv3\x00"), valid[len(streamMagic):]...),
+			wantIs: ErrNotStream,
+		},
+		{
+			name: "wrong version",
+			header: func() []byte {
+				b := bytes.Clone(valid)
+				b[len(streamMagic)] = streamVersion + 1
+				return b
+			}(),
+			wantErr: "unsupported stream version",
+		},
+		{
+			name:    "short fixed header",
+			header:  valid[:len(streamMagic)+5],
+			wantIs:  io.ErrUnexpectedEOF,
+			wantErr: "failed to read header",
+		},
+		{
+			name:    "short salt and nonce prefix",
+			header:  valid[:len(valid)-3],
+			wantIs:  io.ErrUnexpectedEOF,
+			wantErr: "failed to read header",
+		},
+		{
+			name:    "zero segment size",
+			header:  testArgon2Header(fastKDFParams, func(h *streamHeader) { h.segmentSize = 0 }),
+			wantErr: "invalid segment size",
+		},
+		{
+			name:    "segment size too big",
+			header:  testArgon2Header(fastKDFParams, func(h *streamHeader) { h.segmentSize = maxSegmentSize + 1 }),
+			wantErr: "invalid segment size",
+		},
+		{
+			name:    "zero time",
+			header:  testArgon2Header(fastKDFParams, func(h *streamHeader) { h.time = 0 }),
+			wantErr: "time cost",
+		},
+		{
+			name:    "time too high",
+			header:  testArgon2Header(fastKDFParams, func(h *streamHeader) { h.time = maxKDFTime + 1 }),
+			wantErr: "time cost",
+		},
+		{
+			name:    "memory too low",
+			header:  testArgon2Header(fastKDFParams, func(h *streamHeader) { h.memoryKiB = minKDFMemoryKiB - 1 }),
+			wantErr: "memory",
+		},
+		{
+			name:    "memory too high",
+			header:  testArgon2Header(fastKDFParams, func(h *streamHeader) { h.memoryKiB = maxKDFMemoryKiB + 1 }),
+			wantErr: "memory",
+		},
+		{
+			name:    "zero threads",
+			header:  testArgon2Header(fastKDFParams, func(h *streamHeader) { h.threads = 0 }),
+			wantErr: "threads",
+		},
+		{
+			name:    "unknown kdf",
+			header:  testArgon2Header(fastKDFParams, func(h *streamHeader) { h.kdf = 9 }),
+			wantErr: "different kind of secret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDecryptReader(bytes.NewReader(tt.header), "password")
+			if err == nil {
+				t.Fatal("NewDecryptReader accepted an invalid header")
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("error = %v, want %v", err, tt.wantIs)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Streaming v2 format:
+//
+//	header:  magic(8) | version(1) | kdf(1) | time(4) | memoryKiB(4) | threads(1) |
+//	         saltLen(1) | salt | segmentSize(4) | noncePrefix(7)
+//	body:    segment_0 | segment_1 | ... | segment_n
+//
+// Every segment is sealed with AES-256-GCM under a per-file key derived from the
+// password (or a raw key) and the salt. The 12-byte nonce of segment i is
+// noncePrefix(7) | i as uint32 big-endian (4) | lastFlag(1), following the STREAM
+// construction: dropping, reordering or truncating segments makes decryption fail.
+// The whole header is authenticated as associated data of every segment.
+const (
+	streamVersion           = 2
+	noncePrefixLen          = 7
+	tagLen                  = 16
+	DefaultSegmentSize      = 64 * 1024
+	maxSegmentSize          = 16 * 1024 * 1024
+	streamKeyInfo           = "fsync stream v2"
+	kdfArgon2id        byte = 1 // key derived from a password with Argon2id
+	kdfRawKey          byte = 2 // caller supplies a 32-byte key directly
+)
+
+// streamMagic identifies v2 files. v1 files start with a random salt, so the
+// chance of a v1 file being mistaken for v2 is 2^-64.
+var streamMagic = []byte("FSYNCv2\x00")
+
+var (
+	// ErrNotStream is returned when the input does not start with a v2 header.
+	ErrNotStream = errors.New("not a v2 encrypted stream")
+	// ErrTruncated is returned when the stream ends before its final segment.
+	ErrTruncated = errors.New("encrypted stream is truncated")
+)
+
+// streamHeader holds the parsed or to-be-written v2 header.
+type streamHeader struct {
+	kdf         byte
+	time        uint32
+	memoryKiB   uint32
+	threads     uint8
+	salt        []byte
+	segmentSize uint32
+	noncePrefix []byte
+}
+
+func (h *streamHeader) marshal() []byte {
+	var buf bytes.Buffer
+	buf.Write(streamMagic)
+	buf.WriteByte(streamVersion)
+	buf.WriteByte(h.kdf)
+	binary.Write(&buf, binary.BigEndian, h.time)
+	binary.Write(&buf, binary.BigEndian, h.memoryKiB)
+	buf.WriteByte(h.threads)
+	buf.WriteByte(byte(len(h.salt)))
+	buf.Write(h.salt)
+	binary.Write(&buf, binary.BigEndian, h.segmentSize)
+	buf.Write(h.noncePrefix)
+	return buf.Bytes()
+}
+
+// readStreamHeader parses a v2 header and returns it with its raw bytes.
+func readStreamHeader(r io.Reader) (*streamHeader, []byte, error) {
+	fixed := make([]byte, len(streamMagic)+1+1+4+4+1+1)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if !bytes.Equal(fixed[:len(streamMagic)], streamMagic) {
+		return nil, nil, ErrNotStream
+	}
+	p := fixed[len(streamMagic):]
+	if p[0] != streamVersion {
+		return nil, nil, fmt.Errorf("unsupported stream version: %d", p[0])
+	}
+	h := &streamHeader{
+		kdf:       p[1],
+		time:      binary.BigEndian.Uint32(p[2:6]),
+		memoryKiB: binary.BigEndian.Uint32(p[6:10]),
+		threads:   p[10],
+	}
+	saltLength := int(p[11])
+
+	rest := make([]byte, saltLength+4+noncePrefixLen)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	h.salt = rest[:saltLength]
+	h.segmentSize = binary.BigEndian.Uint32(rest[saltLength : saltLength+4])
+	h.noncePrefix = rest[saltLength+4:]
+
+	if h.segmentSize == 0 || h.segmentSize > maxSegmentSize {
+		return nil, nil, fmt.Errorf("invalid segment size: %d", h.segmentSize)
+	}
+	return h, append(fixed, rest...), nil
+}
+
+// deriveStreamKey derives the per-file AEAD from the header and the secret.
+func deriveStreamKey(h *streamHeader, secret []byte) (cipher.AEAD, error) {
+	var ikm []byte
+	switch h.kdf {
+	case kdfArgon2id:
+		var err error
+		ikm, err = h.kdfParams().DeriveKey(secret, h.salt, keyLen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Argon2id parameters in header: %w", err)
+		}
+	case kdfRawKey:
+		if len(secret) != keyLen {
+			return nil, fmt.Errorf("invalid key length: expected %d bytes, got %d", keyLen, len(secret))
+		}
+		ikm = secret
+	default:
+		return nil, fmt.Errorf("unsupported KDF: %d", h.kdf)
+	}
+
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, h.salt, []byte(streamKeyInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive stream key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// segmentNonce builds the STREAM nonce for segment i.
+func segmentNonce(prefix []byte, i uint32, last bool) []byte {
+	nonce := make([]byte, nonceLen)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixLen:], i)
+	if last {
+		nonce[nonceLen-1] = 1
+	}
+	return nonce
+}
+
+// streamWriter encrypts plaintext written to it into v2 segments.
+type streamWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+// kdfParams returns the Argon2id parameters recorded in the header.
+func (h *streamHeader) kdfParams() KDFParams {
+	return KDFParams{Time: h.time, MemoryKiB: h.memoryKiB, Threads: h.threads}
+}
+
+// newArgon2Header returns a header for a password-derived key with params.
+func newArgon2Header(params KDFParams, salt []byte) *streamHeader {
+	return &streamHeader{
+		kdf:       kdfArgon2id,
+		time:      params.Time,
+		memoryKiB: params.MemoryKiB,
+		threads:   params.Threads,
+		salt:      salt,
+	}
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it into
+// dst using a key derived from password with DefaultKDFParams. Close must be
+// called to write the final segment; it does not close dst.
+func NewEncryptWriter(dst io.Writer, password string) (io.WriteCloser, error) {
+	return NewEncryptWriterWithParams(dst, password, DefaultKDFParams)
+}
+
+// NewEncryptWriterWithParams is like NewEncryptWriter but uses the given
+// Argon2id parameters, which are recorded in the header.
+func NewEncryptWriterWithParams(dst io.Writer, password string, params KDFParams) (io.WriteCloser, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	salt, err := GenerateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return newStreamWriter(dst, newArgon2Header(params, salt), []byte(password), DefaultSegmentSize)
+}
+
+// NewEncryptWriterWithKey is like NewEncryptWriter but uses a 32-byte key
+// instead of a password, skipping the password KDF.
+func NewEncryptWriterWithKey(dst io.Writer, key []byte) (io.WriteCloser, error) {
+	salt, err := GenerateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return newStreamWriter(dst, &streamHeader{kdf: kdfRawKey, salt: salt}, key, DefaultSegmentSize)
+}
+
+func newStreamWriter(dst io.Writer, h *streamHeader, secret []byte, segmentSize int) (*streamWriter, error) {
+	h.segmentSize = uint32(segmentSize)
+	h.noncePrefix = make([]byte, noncePrefixLen)
+	if _, err := io.ReadFull(rand.Reader, h.noncePrefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+	aead, err := deriveStreamKey(h, secret)
+	if err != nil {
+		return nil, err
+	}
+	header := h.marshal()
+	if _, err := dst.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return &streamWriter{
+		dst:    dst,
+		aead:   aead,
+		header: header,
+		prefix: h.noncePrefix,
+		buf:    make([]byte, 0, segmentSize),
+	}, nil
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		// Keep a full segment buffered: only Close knows which segment is last.
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *streamWriter) flush(last bool) error {
+	if w.counter == ^uint32(0) {
+		return errors.New("encrypted stream exceeds maximum number of segments")
+	}
+	nonce := segmentNonce(w.prefix, w.counter, last)
+	sealed := w.aead.Seal(nil, nonce, w.buf, w.header)
+	if _, err := w.dst.Write(sealed); err != nil {
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close seals the final segment.
+func (w *streamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// streamReader decrypts v2 segments read from src.
+type streamReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	segSize int
+	counter uint32
+
+	cipherBuf []byte
+	lookahead []byte // first byte of the next segment, if any
+	plain     []byte
+	done      bool
+}
+
+// NewDecryptReader returns a reader that decrypts a v2 stream produced by
+// NewEncryptWriter. The KDF parameters and salt are taken from the header.
+// Reads fail if any segment was modified, reordered or the stream was truncated.
+func NewDecryptReader(src io.Reader, password string) (io.Reader, error) {
+	return newStreamReader(src, []byte(password), kdfArgon2id)
+}
+
+// NewDecryptReaderWithKey decrypts a stream produced by NewEncryptWriterWithKey.
+func NewDecryptReaderWithKey(src io.Reader, key []byte) (io.Reader, error) {
+	return newStreamReader(src, key, kdfRawKey)
+}
+
+func newStreamReader(src io.Reader, secret []byte, kdf byte) (*streamReader, error) {
+	h, header, err := readStreamHeader(src)
+	if err != nil {
+		return nil, err
+	}
+	if h.kdf != kdf {
+		return nil, fmt.Errorf("stream was encrypted with a different kind of secret (kdf %d)", h.kdf)
+	}
+	aead, err := deriveStreamKey(h, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{
+		src:       src,
+		aead:      aead,
+		header:    header,
+		prefix:    h.noncePrefix,
+		segSize:   int(h.segmentSize),
+		cipherBuf: make([]byte, int(h.segmentSize)+tagLen+1),
+	}, nil
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// next reads and opens one segment. It reads one byte past the segment to learn
+// whether more data follows, which decides the expected last-segment flag.
+func (r *streamReader) next() error {
+	buf := r.cipherBuf[:0]
+	buf = append(buf, r.lookahead...)
+	r.lookahead = nil
+
+	n, err := io.ReadFull(r.src, r.cipherBuf[len(buf):r.segSize+tagLen+1])
+	buf = r.cipherBuf[:len(buf)+n]
+	last := false
+	switch {
+	case err == nil:
+		// A full segment plus one extra byte: this is not the last segment.
+		r.lookahead = []byte{buf[len(buf)-1]}
+		buf = buf[:len(buf)-1]
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		last = true
+	default:
+		return fmt.Errorf("failed to read segment: %w", err)
+	}
+	if len(buf) < tagLen {
+		return ErrTruncated
+	}
+
+	plain, err := r.aead.Open(buf[:0], segmentNonce(r.prefix, r.counter, last), buf, r.header)
+	if err != nil {
+		if last {
+			return fmt.Errorf("decryption failed (wrong password, tampered or truncated stream): %w", err)
+		}
+		return fmt.Errorf("decryption failed (wrong password or tampered stream): %w", err)
+	}
+	r.counter++
+	r.plain = plain
+	r.done = last
+	return nil
+}
+
+// EncryptStream encrypts src into dst in the v2 format.
+func EncryptStream(dst io.Writer, src io.Reader, password string) error {
+	w, err := NewEncryptWriter(dst, password)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// DecryptStream decrypts a v2 stream from src into dst.
+func DecryptStream(dst io.Writer, src io.Reader, password string) error {
+	r, err := NewDecryptReader(src, password)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+// IsStream reports whether prefix starts with the v2 stream magic.
+// At least 8 bytes are needed for a positive answer.
+func IsStream(prefix []byte) bool {
+	return len(prefix) >= len(streamMagic) && bytes.Equal(prefix[:len(streamMagic)], streamMagic)
+}
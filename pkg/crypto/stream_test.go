@@ -0,0 +1,158 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+const testSegmentSize = 16
+
+var testKey = bytes.Repeat([]byte{0x42}, keyLen)
+
+// sealTestStream encrypts plain with testKey using small segments, so tests can
+// manipulate individual segments.
+func sealTestStream(t *testing.T, plain []byte) (header, body []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newStreamWriter(&buf, &streamHeader{kdf: kdfRawKey, salt: bytes.Repeat([]byte{1}, 16)}, testKey, testSegmentSize)
+	if err != nil {
+		t.Fatalf("newStreamWriter: %v", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	out := buf.Bytes()
+	headerLen := len(w.header)
+	return out[:headerLen], out[headerLen:]
+}
+
+// segments splits a stream body into its sealed segments.
+func segments(body []byte) [][]byte {
+	var segs [][]byte
+	for len(body) > testSegmentSize+tagLen {
+		segs = append(segs, body[:testSegmentSize+tagLen])
+		body = body[testSegmentSize+tagLen:]
+	}
+	return append(segs, body)
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func openTestStream(stream []byte) ([]byte, error) {
+	r, err := NewDecryptReaderWithKey(bytes.NewReader(stream), testKey)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"one byte", 1},
+		{"short segment", testSegmentSize - 1},
+		{"exact segment", testSegmentSize},
+		{"segment plus one", testSegmentSize + 1},
+		{"exact segments", 3 * testSegmentSize},
+		{"partial last segment", 3*testSegmentSize + 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := make([]byte, tt.size)
+			for i := range plain {
+				plain[i] = byte(i)
+			}
+			header, body := sealTestStream(t, plain)
+			// A full final segment is sealed as the last one, no empty trailer follows
+			wantSegments := (tt.size + testSegmentSize - 1) / testSegmentSize
+			if wantSegments == 0 {
+				wantSegments = 1
+			}
+			if got := len(segments(body)); got != wantSegments {
+				t.Fatalf("segments = %d, want %d", got, wantSegments)
+			}
+			got, err := openTestStream(join(header, body))
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatalf("decrypted %d bytes, want %d", len(got), len(plain))
+			}
+		})
+	}
+}
+
+func TestStreamRejectsModifiedStreams(t *testing.T) {
+	plain := bytes.Repeat([]byte("0123456789"), 5) // 50 bytes: 3 full segments and a partial one
+	header, body := sealTestStream(t, plain)
+	segs := segments(body)
+	if len(segs) != 4 {
+		t.Fatalf("segments = %d, want 4", len(segs))
+	}
+
+	flip := func(b []byte, i int) []byte {
+		c := append([]byte(nil), b...)
+		c[i] ^= 0x01
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		stream  []byte
+		wantErr error // nil means any error
+	}{
+		{"drop last segment", join(header, segs[0], segs[1], segs[2]), nil},
+		{"truncate inside last segment", join(header, segs[0], segs[1], segs[2], segs[3][:len(segs[3])-1]), nil},
+		{"truncate to tag only", join(header, segs[0], segs[3][:tagLen-1]), ErrTruncated},
+		{"header only", header, ErrTruncated},
+		{"drop middle segment", join(header, segs[0], segs[2], segs[3]), nil},
+		{"swap segments", join(header, segs[1], segs[0], segs[2], segs[3]), nil},
+		{"duplicate segment", join(header, segs[0], segs[0], segs[1], segs[2], segs[3]), nil},
+		{"append garbage", join(header, body, []byte{0}), nil},
+		{"flip ciphertext byte", join(header, flip(body, 3)), nil},
+		{"flip tag byte", join(header, flip(body, len(body)-1)), nil},
+		{"flip salt byte", join(flip(header, len(header)-12), body), nil},
+		{"flip nonce prefix", join(flip(header, len(header)-1), body), nil},
+		{"bad magic", join(flip(header, 0), body), ErrNotStream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openTestStream(tt.stream)
+			if err == nil {
+				t.Fatal("decrypt succeeded, want error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStreamRejectsWrongSecret(t *testing.T) {
+	header, body := sealTestStream(t, []byte("secret data"))
+	stream := join(header, body)
+
+	wrongKey := bytes.Repeat([]byte{0x43}, keyLen)
+	r, err := NewDecryptReaderWithKey(bytes.NewReader(stream), wrongKey)
+	if err != nil {
+		t.Fatalf("NewDecryptReaderWithKey: %v", err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("decrypt with wrong key succeeded")
+	}
+
+	// A raw-key stream must not be accepted as a password stream.
+	if _, err := NewDecryptReader(bytes.NewReader(stream), "password"); err == nil {
+		t.Fatal("password reader accepted a raw-key stream")
+	}
+}
@@ -1,64 +1,325 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"time"
 )
 
-func GenerateSelfSignedCert(certFile, keyFile, host string) error {
-	// 生成 RSA 私钥
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+// KeyType 证书密钥算法
+type KeyType string
+
+const (
+	KeyECDSA   KeyType = "ecdsa"   // ECDSA P-256
+	KeyEd25519 KeyType = "ed25519" // Ed25519
+)
+
+// CertOptions 签发证书的参数
+type CertOptions struct {
+	CommonName string
+	Hosts      []string      // SAN 中的 IP 或域名
+	Validity   time.Duration // 有效期
+	KeyType    KeyType
+}
+
+// CA 本地证书颁发机构，签发服务端和客户端证书。客户端固定的是 CA 证书，
+// 续期叶子证书时沿用同一个 CA，已固定证书的客户端无需重新确认
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// GenerateKey 生成指定算法的私钥
+func GenerateKey(keyType KeyType) (crypto.Signer, error) {
+	switch keyType {
+	case KeyECDSA, "":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("不支持的密钥算法: %s", keyType)
+	}
+}
+
+// NewCA 生成新的本地 CA
+func NewCA(commonName string, validity time.Duration, keyType KeyType) (*CA, error) {
+	key, err := GenerateKey(keyType)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	template, err := newTemplate(commonName, validity)
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	template.MaxPathLenZero = true
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("生成 CA 证书失败: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// LoadCA 读取 CA 证书和私钥
+func LoadCA(certFile, keyFile string) (*CA, error) {
+	cert, err := ReadCertFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s 不是 CA 证书", certFile)
+	}
+	key, err := ReadKeyFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// LoadOrCreateCA 读取 CA，证书和私钥都不存在时生成新的 CA 并保存，返回值 created 表示是否新生成
+func LoadOrCreateCA(certFile, keyFile string, validity time.Duration, keyType KeyType) (ca *CA, created bool, err error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		ca, err = LoadCA(certFile, keyFile)
+		return ca, false, err
+	}
+	if !errors.Is(certErr, os.ErrNotExist) || !errors.Is(keyErr, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("CA 证书 %s 和私钥 %s 只存在其一，请恢复缺失的文件或同时删除两者", certFile, keyFile)
+	}
+	if ca, err = NewCA("fsync local CA", validity, keyType); err != nil {
+		return nil, false, err
+	}
+	if err := WriteCertFiles(certFile, keyFile, [][]byte{ca.Cert.Raw}, ca.Key); err != nil {
+		return nil, false, err
+	}
+	return ca, true, nil
+}
+
+// IssueServerCert 签发服务端证书，127.0.0.1 和 localhost 总是包含在 SAN 中
+func (ca *CA) IssueServerCert(opts CertOptions) (*x509.Certificate, crypto.Signer, error) {
+	hosts := append([]string{"127.0.0.1", "localhost"}, opts.Hosts...)
+	return ca.issue(opts, hosts, x509.ExtKeyUsageServerAuth)
+}
+
+// IssueClientCert 签发客户端证书，用于双向 TLS 认证
+func (ca *CA) IssueClientCert(opts CertOptions) (*x509.Certificate, crypto.Signer, error) {
+	return ca.issue(opts, opts.Hosts, x509.ExtKeyUsageClientAuth)
+}
+
+// SignClientCSR 按证书签名请求签发客户端证书，私钥留在设备上。主题使用 opts.CommonName，忽略请求中的主题和扩展
+func (ca *CA) SignClientCSR(csr *x509.CertificateRequest, opts CertOptions) (*x509.Certificate, error) {
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("证书签名请求的签名无效: %w", err)
+	}
+	template, err := ca.leafTemplate(opts, x509.ExtKeyUsageClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, csr.PublicKey, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("签发证书失败: %w", err)
+	}
+	return x509.ParseCertificate(der)
+}
+
+// issue 生成叶子证书的私钥并用 CA 签发
+func (ca *CA) issue(opts CertOptions, hosts []string, usage x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer, error) {
+	key, err := GenerateKey(opts.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := ca.leafTemplate(opts, usage)
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("签发证书失败: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
 	}
+	return cert, key, nil
+}
 
-	// 构造证书模板
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, _ := rand.Int(rand.Reader, serialNumberLimit)
+// leafTemplate 生成由 CA 签发的叶子证书模板，有效期不超过 CA
+func (ca *CA) leafTemplate(opts CertOptions, usage x509.ExtKeyUsage) (*x509.Certificate, error) {
+	template, err := newTemplate(opts.CommonName, opts.Validity)
+	if err != nil {
+		return nil, err
+	}
+	if template.NotAfter.After(ca.Cert.NotAfter) {
+		template.NotAfter = ca.Cert.NotAfter
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	return template, nil
+}
 
-	template := x509.Certificate{
+// newTemplate 生成带随机序列号的证书模板
+func newTemplate(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	if validity <= 0 {
+		return nil, fmt.Errorf("证书有效期必须大于 0")
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			Organization: []string{"fsync"},
-			CommonName:   host,
+			CommonName:   commonName,
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(365 *10 * 24 * time.Hour), // 10年有效期
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		NotBefore:             now.Add(-5 * time.Minute), // 容忍客户端时钟略慢
+		NotAfter:              now.Add(validity),
 		BasicConstraintsValid: true,
-		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
-		DNSNames:              []string{host, "localhost"},
+	}, nil
+}
+
+// WriteCertFiles 写入证书链和私钥。证书文件按 chain 顺序写入多张证书（叶子证书在前，CA 在后），私钥文件仅当前用户可读写。
+// 先写临时文件再重命名，避免运行中的服务读到写了一半的文件
+func WriteCertFiles(certFile, keyFile string, chain [][]byte, key crypto.Signer) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("编码私钥失败: %w", err)
 	}
+	certPEM := make([]byte, 0)
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	if err := writeFileAtomic(keyFile, keyPEM, 0600); err != nil {
+		return fmt.Errorf("写入私钥失败: %w", err)
+	}
+	if err := writeFileAtomic(certFile, certPEM, 0644); err != nil {
+		return fmt.Errorf("写入证书失败: %w", err)
+	}
+	return nil
+}
 
-	// 自签名
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+// writeFileAtomic 写入临时文件后重命名为目标文件，按需创建目录
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	// 写入证书文件
-	certOut, err := os.Create(certFile)
+// ReadCertFile 读取 PEM 文件中的第一张证书
+func ReadCertFile(path string) (*x509.Certificate, error) {
+	chain, err := ReadCertChain(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
-	certOut.Close()
+	return chain[0], nil
+}
 
-	// 写入私钥文件
-	keyOut, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600) 
+// ReadCertChain 读取 PEM 文件中的所有证书
+func ReadCertChain(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-	keyOut.Close()
+	chain := make([]*x509.Certificate, 0)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析证书 %s 失败: %w", path, err)
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%s 中没有证书", path)
+	}
+	return chain, nil
+}
 
-	return nil
+// ReadKeyFile 读取 PEM 格式的私钥，支持 PKCS#8、EC 和 PKCS#1 格式
+func ReadKeyFile(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s 中没有私钥", path)
+	}
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥 %s 失败: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("不支持的私钥类型: %T", key)
+	}
+	return signer, nil
 }
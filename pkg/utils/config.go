@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// ConfigProblems 配置校验发现的问题。校验时收集全部问题，一次性报告，而不是遇到第一个就返回
+type ConfigProblems []string
+
+// Add 记录一个问题，key 为配置项，如 server.port
+func (p *ConfigProblems) Add(key, format string, args ...interface{}) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+// Merge 追加 other 中的问题，跳过已有问题的配置项（如解析失败后又因取值为空校验失败）
+func (p ConfigProblems) Merge(other ConfigProblems) ConfigProblems {
+	reported := make(map[string]bool, len(p))
+	for _, problem := range p {
+		reported[problemKey(problem)] = true
+	}
+	for _, problem := range other {
+		if !reported[problemKey(problem)] {
+			p = append(p, problem)
+		}
+	}
+	return p
+}
+
+// problemKey 返回问题所属的配置项
+func problemKey(problem string) string {
+	key, _, _ := strings.Cut(problem, ": ")
+	return key
+}
+
+// Err 没有问题时返回 nil
+func (p ConfigProblems) Err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
+
+func (p ConfigProblems) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "配置有 %d 个问题:", len(p))
+	for _, problem := range p {
+		b.WriteString("\n  - ")
+		b.WriteString(problem)
+	}
+	return b.String()
+}
+
+// DecodeProblems 把解析配置文件的错误拆分为逐个配置项的问题
+func DecodeProblems(err error) ConfigProblems {
+	problems := make(ConfigProblems, 0)
+	var walk func(error)
+	walk = func(err error) {
+		if decodeErr, ok := err.(*mapstructure.DecodeError); ok {
+			problems = append(problems, decodeErr.Name()+": "+decodeErr.Unwrap().Error())
+			return
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				walk(e)
+			}
+			return
+		}
+		if wrapped := errors.Unwrap(err); wrapped != nil {
+			walk(wrapped)
+			return
+		}
+		problems = append(problems, err.Error())
+	}
+	walk(err)
+	return problems
+}
+
+// ConfigDecodeHook 解析配置时使用的类型转换：时长必须带单位（30s、5m），逗号分隔的字符串可作为列表（便于环境变量覆盖）
+func ConfigDecodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		durationHook,
+		mapstructure.StringToWeakSliceHookFunc(","),
+	)
+}
+
+// durationHook 解析时长，拒绝不带单位的非零数值，避免 30 被当作 30 纳秒
+func durationHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+	switch from.Kind() {
+	case reflect.String:
+		d, err := time.ParseDuration(data.(string))
+		if err != nil {
+			return nil, fmt.Errorf("无效的时长 %q，应写作 30s、5m、1h 等", data)
+		}
+		return d, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if !reflect.ValueOf(data).IsZero() {
+			return nil, fmt.Errorf("时长 %v 缺少单位，应写作 30s、5m、1h 等", data)
+		}
+	}
+	return data, nil
+}
+
+// ConfigKeys 返回配置结构体所有叶子配置项的键名，用于绑定环境变量（未出现在配置文件中的配置项也能被覆盖）
+func ConfigKeys(cfg interface{}) []string {
+	keys := make([]string, 0)
+	var walk func(prefix string, t reflect.Type)
+	walk = func(prefix string, t reflect.Type) {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+			keys = append(keys, prefix)
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.IsExported() {
+				walk(joinKey(prefix, field), field.Type)
+			}
+		}
+	}
+	walk("", reflect.TypeOf(cfg))
+	return keys
+}
+
+// ExpandPath 展开路径开头的 ~ 为当前用户的主目录
+func ExpandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path, fmt.Errorf("无法展开 ~: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// ChangedKeys 比较两份配置，返回取值不同的配置项，键名按 mapstructure 标签以 . 连接，如 server.port。
+// 嵌套结构体逐字段比较，其他类型（切片、映射等）整体比较
+func ChangedKeys(old, new interface{}) []string {
+	keys := make([]string, 0)
+	diffValue("", reflect.ValueOf(old), reflect.ValueOf(new), &keys)
+	return keys
+}
+
+// diffValue 递归比较两个值，把不同的配置项追加到 keys
+func diffValue(prefix string, a, b reflect.Value, keys *[]string) {
+	for a.Kind() == reflect.Pointer && b.Kind() == reflect.Pointer {
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*keys = append(*keys, prefix)
+			}
+			return
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Kind() != reflect.Struct || a.Type() != b.Type() {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*keys = append(*keys, prefix)
+		}
+		return
+	}
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		diffValue(joinKey(prefix, field), a.Field(i), b.Field(i), keys)
+	}
+}
+
+// joinKey 按字段的 mapstructure 标签拼接配置项键名
+func joinKey(prefix string, field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	if prefix != "" {
+		name = prefix + "." + name
+	}
+	return name
+}
+
+// KeyMatches 判断配置项是否属于 patterns 中的某一项本身或其子项
+func KeyMatches(key string, patterns []string) bool {
+	for _, p := range patterns {
+		if key == p || strings.HasPrefix(key, p+".") {
+			return true
+		}
+	}
+	return false
+}
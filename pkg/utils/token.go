@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"fsync/server/global"
 	"strings"
@@ -9,123 +10,114 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// 令牌类型，所有令牌用同一组密钥签名，靠类型区分，不能互相替代
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+	// TokenChallenge 开启两步验证的用户密码正确后签发，只能用于提交验证码
+	TokenChallenge = "totp_challenge"
+)
+
+// ChallengeTTL 两步验证挑战令牌的有效期，需在此时间内提交验证码
+const ChallengeTTL = 5 * time.Minute
+
+// TokenKeys 签名和校验令牌的密钥，服务端启动时通过 SetTokenKeys 设置
+type TokenKeys interface {
+	// Sign 用当前的签名密钥签名，并在头部写入 kid
+	Sign(claims jwt.Claims) (string, error)
+	// Keyfunc 按头部的 kid 返回校验用的公钥
+	Keyfunc(token *jwt.Token) (interface{}, error)
+	// Methods 可接受的签名算法
+	Methods() []string
+}
+
+var tokenKeys TokenKeys
+
+// SetTokenKeys 设置签名和校验令牌使用的密钥
+func SetTokenKeys(keys TokenKeys) {
+	tokenKeys = keys
+}
+
+// Claims 令牌中的声明。ID（jti）每个令牌唯一，用于吊销；SessionID 是一次登录的标识，
+// 刷新时签发的令牌沿用同一个 SessionID，吊销会话即吊销其中所有令牌；DeviceID 为登录的设备
 type Claims struct {
-	Username string `json:"username"`
+	Username  string `json:"username"`
+	TokenType string `json:"token_type"`
+	SessionID string `json:"sid,omitempty"`
+	DeviceID  string `json:"did,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenPair 访问令牌和刷新令牌，Claims 供服务端记录令牌，不返回给客户端
 type TokenPair struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-}
-
-func GenterateJWT(username string) (string, error) {
-	claims := &Claims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(global.Configs.JWT.AccessTokenExpire) * time.Second)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ID:        "1",
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(global.Configs.JWT.Access))
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
+	AccessToken   string  `json:"access_token"`
+	RefreshToken  string  `json:"refresh_token"`
+	DeviceID      string  `json:"device_id,omitempty"`
+	AccessClaims  *Claims `json:"-"`
+	RefreshClaims *Claims `json:"-"`
 }
 
-// GenerateTokenPair 生成访问令牌和刷新令牌
-func GenerateTokenPair(username string) (*TokenPair, error) {
-	// 生成访问令牌
-	accessClaims := &Claims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(global.Configs.JWT.AccessTokenExpire) * time.Second)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ID:        "1",
-		},
-	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(global.Configs.JWT.Access))
+// GenerateTokenPair 为设备 deviceID 上的会话 sessionID 生成访问令牌和刷新令牌，每个令牌有唯一的 jti
+func GenerateTokenPair(username, sessionID, deviceID string) (*TokenPair, error) {
+	cfg := global.Config().JWT
+	accessToken, accessClaims, err := newToken(username, sessionID, deviceID, TokenAccess,
+		time.Duration(cfg.AccessTokenExpire)*time.Second)
 	if err != nil {
 		return nil, err
 	}
-
-	// 生成刷新令牌
-	refreshClaims := &Claims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(global.Configs.JWT.RefreshTokenExpire) * time.Second)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ID:        "2",
-		},
-	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(global.Configs.JWT.Refresh))
+	refreshToken, refreshClaims, err := newToken(username, sessionID, deviceID, TokenRefresh,
+		time.Duration(cfg.RefreshTokenExpire)*time.Second)
 	if err != nil {
 		return nil, err
 	}
-
 	return &TokenPair{
-		AccessToken:  accessTokenString,
-		RefreshToken: refreshTokenString,
+		AccessToken:   accessToken,
+		RefreshToken:  refreshToken,
+		DeviceID:      deviceID,
+		AccessClaims:  accessClaims,
+		RefreshClaims: refreshClaims,
 	}, nil
 }
 
-// RefreshAccessToken 使用刷新令牌生成新的访问令牌
-func RefreshAccessToken(refreshTokenString string) (*TokenPair, error) {
-	// 解析刷新令牌
-	token, err := jwt.ParseWithClaims(refreshTokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// 确保签名方法是 HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(global.Configs.JWT.Refresh), nil
-	})
+// GenerateChallenge 签发两步验证的挑战令牌，证明用户已通过密码验证。返回的声明供服务端按 jti 记录挑战
+func GenerateChallenge(username string) (string, *Claims, error) {
+	return newToken(username, "", "", TokenChallenge, ChallengeTTL)
+}
 
+// ParseChallenge 解析两步验证的挑战令牌
+func ParseChallenge(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, TokenChallenge)
+}
+
+// newToken 签发一个令牌
+func newToken(username, sessionID, deviceID, tokenType string, expire time.Duration) (string, *Claims, error) {
+	if tokenKeys == nil {
+		return "", nil, errors.New("令牌签名密钥未加载")
+	}
+	id, err := NewUUID()
 	if err != nil {
-		return nil, fmt.Errorf("invalid refresh token: %w", err)
+		return "", nil, err
 	}
-
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		// 使用刷新令牌中的用户名生成新的访问令牌
-		newAccessToken, err := GenterateJWT(claims.Username)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate new access token: %w", err)
-		}
-
-		// 检查刷新令牌是否即将过期，如果需要则生成新的刷新令牌
-		timeUntilExpire := claims.ExpiresAt.Sub(time.Now())
-		newRefreshToken := refreshTokenString // 默认使用现有的刷新令牌
-
-		// 如果刷新令牌将在7天内过期，则生成新的刷新令牌
-		if timeUntilExpire < time.Hour*24*7 {
-			refreshClaims := &Claims{
-				Username: claims.Username,
-				RegisteredClaims: jwt.RegisteredClaims{
-					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(global.Configs.JWT.RefreshTokenExpire) * time.Second)),
-					IssuedAt:  jwt.NewNumericDate(time.Now()),
-					ID:        "2",
-				},
-			}
-			newRefreshTokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-			newRefreshToken, err = newRefreshTokenObj.SignedString([]byte(global.Configs.JWT.Refresh))
-			if err != nil {
-				return nil, fmt.Errorf("failed to generate new refresh token: %w", err)
-			}
-		}
-
-		return &TokenPair{
-			AccessToken:  newAccessToken,
-			RefreshToken: newRefreshToken,
-		}, nil
+	now := time.Now()
+	claims := &Claims{
+		Username:  username,
+		TokenType: tokenType,
+		SessionID: sessionID,
+		DeviceID:  deviceID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expire)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        id,
+		},
 	}
-
-	return nil, fmt.Errorf("invalid refresh token")
+	token, err := tokenKeys.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
 }
 
+// ParseToken 解析 Authorization 头中的访问令牌
 func ParseToken(tokenString string) (*Claims, error) {
 	// 检查token是否为空
 	if tokenString == "" {
@@ -137,46 +129,29 @@ func ParseToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("token must start with 'Bearer ' prefix")
 	}
 
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-
-	// 解析token
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// 确保签名方法是 HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(global.Configs.JWT.Access), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
-	}
-
-	return nil, fmt.Errorf("invalid token")
+	return parseToken(strings.TrimPrefix(tokenString, "Bearer "), TokenAccess)
 }
 
 // ParseRefreshToken 解析刷新令牌
 func ParseRefreshToken(tokenString string) (*Claims, error) {
-	// 解析刷新令牌
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// 确保签名方法是 HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(global.Configs.JWT.Refresh), nil
-	})
+	return parseToken(tokenString, TokenRefresh)
+}
 
+// parseToken 校验签名、有效期和令牌类型
+func parseToken(tokenString, tokenType string) (*Claims, error) {
+	if tokenKeys == nil {
+		return nil, errors.New("令牌校验密钥未加载")
+	}
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, tokenKeys.Keyfunc, jwt.WithValidMethods(tokenKeys.Methods()))
 	if err != nil {
 		return nil, err
 	}
-
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
 	}
-
-	return nil, fmt.Errorf("invalid refresh token")
+	if claims.TokenType != tokenType {
+		return nil, fmt.Errorf("token type is %q, want %q", claims.TokenType, tokenType)
+	}
+	return claims, nil
 }
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP 参数（RFC 6238），与常见的验证器应用兼容
+const (
+	TOTPPeriod = 30 * time.Second
+	TOTPDigits = 6
+	// totpSkew 允许的时钟偏差（前后各一个周期）
+	totpSkew = 1
+)
+
+// totpEncoding 密钥的 Base32 编码，不带填充
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret 生成 160 位随机密钥，返回 Base32 编码
+func NewTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPURI 返回 otpauth:// 链接，验证器应用扫描其二维码后添加账号
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// TOTPCode 计算时刻 t 的验证码
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("无效的 TOTP 密钥: %w", err)
+	}
+	return hotp(key, uint64(t.Unix()/int64(TOTPPeriod.Seconds()))), nil
+}
+
+// VerifyTOTP 校验验证码，允许前后一个周期的时钟偏差。通过时返回验证码所在的时间步，
+// 调用方记录已使用的时间步，拒绝不大于它的验证码，防止同一个验证码被重放
+func VerifyTOTP(secret, code string, t time.Time) (step int64, ok bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := t.Unix() / int64(TOTPPeriod.Seconds())
+	for i := -totpSkew; i <= totpSkew; i++ {
+		s := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(s))), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// hotp 计算 HOTP 值（RFC 4226）
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
+
+// recoveryAlphabet 恢复码使用的字符，去掉了容易混淆的 0、1、i、l、o
+const recoveryAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// NewRecoveryCodes 生成 n 个一次性恢复码，形如 k7m2-x9pq
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	// 丢弃超出字母表长度整数倍的随机字节，使每个字符等概率
+	limit := byte(256 - 256%len(recoveryAlphabet))
+	buf := make([]byte, 1)
+	for i := range codes {
+		var b strings.Builder
+		for b.Len() < 9 {
+			if b.Len() == 4 {
+				b.WriteByte('-')
+				continue
+			}
+			if _, err := rand.Read(buf); err != nil {
+				return nil, err
+			}
+			if buf[0] >= limit {
+				continue
+			}
+			b.WriteByte(recoveryAlphabet[int(buf[0])%len(recoveryAlphabet)])
+		}
+		codes[i] = b.String()
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode 统一恢复码的格式（小写、去掉空白和连字符），便于与保存的哈希比较
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.Join(strings.Fields(code), ""))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中 SHA1 测试向量的密钥 "12345678901234567890"，Base32 编码
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc6238Vectors RFC 6238 附录 B 的 SHA1 测试向量，验证码取 8 位值的后 6 位
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		got, err := TOTPCode(rfc6238Secret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode(%d): %v", v.unix, err)
+		}
+		if got != v.code {
+			t.Errorf("TOTPCode(%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestVerifyTOTPRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step, ok := VerifyTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0))
+		if !ok {
+			t.Errorf("VerifyTOTP(%d, %s) rejected a valid code", v.unix, v.code)
+			continue
+		}
+		if want := v.unix / 30; step != want {
+			t.Errorf("VerifyTOTP(%d) step = %d, want %d", v.unix, step, want)
+		}
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / 30
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"current step", 0, true},
+		{"previous step", -TOTPPeriod, true},
+		{"next step", TOTPPeriod, true},
+		{"two steps ago", -2 * TOTPPeriod, false},
+		{"two steps ahead", 2 * TOTPPeriod, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := TOTPCode(rfc6238Secret, now.Add(tt.offset))
+			if err != nil {
+				t.Fatalf("TOTPCode: %v", err)
+			}
+			step, ok := VerifyTOTP(rfc6238Secret, code, now)
+			if ok != tt.ok {
+				t.Fatalf("VerifyTOTP ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && step != current+int64(tt.offset/TOTPPeriod) {
+				t.Errorf("step = %d, want %d", step, current+int64(tt.offset/TOTPPeriod))
+			}
+		})
+	}
+}
+
+// TestVerifyTOTPReplayStep 调用方拒绝不大于已使用时间步的验证码：同一个验证码在有效窗口内再次提交时
+// 返回相同的时间步，而之后周期的验证码时间步更大
+func TestVerifyTOTPReplayStep(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := TOTPCode(rfc6238Secret, now)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	first, ok := VerifyTOTP(rfc6238Secret, code, now)
+	if !ok {
+		t.Fatal("VerifyTOTP rejected a valid code")
+	}
+	// 下一个周期内重放同一个验证码仍落在时钟偏差窗口内，时间步不变，会被调用方拒绝
+	replayed, ok := VerifyTOTP(rfc6238Secret, code, now.Add(TOTPPeriod))
+	if !ok || replayed != first {
+		t.Fatalf("replayed code: step = %d, ok = %v, want step %d", replayed, ok, first)
+	}
+
+	next, err := TOTPCode(rfc6238Secret, now.Add(TOTPPeriod))
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	step, ok := VerifyTOTP(rfc6238Secret, next, now.Add(TOTPPeriod))
+	if !ok || step <= first {
+		t.Fatalf("next code: step = %d, ok = %v, want step > %d", step, ok, first)
+	}
+}
+
+func TestVerifyTOTPInvalidInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"spaces are ignored", rfc6238Secret, " 287 082 ", true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", true},
+		{"wrong code", rfc6238Secret, "287083", false},
+		{"too short", rfc6238Secret, "28708", false},
+		{"too long", rfc6238Secret, "2870820", false},
+		{"invalid secret", "not base32!", "287082", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := VerifyTOTP(tt.secret, tt.code, now); ok != tt.ok {
+				t.Errorf("VerifyTOTP ok = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"fsync/server/configs"
+	"fsync/server/global"
+	"fsync/server/internal/auth"
+	"fsync/server/internal/certs"
+	"fsync/server/logger"
+	"os"
+)
+
+// usage 命令行帮助
+const usage = `用法: fsync-server [命令]
+
+不带命令时启动服务。
+
+命令:
+  config check   检查配置文件和 FSYNC_ 开头的环境变量，列出所有问题
+  cert renew     用本地 CA 重新签发服务端证书，向运行中的服务发送 SIGHUP 后生效
+  jwt rotate     生成新的令牌签名密钥，向运行中的服务发送 SIGHUP 后用它签发令牌，旧密钥继续用于校验`
+
+// runCommand 执行命令行子命令，loadErr 为加载配置的结果，返回进程退出码
+func runCommand(args []string, loadErr error) int {
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "check":
+		// 一次列出配置文件和环境变量覆盖中的所有问题
+		if loadErr != nil {
+			fmt.Fprintf(os.Stderr, "%s\n%v\n", configs.File(), loadErr)
+			return 1
+		}
+		fmt.Printf("配置检查通过: %s\n", configs.File())
+		return 0
+	case len(args) == 2 && args[0] == "cert" && args[1] == "renew":
+		if loadErr != nil {
+			fmt.Fprintln(os.Stderr, loadErr)
+			return 1
+		}
+		if err := logger.InitLogger(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer logger.Sync()
+		if err := certs.Renew(global.Config().Server.TLS); err != nil {
+			fmt.Fprintln(os.Stderr, "续期证书失败:", err)
+			return 1
+		}
+		fmt.Println("已重新签发服务端证书，向运行中的服务发送 SIGHUP 后新的连接使用新证书")
+		return 0
+	case len(args) == 2 && args[0] == "jwt" && args[1] == "rotate":
+		if loadErr != nil {
+			fmt.Fprintln(os.Stderr, loadErr)
+			return 1
+		}
+		kid, err := auth.Rotate(global.Config().JWT)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "生成签名密钥失败:", err)
+			return 1
+		}
+		fmt.Printf("已生成新的令牌签名密钥 %s，向运行中的服务发送 SIGHUP 后生效\n", kid)
+		return 0
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+}
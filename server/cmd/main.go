@@ -1,23 +1,52 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"fsync/server/configs"
 	"fsync/server/global"
+	"fsync/server/internal/audit"
+	"fsync/server/internal/auth"
+	"fsync/server/internal/blobstore"
+	"fsync/server/internal/certs"
 	"fsync/server/internal/db"
+	"fsync/server/internal/loginguard"
+	device_model "fsync/server/internal/modules/device/model"
+	device_service "fsync/server/internal/modules/device/service"
+	file_model "fsync/server/internal/modules/file/model"
+	file_service "fsync/server/internal/modules/file/service"
+	user_model "fsync/server/internal/modules/user/model"
+	user_service "fsync/server/internal/modules/user/service"
 	"fsync/server/internal/routers"
 	"fsync/server/logger"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+// defaultShutdownTimeout 未配置 shutdown_timeout 时退出前等待请求完成的时间
+const defaultShutdownTimeout = 30 * time.Second
+
 func main() {
 	// 加载配置
-	if err := configs.LoadConfig("server/configs"); err != nil {
-		log.Panic("加载配置项错误")
-		panic(err)
+	err := configs.LoadConfig("server/configs")
+
+	// 带参数时执行命令行子命令，不启动服务
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], err))
 	}
-	log.Println("加载到配置", global.Configs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	log.Println("加载到配置", global.Config())
 
 	// 初始化日志
 	if err := logger.InitLogger(); err != nil {
@@ -27,6 +56,11 @@ func main() {
 	global.Logger.Info("初始化日志成功")
 	defer logger.Sync()
 
+	// 加载令牌签名密钥
+	if err := auth.Init(global.Config().JWT); err != nil {
+		global.Logger.Panic("加载令牌签名密钥失败", zap.Error(err))
+	}
+
 	// 初始化数据库
 	if err := db.InitDB(); err != nil {
 		global.Logger.Panic("初始化数据库失败")
@@ -34,13 +68,187 @@ func main() {
 	}
 	global.Logger.Info("初始化数据库成功")
 
+	// 迁移数据表
+	if err := file_service.MigratePathHash(global.DB); err != nil {
+		global.Logger.Panic("升级文件路径索引失败", zap.Error(err))
+	}
+	if err := file_service.MigrateKeyFileRoot(global.DB); err != nil {
+		global.Logger.Panic("升级密钥文件表失败", zap.Error(err))
+	}
+	if err := db.AutoMigrate(
+		&user_model.User{},
+		&file_model.FileMeta{},
+		&file_model.UploadSession{},
+		&file_model.UploadChunk{},
+		&file_model.UserChunk{},
+		&file_model.FileChange{},
+		&file_model.KeyFile{},
+		&device_model.Device{},
+		&user_model.RefreshToken{},
+		&user_model.RevokedToken{},
+		&user_model.RecoveryCode{},
+		&user_model.LoginChallenge{},
+		&audit.Entry{},
+		&loginguard.Attempt{},
+	); err != nil {
+		global.Logger.Panic("迁移数据表失败")
+		panic(err)
+	}
+
+	// 加载已吊销的访问令牌和设备
+	if err := user_service.LoadDenylist(); err != nil {
+		global.Logger.Panic("加载已吊销令牌失败", zap.Error(err))
+	}
+	if err := device_service.LoadRevoked(); err != nil {
+		global.Logger.Panic("加载已吊销设备失败", zap.Error(err))
+	}
+
+	// 登录失败记录的存储
+	if err := loginguard.Init(global.Config().LoginGuard); err != nil {
+		global.Logger.Panic("初始化登录防护失败", zap.Error(err))
+	}
+
+	// 初始化文件存储
+	blobs, err := blobstore.New(global.Config().Storage.DataDir)
+	if err != nil {
+		global.Logger.Panic("初始化文件存储失败", zap.Error(err))
+		panic(err)
+	}
+	global.Blobs = blobs
+
 	// 初始化路由
 	r := routers.InitRouter()
 
-	// 启动服务
-	if err := r.Run(global.Configs.Server.Host + global.Configs.Server.Port); err != nil {
-		global.Logger.Panic("启动服务失败")
-		panic(err)
+	// 启动服务。大文件上传下载的请求体和响应可能持续很久，只限制读取请求头的时间
+	addr := global.Config().Server.Addr()
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadHeaderTimeout: global.Config().Server.ReadTimeout,
+		// 记录每个连接认证的设备，设备被吊销时立即断开
+		ConnContext: device_service.ConnContext,
+		ConnState: func(conn net.Conn, state http.ConnState) {
+			if state == http.StateClosed {
+				device_service.ConnClosed(conn)
+			}
+		},
+	}
+	tlsCfg := global.Config().Server.TLS
+	quit := make(chan struct{})
+	defer close(quit)
+	user_service.WatchTokens(quit)
+	auth.Watch(quit)
+	loginguard.Watch(quit)
+	if tlsCfg.Enabled {
+		certManager, err = certs.NewManager(tlsCfg)
+		if err != nil {
+			global.Logger.Panic("加载 HTTPS 证书失败", zap.Error(err))
+		}
+		srv.TLSConfig = certManager.TLSConfig()
+		certManager.Watch(quit)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsCfg.Enabled {
+			// 证书已在 TLSConfig 中
+			serveErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+	global.Logger.Info("启动服务成功:", zap.String("addr", addr), zap.Bool("https", tlsCfg.Enabled))
+
+	// 配置文件变化时自动重新加载
+	configs.Watch(reloadConfig)
+
+	// 等待退出信号，SIGHUP 重新加载配置
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+wait:
+	for {
+		select {
+		case err := <-serveErr:
+			global.Logger.Error("服务异常退出", zap.Error(err))
+			break wait
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				reloadConfig()
+				reloadCert()
+				reloadKeys()
+				continue
+			}
+			global.Logger.Info("收到退出信号，正在退出", zap.String("signal", sig.String()))
+			break wait
+		}
+	}
+
+	// 停止接收新连接，等待进行中的请求完成，超时后强制关闭
+	timeout := global.Config().Server.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		global.Logger.Warn("等待请求完成超时，强制关闭连接", zap.Error(err))
+		srv.Close()
+	}
+	if err := db.Close(); err != nil {
+		global.Logger.Error("关闭数据库连接池失败", zap.Error(err))
+	}
+	global.Logger.Info("服务已退出")
+}
+
+// certManager 启用 HTTPS 时的证书，SIGHUP 时重新读取证书文件
+var certManager *certs.Manager
+
+// reloadMutex 串行化 SIGHUP 和配置文件变化触发的重新加载
+var reloadMutex sync.Mutex
+
+// reloadConfig 重新读取配置文件，校验通过后应用可以在运行中生效的修改（日志级别、CORS、令牌有效期、登录防护阈值、限流规则）；
+// 包含需要重启的修改（如监听地址、数据库连接）或校验失败时整体拒绝，继续使用当前配置
+func reloadConfig() {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	cfg, err := configs.Reload()
+	var changed []string
+	if err == nil {
+		changed, err = configs.CheckLive(global.Config(), cfg)
+	}
+	if err != nil {
+		global.Logger.Error("重新加载配置失败，继续使用当前配置", zap.Error(err))
+		return
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	// 日志级别已在校验时检查过
+	_ = logger.SetLevel(cfg.Logger.Level)
+
+	// 中间件和令牌签发每次使用时通过 global.Config() 读取，发布后立即生效
+	global.SetConfig(cfg)
+	global.Logger.Info("已重新加载配置", zap.Strings("changed", changed))
+}
+
+// reloadCert 重新读取证书文件，用于手动更换证书或 `cert renew` 之后
+func reloadCert() {
+	if certManager == nil {
+		return
+	}
+	if err := certManager.Reload(); err != nil {
+		global.Logger.Error("重新加载证书失败，继续使用当前证书", zap.Error(err))
+		return
+	}
+	global.Logger.Info("已重新加载证书")
+}
+
+// reloadKeys 重新读取令牌签名密钥，用于 `jwt rotate` 之后
+func reloadKeys() {
+	if err := auth.Reload(); err != nil {
+		global.Logger.Error("重新加载令牌签名密钥失败，继续使用当前密钥", zap.Error(err))
+		return
 	}
-	global.Logger.Info("启动服务成功:", zap.String("addr", global.Configs.Server.Host+global.Configs.Server.Port))
+	global.Logger.Info("已重新加载令牌签名密钥")
 }
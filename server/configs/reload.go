@@ -0,0 +1,49 @@
+package configs
+
+import (
+	"fmt"
+	"fsync/pkg/utils"
+	"fsync/server/models"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// LiveKeys 可以在运行中应用的配置项（含其子项），其他配置项的修改需要重启服务
+var LiveKeys = []string{
+	"logger.level",
+	"server.cors_enabled",
+	"server.cors_origins",
+	"jwt.access_token_expire",
+	"jwt.refresh_token_expire",
+	"login_guard.max_failures",
+	"login_guard.ip_max_failures",
+	"login_guard.base_delay",
+	"login_guard.max_delay",
+	"login_guard.lockout",
+	"login_guard.max_lockout",
+	"login_guard.reset_after",
+	"rate_limit",
+}
+
+// Watch 监听配置文件，文件变化时调用 onChange，由调用方重新加载并应用
+func Watch(onChange func()) {
+	viper.OnConfigChange(func(fsnotify.Event) { onChange() })
+	viper.WatchConfig()
+}
+
+// CheckLive 比较新旧配置，返回发生变化的配置项；包含无法在运行中应用的修改时返回错误
+func CheckLive(old, new *models.Config) ([]string, error) {
+	changed := utils.ChangedKeys(old, new)
+	rejected := make([]string, 0)
+	for _, key := range changed {
+		if !utils.KeyMatches(key, LiveKeys) {
+			rejected = append(rejected, key)
+		}
+	}
+	if len(rejected) > 0 {
+		return changed, fmt.Errorf("以下配置项的修改需要重启服务才能生效，本次重新加载已拒绝: %s", strings.Join(rejected, ", "))
+	}
+	return changed, nil
+}
@@ -0,0 +1,258 @@
+package configs
+
+import (
+	"fsync/pkg/utils"
+	"fsync/server/internal/auth"
+	"fsync/server/internal/certs"
+	"fsync/server/internal/loginguard"
+	"fsync/server/internal/middleware"
+	"fsync/server/models"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Validate 展开路径中的 ~ 并校验配置，返回发现的所有问题
+func Validate(cfg *models.Config) utils.ConfigProblems {
+	p := make(utils.ConfigProblems, 0)
+
+	// 应用
+	switch cfg.App.Env {
+	case "development", "staging", "production":
+	default:
+		p.Add("app.env", "无效的运行环境 %q，可选 development、staging、production", cfg.App.Env)
+	}
+
+	// HTTP 服务
+	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
+		p.Add("server.port", "端口 %d 应为 1-65535，写作数字，如 18084", cfg.Server.Port)
+	}
+	if cfg.Server.ReadTimeout < 0 {
+		p.Add("server.read_timeout", "不能为负数")
+	}
+	if cfg.Server.WriteTimeout < 0 {
+		p.Add("server.write_timeout", "不能为负数")
+	}
+	if cfg.Server.ShutdownTimeout < 0 {
+		p.Add("server.shutdown_timeout", "不能为负数")
+	}
+	if tlsCfg := &cfg.Server.TLS; tlsCfg.Enabled {
+		if tlsCfg.CertFile == "" {
+			p.Add("server.tls.cert_file", "启用 HTTPS 时不能为空")
+		} else {
+			expandPath(&p, "server.tls.cert_file", &tlsCfg.CertFile)
+		}
+		if tlsCfg.KeyFile == "" {
+			p.Add("server.tls.key_file", "启用 HTTPS 时不能为空")
+		} else {
+			expandPath(&p, "server.tls.key_file", &tlsCfg.KeyFile)
+		}
+		if tlsCfg.AutoGenerate {
+			if tlsCfg.CACertFile == "" {
+				p.Add("server.tls.ca_cert_file", "自动生成证书时不能为空")
+			} else {
+				expandPath(&p, "server.tls.ca_cert_file", &tlsCfg.CACertFile)
+			}
+			if tlsCfg.CAKeyFile == "" {
+				p.Add("server.tls.ca_key_file", "自动生成证书时不能为空")
+			} else {
+				expandPath(&p, "server.tls.ca_key_file", &tlsCfg.CAKeyFile)
+			}
+			if tlsCfg.KeyType != string(utils.KeyECDSA) && tlsCfg.KeyType != string(utils.KeyEd25519) {
+				p.Add("server.tls.key_type", "不支持的密钥算法 %q，可选 ecdsa、ed25519", tlsCfg.KeyType)
+			}
+			if tlsCfg.Validity <= 0 {
+				p.Add("server.tls.validity", "必须大于 0，如 2160h")
+			}
+			if tlsCfg.CAValidity < tlsCfg.Validity {
+				p.Add("server.tls.ca_validity", "不能短于 validity（%s）", tlsCfg.Validity)
+			}
+			if tlsCfg.RenewBefore < 0 || (tlsCfg.Validity > 0 && tlsCfg.RenewBefore >= tlsCfg.Validity) {
+				p.Add("server.tls.renew_before", "应为 0 到 validity（%s）之间", tlsCfg.Validity)
+			}
+		}
+		if _, ok := certs.MinVersions[tlsCfg.MinVersion]; !ok {
+			p.Add("server.tls.min_version", "无效的 TLS 版本 %q，可选 1.3、1.2", tlsCfg.MinVersion)
+		}
+		if tlsCfg.ExpiryWarning < 0 {
+			p.Add("server.tls.expiry_warning", "不能为负数")
+		}
+		if tlsCfg.DeviceValidity <= 0 {
+			p.Add("server.tls.device_validity", "必须大于 0，如 8760h")
+		}
+		if tlsCfg.RequireDeviceCert && (tlsCfg.CACertFile == "" || tlsCfg.CAKeyFile == "") {
+			p.Add("server.tls.require_device_cert", "需要配置 ca_cert_file 和 ca_key_file 以签发设备证书")
+		}
+		for _, host := range tlsCfg.Hosts {
+			if host == "" || (strings.ContainsAny(host, " /:") && net.ParseIP(host) == nil) {
+				p.Add("server.tls.hosts", "无效的 IP 或域名 %q", host)
+			}
+		}
+	}
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				p.Add("server.trusted_proxies", "无效的地址 %q，应为 IP 或 CIDR，如 10.0.0.0/8", proxy)
+			}
+		}
+	}
+	for _, origin := range cfg.Server.CorsOrigins {
+		if origin == "*" {
+			p.Add("server.cors_origins", "不支持 *：允许携带凭据的跨域请求必须逐个列出来源")
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			p.Add("server.cors_origins", "无效的来源 %q，应写作 https://example.com", origin)
+		}
+	}
+
+	// 数据库
+	if cfg.Database.Driver != "mysql" {
+		p.Add("database.driver", "不支持的数据库 %q，目前只支持 mysql", cfg.Database.Driver)
+	}
+	if cfg.Database.Host == "" {
+		p.Add("database.host", "不能为空")
+	}
+	if cfg.Database.Port < 1 || cfg.Database.Port > 65535 {
+		p.Add("database.port", "端口 %d 应为 1-65535", cfg.Database.Port)
+	}
+	if cfg.Database.Name == "" {
+		p.Add("database.name", "不能为空")
+	}
+	if cfg.Database.Username == "" {
+		p.Add("database.username", "不能为空")
+	}
+	if cfg.Database.Charset == "" {
+		p.Add("database.charset", "不能为空，如 utf8mb4")
+	}
+	if cfg.Database.MaxOpenConns < 0 {
+		p.Add("database.max_open_conns", "不能为负数")
+	}
+	if cfg.Database.MaxIdleConns < 0 {
+		p.Add("database.max_idle_conns", "不能为负数")
+	} else if cfg.Database.MaxOpenConns > 0 && cfg.Database.MaxIdleConns > cfg.Database.MaxOpenConns {
+		p.Add("database.max_idle_conns", "不能大于 max_open_conns（%d）", cfg.Database.MaxOpenConns)
+	}
+	if cfg.Database.ConnMaxLifetime < 0 {
+		p.Add("database.conn_max_lifetime", "不能为负数")
+	}
+
+	// 日志
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(cfg.Logger.Level)); err != nil {
+		p.Add("logger.level", "无效的日志级别 %q，可选 debug、info、warn、error、dpanic、panic、fatal", cfg.Logger.Level)
+	}
+	if cfg.Logger.Format != "json" && cfg.Logger.Format != "console" {
+		p.Add("logger.format", "无效的日志格式 %q，可选 json、console", cfg.Logger.Format)
+	}
+	expandPaths(&p, "logger.output_paths", cfg.Logger.OutputPaths)
+	expandPaths(&p, "logger.error_output_paths", cfg.Logger.ErrorOutputPaths)
+
+	// JWT
+	if _, ok := auth.Methods[cfg.JWT.Algorithm]; !ok {
+		p.Add("jwt.algorithm", "不支持的签名算法 %q，可选 EdDSA、ES256", cfg.JWT.Algorithm)
+	}
+	if cfg.JWT.KeyDir == "" {
+		p.Add("jwt.key_dir", "不能为空")
+	} else {
+		expandPath(&p, "jwt.key_dir", &cfg.JWT.KeyDir)
+	}
+	if cfg.JWT.RotateAfter <= 0 {
+		p.Add("jwt.rotate_after", "必须大于 0，如 720h")
+	}
+	if cfg.JWT.AccessTokenExpire <= 0 {
+		p.Add("jwt.access_token_expire", "必须大于 0（秒）")
+	}
+	if cfg.JWT.RefreshTokenExpire <= 0 {
+		p.Add("jwt.refresh_token_expire", "必须大于 0（秒）")
+	} else if cfg.JWT.RefreshTokenExpire < cfg.JWT.AccessTokenExpire {
+		p.Add("jwt.refresh_token_expire", "不应短于 access_token_expire（%d 秒）", cfg.JWT.AccessTokenExpire)
+	}
+
+	// 登录防护
+	guard := cfg.LoginGuard
+	if guard.Store != loginguard.StoreMemory && guard.Store != loginguard.StoreDatabase {
+		p.Add("login_guard.store", "不支持的存储 %q，可选 memory、database", guard.Store)
+	}
+	if guard.MaxFailures < 0 {
+		p.Add("login_guard.max_failures", "不能为负数")
+	}
+	if guard.IPMaxFailures < 0 {
+		p.Add("login_guard.ip_max_failures", "不能为负数")
+	}
+	if guard.BaseDelay < 0 {
+		p.Add("login_guard.base_delay", "不能为负数")
+	}
+	if guard.MaxDelay < 0 {
+		p.Add("login_guard.max_delay", "不能为负数")
+	}
+	if (guard.MaxFailures > 0 || guard.IPMaxFailures > 0) && guard.Lockout <= 0 {
+		p.Add("login_guard.lockout", "启用锁定时必须大于 0，如 15m")
+	}
+	if guard.MaxLockout < 0 {
+		p.Add("login_guard.max_lockout", "不能为负数")
+	}
+	if guard.ResetAfter <= 0 {
+		p.Add("login_guard.reset_after", "必须大于 0，如 24h")
+	}
+
+	// 接口限流
+	names := make([]string, 0, len(cfg.RateLimit.Rules))
+	for name := range cfg.RateLimit.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rule, key := cfg.RateLimit.Rules[name], "rate_limit.rules."+name
+		switch name {
+		case middleware.RateLimitAuth, middleware.RateLimitAPI, middleware.RateLimitFile, middleware.RateLimitIP:
+		default:
+			p.Add(key, "未知的规则，可选 auth、api、file、ip")
+		}
+		if rule.Requests < 0 {
+			p.Add(key+".requests", "不能为负数")
+		}
+		if rule.Requests > 0 && rule.Per <= 0 {
+			p.Add(key+".per", "必须大于 0，如 1m")
+		}
+		if rule.Burst < 0 {
+			p.Add(key+".burst", "不能为负数")
+		}
+	}
+
+	// 文件存储
+	if cfg.Storage.DataDir == "" {
+		p.Add("storage.data_dir", "不能为空")
+	} else {
+		expandPath(&p, "storage.data_dir", &cfg.Storage.DataDir)
+	}
+	if cfg.Storage.ChunkSize <= 0 {
+		p.Add("storage.chunk_size", "必须大于 0（字节）")
+	}
+	if cfg.Storage.MaxChunkSize < 0 {
+		p.Add("storage.max_chunk_size", "不能为负数")
+	} else if cfg.Storage.MaxChunkSize > 0 && cfg.Storage.MaxChunkSize < cfg.Storage.ChunkSize {
+		p.Add("storage.max_chunk_size", "不能小于 chunk_size（%d）", cfg.Storage.ChunkSize)
+	}
+	return p
+}
+
+// expandPath 展开单个路径中的 ~，失败时记录问题
+func expandPath(p *utils.ConfigProblems, key string, path *string) {
+	expanded, err := utils.ExpandPath(*path)
+	if err != nil {
+		p.Add(key, "%v", err)
+		return
+	}
+	*path = expanded
+}
+
+// expandPaths 展开日志输出路径中的 ~，stdout、stderr 保持不变
+func expandPaths(p *utils.ConfigProblems, key string, paths []string) {
+	for i := range paths {
+		expandPath(p, key, &paths[i])
+	}
+}
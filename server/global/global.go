@@ -1,14 +1,29 @@
 package global
 
 import (
+	"fsync/server/internal/blobstore"
 	"fsync/server/models"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 var (
-	Configs *models.Config
-	Logger  *zap.Logger
-	DB      *gorm.DB
+	Logger *zap.Logger
+	DB     *gorm.DB
+	Blobs  *blobstore.Store
 )
+
+// configs 当前生效的配置。重新加载时整体替换，读取方通过 Config 拿到的快照不会被并发修改
+var configs atomic.Pointer[models.Config]
+
+// Config 返回当前生效的配置。一次处理中需要读取多个配置项时应先保存返回值，避免前后读到不同版本
+func Config() *models.Config {
+	return configs.Load()
+}
+
+// SetConfig 发布新配置，之后调用 Config 的读取方立即看到新值
+func SetConfig(cfg *models.Config) {
+	configs.Store(cfg)
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"fsync/server/global"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 审计事件
+const (
+	EventLoginSucceeded = "login_succeeded"
+	EventLoginFailed    = "login_failed"
+	EventLoginThrottled = "login_throttled" // 锁定或等待期间的登录尝试，未校验密码
+	EventLocked         = "locked"          // 用户名或 IP 因连续失败被临时锁定
+	EventUnlocked       = "unlocked"        // 管理员解除锁定
+)
+
+// Entry 审计日志记录
+type Entry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Event     string    `gorm:"size:32;index;not null" json:"event"`
+	Username  string    `gorm:"size:64;index" json:"username"`
+	IP        string    `gorm:"size:64;index" json:"ip"`
+	Actor     string    `gorm:"size:64" json:"actor,omitempty"` // 执行操作的管理员，用户自己的操作为空
+	Detail    string    `gorm:"size:255" json:"detail,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 审计日志表名
+func (Entry) TableName() string {
+	return "audit_logs"
+}
+
+// Record 记录一条审计日志，同时写入日志和数据库。写入数据库失败只记录警告，不影响请求
+func Record(entry Entry) {
+	global.Logger.Info("审计", zap.String("event", entry.Event), zap.String("username", entry.Username),
+		zap.String("ip", entry.IP), zap.String("actor", entry.Actor), zap.String("detail", entry.Detail))
+	if err := global.DB.Create(&entry).Error; err != nil {
+		global.Logger.Warn("写入审计日志失败", zap.String("event", entry.Event), zap.Error(err))
+	}
+}
+
+// List 按时间倒序列出审计日志，username 不为空时只列出该用户的记录
+func List(username string, limit int) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	query := global.DB.Order("id DESC").Limit(limit)
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -1 +1,367 @@
-package auth
\ No newline at end of file
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"fsync/pkg/utils"
+	"fsync/server/global"
+	"fsync/server/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// checkInterval 运行中检查签名密钥是否需要轮换的周期
+const checkInterval = time.Hour
+
+// createdHeader 密钥文件 PEM 头中记录生成时间的字段
+const createdHeader = "Created"
+
+// Methods 配置中 algorithm 可选的签名算法
+var Methods = map[string]jwt.SigningMethod{
+	"EdDSA": jwt.SigningMethodEdDSA,
+	"ES256": jwt.SigningMethodES256,
+}
+
+// keyTypes 各签名算法使用的密钥
+var keyTypes = map[string]utils.KeyType{
+	"EdDSA": utils.KeyEd25519,
+	"ES256": utils.KeyECDSA,
+}
+
+// signingKey 一个签名密钥，kid 由公钥计算
+type signingKey struct {
+	id      string
+	alg     string
+	key     crypto.Signer
+	created time.Time
+}
+
+// KeySet 令牌签名密钥。最新的密钥用于签名，之前的密钥保留用于校验，直到它们签发的令牌全部过期，
+// 因此轮换密钥不会让已登录的用户掉线。密钥保存在 key_dir 下，每个密钥一个 PEM 文件
+type KeySet struct {
+	cfg   models.JWTConfig
+	mutex sync.RWMutex
+	keys  []*signingKey // 按生成时间排序，最后一个是当前的签名密钥
+}
+
+// keys 服务使用的签名密钥，由 Init 加载
+var keys *KeySet
+
+// Init 加载签名密钥，没有密钥、当前密钥已到轮换时间或算法与配置不同时生成新密钥，并设置为令牌签发和校验使用的密钥
+func Init(cfg models.JWTConfig) error {
+	ks := &KeySet{cfg: cfg}
+	if err := ks.Reload(); err != nil {
+		return err
+	}
+	if err := ks.check(); err != nil {
+		return err
+	}
+	keys = ks
+	utils.SetTokenKeys(ks)
+	return nil
+}
+
+// Reload 重新读取密钥目录，用于 `jwt rotate` 生成新密钥之后
+func (ks *KeySet) Reload() error {
+	loaded, err := loadKeys(ks.cfg.KeyDir)
+	if err != nil {
+		return err
+	}
+	ks.mutex.Lock()
+	ks.keys = loaded
+	ks.mutex.Unlock()
+	return nil
+}
+
+// Sign 用当前的签名密钥签名
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mutex.RLock()
+	if len(ks.keys) == 0 {
+		ks.mutex.RUnlock()
+		return "", errors.New("没有可用的签名密钥")
+	}
+	active := ks.keys[len(ks.keys)-1]
+	ks.mutex.RUnlock()
+
+	token := jwt.NewWithClaims(Methods[active.alg], claims)
+	token.Header["kid"] = active.id
+	return token.SignedString(active.key)
+}
+
+// Keyfunc 按 kid 查找校验密钥，并要求令牌的算法与密钥一致
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	for _, k := range ks.keys {
+		if k.id == kid {
+			if token.Method.Alg() != k.alg {
+				return nil, fmt.Errorf("kid %s 的算法是 %s，令牌声明的是 %s", kid, k.alg, token.Method.Alg())
+			}
+			return k.key.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("未知的签名密钥 %q", kid)
+}
+
+// Methods 可接受的签名算法
+func (ks *KeySet) Methods() []string {
+	methods := make([]string, 0, len(Methods))
+	for alg := range Methods {
+		methods = append(methods, alg)
+	}
+	return methods
+}
+
+// Rotate 生成新的签名密钥，之后签发的令牌使用它，返回新密钥的 kid
+func (ks *KeySet) Rotate() (string, error) {
+	k, err := generateKey(ks.cfg.KeyDir, ks.cfg.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	ks.mutex.Lock()
+	ks.keys = append(ks.keys, k)
+	ks.mutex.Unlock()
+	global.Logger.Info("已生成新的令牌签名密钥", zap.String("kid", k.id), zap.String("alg", k.alg))
+	return k.id, nil
+}
+
+// Watch 每小时检查一次是否需要轮换密钥、删除不再需要的旧密钥，直到 quit 关闭
+func Watch(quit <-chan struct{}) {
+	ks := keys
+	if ks == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := ks.check(); err != nil {
+					global.Logger.Error("检查令牌签名密钥失败", zap.Error(err))
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// check 当前密钥到了轮换时间或算法与配置不同时生成新密钥，并删除签发的令牌都已过期的旧密钥
+func (ks *KeySet) check() error {
+	ks.mutex.RLock()
+	var active *signingKey
+	if len(ks.keys) > 0 {
+		active = ks.keys[len(ks.keys)-1]
+	}
+	ks.mutex.RUnlock()
+	if active == nil || active.alg != ks.cfg.Algorithm || time.Since(active.created) >= ks.cfg.RotateAfter {
+		if _, err := ks.Rotate(); err != nil {
+			return err
+		}
+	}
+	ks.prune()
+	return nil
+}
+
+// prune 删除不再需要的旧密钥。密钥在下一个密钥生成时停止签名，此后最长有效的刷新令牌过期后即可删除
+func (ks *KeySet) prune() {
+	retain := time.Duration(max(ks.cfg.AccessTokenExpire, ks.cfg.RefreshTokenExpire)) * time.Second
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	kept := make([]*signingKey, 0, len(ks.keys))
+	for i, k := range ks.keys {
+		if i < len(ks.keys)-1 && time.Since(ks.keys[i+1].created) > retain {
+			if err := os.Remove(keyPath(ks.cfg.KeyDir, k.id)); err != nil && !os.IsNotExist(err) {
+				global.Logger.Warn("删除过期的令牌签名密钥失败", zap.String("kid", k.id), zap.Error(err))
+				kept = append(kept, k)
+				continue
+			}
+			global.Logger.Info("已删除过期的令牌签名密钥", zap.String("kid", k.id))
+			continue
+		}
+		kept = append(kept, k)
+	}
+	ks.keys = kept
+}
+
+// JWK JSON Web Key 中的公钥
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS 返回所有可用于校验令牌的公钥（/.well-known/jwks.json），其他服务可据此校验本服务签发的令牌
+func JWKS(ctx *gin.Context) {
+	set := make([]JWK, 0)
+	if keys != nil {
+		keys.mutex.RLock()
+		for _, k := range keys.keys {
+			set = append(set, publicJWK(k))
+		}
+		keys.mutex.RUnlock()
+	}
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, gin.H{"keys": set})
+}
+
+// publicJWK 把公钥编码为 JWK
+func publicJWK(k *signingKey) JWK {
+	jwk := JWK{Kid: k.id, Alg: k.alg, Use: "sig"}
+	switch pub := k.key.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	case *ecdsa.PublicKey:
+		// P-256 的坐标固定编码为 32 字节
+		jwk.Kty, jwk.Crv = "EC", "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))
+	}
+	return jwk
+}
+
+// Rotate 为命令行 `jwt rotate` 生成新的签名密钥，运行中的服务收到 SIGHUP 后开始使用
+func Rotate(cfg models.JWTConfig) (string, error) {
+	k, err := generateKey(cfg.KeyDir, cfg.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	return k.id, nil
+}
+
+// Reload 重新读取服务使用的签名密钥
+func Reload() error {
+	if keys == nil {
+		return nil
+	}
+	return keys.Reload()
+}
+
+// generateKey 生成签名密钥并写入密钥目录
+func generateKey(dir, alg string) (*signingKey, error) {
+	keyType, ok := keyTypes[alg]
+	if !ok {
+		return nil, fmt.Errorf("不支持的签名算法 %q", alg)
+	}
+	key, err := utils.GenerateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	id, err := keyID(key)
+	if err != nil {
+		return nil, err
+	}
+	k := &signingKey{id: id, alg: alg, key: key, created: time.Now()}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建密钥目录失败: %w", err)
+	}
+	block := &pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{createdHeader: k.created.UTC().Format(time.RFC3339Nano)},
+		Bytes:   der,
+	}
+	if err := os.WriteFile(keyPath(dir, id), pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, fmt.Errorf("保存签名密钥失败: %w", err)
+	}
+	return k, nil
+}
+
+// loadKeys 读取密钥目录下的所有签名密钥，目录不存在时返回空
+func loadKeys(dir string) ([]*signingKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取密钥目录失败: %w", err)
+	}
+	loaded := make([]*signingKey, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pem") {
+			continue
+		}
+		k, err := readKey(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, k)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].created.Before(loaded[j].created) })
+	return loaded, nil
+}
+
+// readKey 读取一个签名密钥文件
+func readKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取签名密钥失败: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s 不是 PEM 格式的私钥", path)
+	}
+	created, err := time.Parse(time.RFC3339Nano, block.Headers[createdHeader])
+	if err != nil {
+		return nil, fmt.Errorf("%s 缺少有效的 %s 时间: %w", path, createdHeader, err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析签名密钥 %s 失败: %w", path, err)
+	}
+	k := &signingKey{created: created}
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.alg, k.key = "EdDSA", key
+	case *ecdsa.PrivateKey:
+		k.alg, k.key = "ES256", key
+	default:
+		return nil, fmt.Errorf("%s 的密钥类型 %T 不支持", path, parsed)
+	}
+	if k.id, err = keyID(k.key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// keyID 由公钥计算 kid：PKIX 编码的 SHA-256 前 12 字节
+func keyID(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// keyPath 返回密钥文件路径
+func keyPath(dir, id string) string {
+	return filepath.Join(dir, id+".pem")
+}
@@ -0,0 +1,178 @@
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrMismatch 写入内容的大小或哈希与声明不一致
+var ErrMismatch = errors.New("内容校验失败")
+
+// Store 基于本地磁盘、按内容哈希寻址的对象存储
+type Store struct {
+	root string
+}
+
+// New 创建存储实例，并确保目录结构存在
+func New(root string) (*Store, error) {
+	for _, dir := range []string{"blobs", "chunks", "uploads", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0750); err != nil {
+			return nil, fmt.Errorf("创建存储目录失败: %w", err)
+		}
+	}
+	return &Store{root: root}, nil
+}
+
+// BlobPath 返回内容哈希对应的文件路径
+func (s *Store) BlobPath(hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(s.root, "blobs", hash)
+	}
+	return filepath.Join(s.root, "blobs", hash[:2], hash)
+}
+
+// HasBlob 判断对象是否已存在
+func (s *Store) HasBlob(hash string) bool {
+	_, err := os.Stat(s.BlobPath(hash))
+	return err == nil
+}
+
+// OpenBlob 打开对象用于读取
+func (s *Store) OpenBlob(hash string) (*os.File, error) {
+	return os.Open(s.BlobPath(hash))
+}
+
+// PutBlob 写入对象，写入过程中校验 SHA256，不匹配时丢弃。对象已存在时直接返回
+func (s *Store) PutBlob(hash string, src io.Reader) error {
+	if s.HasBlob(hash) {
+		_, err := io.Copy(io.Discard, src)
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "blob-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入对象失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入对象失败: %w", err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != hash {
+		return fmt.Errorf("%w: 对象哈希期望 %s，实际 %s", ErrMismatch, hash, got)
+	}
+
+	dest := s.BlobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return fmt.Errorf("创建对象目录失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// UploadDir 返回上传会话的分块暂存目录
+func (s *Store) UploadDir(sessionID string) string {
+	return filepath.Join(s.root, "uploads", sessionID)
+}
+
+// UploadChunkPath 返回上传会话中某个分块的暂存路径
+func (s *Store) UploadChunkPath(sessionID string, index int) string {
+	return filepath.Join(s.UploadDir(sessionID), fmt.Sprintf("%08d", index))
+}
+
+// WriteUploadChunk 写入上传分块，校验大小和 SHA256 后原子地落盘
+func (s *Store) WriteUploadChunk(sessionID string, index int, expectedSize int64, expectedHash string, src io.Reader) error {
+	if err := os.MkdirAll(s.UploadDir(sessionID), 0750); err != nil {
+		return fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.UploadDir(sessionID), "chunk-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(src, expectedSize+1))
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入分块失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入分块失败: %w", err)
+	}
+	if n != expectedSize {
+		return fmt.Errorf("%w: 分块大小期望 %d，实际 %d", ErrMismatch, expectedSize, n)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != expectedHash {
+		return fmt.Errorf("%w: 分块哈希期望 %s，实际 %s", ErrMismatch, expectedHash, got)
+	}
+	return os.Rename(tmp.Name(), s.UploadChunkPath(sessionID, index))
+}
+
+// RemoveUpload 删除上传会话的暂存分块
+func (s *Store) RemoveUpload(sessionID string) error {
+	return os.RemoveAll(s.UploadDir(sessionID))
+}
+
+// ChunkPath 返回内容定义分块（增量同步）的存储路径
+func (s *Store) ChunkPath(hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(s.root, "chunks", hash)
+	}
+	return filepath.Join(s.root, "chunks", hash[:2], hash)
+}
+
+// HasChunk 判断内容分块是否已存在
+func (s *Store) HasChunk(hash string) bool {
+	_, err := os.Stat(s.ChunkPath(hash))
+	return err == nil
+}
+
+// OpenChunk 打开内容分块用于读取
+func (s *Store) OpenChunk(hash string) (*os.File, error) {
+	return os.Open(s.ChunkPath(hash))
+}
+
+// PutChunk 写入内容分块并校验 SHA256，返回分块大小。
+// 分块已存在时仍完整读取并校验上传内容，不能仅凭哈希认定对方持有该内容
+func (s *Store) PutChunk(hash string, maxSize int64, src io.Reader) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "chunk-*")
+	if err != nil {
+		return 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(src, maxSize+1))
+	if err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("写入分块失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("写入分块失败: %w", err)
+	}
+	if n > maxSize {
+		return 0, fmt.Errorf("%w: 分块超过最大大小 %d", ErrMismatch, maxSize)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != hash {
+		return 0, fmt.Errorf("%w: 分块哈希期望 %s，实际 %s", ErrMismatch, hash, got)
+	}
+	if s.HasChunk(hash) {
+		return n, nil
+	}
+
+	dest := s.ChunkPath(hash)
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return 0, fmt.Errorf("创建分块目录失败: %w", err)
+	}
+	return n, os.Rename(tmp.Name(), dest)
+}
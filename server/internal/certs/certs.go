@@ -0,0 +1,186 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"fsync/pkg/utils"
+	"fsync/server/global"
+	"fsync/server/models"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// checkInterval 运行中检查证书有效期的周期
+const checkInterval = 24 * time.Hour
+
+// MinVersions 配置中 min_version 可选的取值
+var MinVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Manager 服务端 HTTPS 证书。自动生成的证书由本地 CA 签发，临近过期时用同一个 CA 续期，
+// 续期或重新加载后新的连接立即使用新证书，无需重启服务
+type Manager struct {
+	cfg     models.TLSConfig
+	current atomic.Pointer[tls.Certificate]
+}
+
+// NewManager 加载证书。证书或私钥不存在且开启 auto_generate 时先签发
+func NewManager(cfg models.TLSConfig) (*Manager, error) {
+	m := &Manager{cfg: cfg}
+	if !exists(cfg.CertFile) || !exists(cfg.KeyFile) {
+		if !cfg.AutoGenerate {
+			return nil, fmt.Errorf("证书文件 %s 或私钥文件 %s 不存在，请提供证书或开启 server.tls.auto_generate", cfg.CertFile, cfg.KeyFile)
+		}
+		if err := Renew(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TLSConfig 返回 HTTPS 服务使用的 TLS 配置，每次握手读取当前证书。
+// 本地 CA 存在时接受（不强制）由它签发的设备证书，是否必须携带由 DeviceAuth 中间件按路由决定
+func (m *Manager) TLSConfig() *tls.Config {
+	minVersion, ok := MinVersions[m.cfg.MinVersion]
+	if !ok {
+		minVersion = tls.VersionTLS13
+	}
+	cfg := &tls.Config{
+		MinVersion: minVersion,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return m.current.Load(), nil
+		},
+	}
+	if m.cfg.CACertFile != "" {
+		if ca, err := utils.ReadCertFile(m.cfg.CACertFile); err == nil {
+			pool := x509.NewCertPool()
+			pool.AddCert(ca)
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			cfg.ClientCAs = pool
+		} else {
+			global.Logger.Warn("无法读取本地 CA 证书，不接受设备证书", zap.String("ca_cert", m.cfg.CACertFile), zap.Error(err))
+		}
+	}
+	return cfg
+}
+
+// Reload 重新读取证书和私钥文件，用于手动更换或 `cert renew` 续期证书后
+func (m *Manager) Reload() error {
+	cert, err := tls.LoadX509KeyPair(m.cfg.CertFile, m.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("加载证书失败: %w", err)
+	}
+	m.current.Store(&cert)
+	return nil
+}
+
+// Renew 用本地 CA 重新签发服务端证书，CA 不存在时先生成。已固定 CA 证书的客户端信任续期后的证书
+func Renew(cfg models.TLSConfig) error {
+	ca, created, err := utils.LoadOrCreateCA(cfg.CACertFile, cfg.CAKeyFile, cfg.CAValidity, utils.KeyType(cfg.KeyType))
+	if err != nil {
+		return fmt.Errorf("加载本地 CA 失败: %w", err)
+	}
+	if created {
+		global.Logger.Info("已生成本地 CA", zap.String("ca_cert", cfg.CACertFile), zap.Time("not_after", ca.Cert.NotAfter))
+		if exists(cfg.CertFile) {
+			global.Logger.Warn("原有证书不是由本地 CA 签发的，续期后已登录的客户端需要执行 `trust reset` 并重新 login 确认新证书")
+		}
+	}
+	cert, key, err := ca.IssueServerCert(utils.CertOptions{
+		CommonName: "fsync server",
+		Hosts:      cfg.Hosts,
+		Validity:   cfg.Validity,
+		KeyType:    utils.KeyType(cfg.KeyType),
+	})
+	if err != nil {
+		return err
+	}
+	// 证书文件中附带 CA 证书，客户端据此校验证书链并固定 CA
+	if err := utils.WriteCertFiles(cfg.CertFile, cfg.KeyFile, [][]byte{cert.Raw, ca.Cert.Raw}, key); err != nil {
+		return err
+	}
+	global.Logger.Info("已签发服务端证书", zap.String("cert", cfg.CertFile),
+		zap.Strings("ips", ipStrings(cert.IPAddresses)), zap.Strings("dns", cert.DNSNames), zap.Time("not_after", cert.NotAfter))
+	return nil
+}
+
+// Watch 启动时和之后每天检查一次证书有效期，直到 quit 关闭
+func (m *Manager) Watch(quit <-chan struct{}) {
+	m.check()
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.check()
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// check 检查证书有效期：开启 auto_generate 时临近过期的证书自动续期，否则记录错误日志；CA 临近过期时同样告警
+func (m *Manager) check() {
+	leaf := m.current.Load().Leaf
+	left := time.Until(leaf.NotAfter)
+	if m.cfg.AutoGenerate && left < m.cfg.RenewBefore {
+		err := Renew(m.cfg)
+		if err == nil {
+			err = m.Reload()
+		}
+		if err == nil {
+			return
+		}
+		global.Logger.Error("续期证书失败", zap.Error(err))
+	}
+	warnExpiry("HTTPS 证书", m.cfg.CertFile, leaf.NotAfter, m.cfg.ExpiryWarning,
+		"请尽快更换证书，或执行 `fsync-server cert renew` 后发送 SIGHUP")
+
+	if ca, err := utils.ReadCertFile(m.cfg.CACertFile); err == nil {
+		warnExpiry("本地 CA 证书", m.cfg.CACertFile, ca.NotAfter, m.cfg.ExpiryWarning,
+			"删除 CA 证书和私钥后执行 `fsync-server cert renew` 生成新的 CA，之后所有客户端需要执行 `trust reset` 并重新 login")
+	} else if !errors.Is(err, os.ErrNotExist) {
+		global.Logger.Error("检查 CA 证书有效期失败", zap.Error(err))
+	}
+}
+
+// warnExpiry 证书已过期或剩余有效期不足 warnBefore 时记录错误日志
+func warnExpiry(name, file string, notAfter time.Time, warnBefore time.Duration, hint string) {
+	left := time.Until(notAfter)
+	switch {
+	case left <= 0:
+		global.Logger.Error("!!! "+name+"已过期，客户端将无法连接，"+hint,
+			zap.String("file", file), zap.Time("not_after", notAfter))
+	case left < warnBefore:
+		global.Logger.Error("!!! "+name+"即将过期，"+hint,
+			zap.String("file", file), zap.Time("not_after", notAfter), zap.Int("days_left", int(left.Hours()/24)))
+	}
+}
+
+// ipStrings 把 IP 列表转为字符串，用于日志
+func ipStrings(ips []net.IP) []string {
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
+
+// exists 判断文件是否存在
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
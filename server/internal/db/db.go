@@ -14,12 +14,12 @@ import (
 func InitDB() error {
 	// 构建不包含数据库名的DSN用于连接MySQL服务器
 	baseDSN := fmt.Sprintf("%s:%s@tcp(%s:%d)/?charset=%s&parseTime=%t&loc=Local",
-		global.Configs.Database.Username,
-		global.Configs.Database.Password,
-		global.Configs.Database.Host,
-		global.Configs.Database.Port,
-		global.Configs.Database.Charset,
-		global.Configs.Database.ParseTime,
+		global.Config().Database.Username,
+		global.Config().Database.Password,
+		global.Config().Database.Host,
+		global.Config().Database.Port,
+		global.Config().Database.Charset,
+		global.Config().Database.ParseTime,
 	)
 
 	// 先尝试连接到MySQL服务器
@@ -31,7 +31,7 @@ func InitDB() error {
 	defer sqlDB.Close()
 
 	// 检查数据库是否存在
-	dbName := global.Configs.Database.Name
+	dbName := global.Config().Database.Name
 	var exists int
 	query := "SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?"
 	err = sqlDB.QueryRow(query, dbName).Scan(&exists)
@@ -44,7 +44,7 @@ func InitDB() error {
 	if exists == 0 {
 		global.Logger.Info("数据库不存在，正在创建数据库", zap.String("database", dbName))
 		createQuery := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s` CHARACTER SET %s COLLATE %s_general_ci",
-			dbName, global.Configs.Database.Charset, global.Configs.Database.Charset)
+			dbName, global.Config().Database.Charset, global.Config().Database.Charset)
 		_, err = sqlDB.Exec(createQuery)
 		if err != nil {
 			global.Logger.Error("创建数据库失败", zap.Error(err))
@@ -57,13 +57,13 @@ func InitDB() error {
 
 	// 构建包含数据库名的DSN
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=%t&loc=Local",
-		global.Configs.Database.Username,
-		global.Configs.Database.Password,
-		global.Configs.Database.Host,
-		global.Configs.Database.Port,
-		global.Configs.Database.Name,
-		global.Configs.Database.Charset,
-		global.Configs.Database.ParseTime,
+		global.Config().Database.Username,
+		global.Config().Database.Password,
+		global.Config().Database.Host,
+		global.Config().Database.Port,
+		global.Config().Database.Name,
+		global.Config().Database.Charset,
+		global.Config().Database.ParseTime,
 	)
 
 	// 连接数据库
@@ -81,13 +81,13 @@ func InitDB() error {
 	}
 
 	// 设置连接池参数
-	sqlDB.SetMaxOpenConns(global.Configs.Database.MaxOpenConns)
-	sqlDB.SetMaxIdleConns(global.Configs.Database.MaxIdleConns)
-	sqlDB.SetConnMaxLifetime(global.Configs.Database.ConnMaxLifetime)
+	sqlDB.SetMaxOpenConns(global.Config().Database.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(global.Config().Database.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(global.Config().Database.ConnMaxLifetime)
 
 	global.Logger.Info("已配置连接池",
-		zap.Int("max_open_conns", global.Configs.Database.MaxOpenConns),
-		zap.Int("max_idle_conns", global.Configs.Database.MaxIdleConns))
+		zap.Int("max_open_conns", global.Config().Database.MaxOpenConns),
+		zap.Int("max_idle_conns", global.Config().Database.MaxIdleConns))
 
 	// 将数据库实例保存到全局变量
 	global.DB = db
@@ -120,3 +120,15 @@ func AutoMigrate(models ...interface{}) error {
 	global.Logger.Info("自动迁移完成")
 	return nil
 }
+
+// Close 关闭数据库连接池，在程序退出前调用
+func Close() error {
+	if global.DB == nil {
+		return nil
+	}
+	sqlDB, err := global.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,116 @@
+package loginguard
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Attempt DatabaseStore 保存的登录失败记录
+type Attempt struct {
+	ID          string `gorm:"primaryKey;size:128"` // user:<用户名> 或 ip:<地址>
+	Failures    int    `gorm:"not null"`
+	Lockouts    int    `gorm:"not null"`
+	LastFailure *time.Time
+	LockedUntil *time.Time `gorm:"index"`
+	UpdatedAt   time.Time  `gorm:"index"`
+}
+
+// TableName 登录失败记录表名
+func (Attempt) TableName() string {
+	return "login_attempts"
+}
+
+// record 转换为 Record，空时间转为零值
+func (a *Attempt) record() Record {
+	r := Record{Failures: a.Failures, Lockouts: a.Lockouts}
+	if a.LastFailure != nil {
+		r.LastFailure = *a.LastFailure
+	}
+	if a.LockedUntil != nil {
+		r.LockedUntil = *a.LockedUntil
+	}
+	return r
+}
+
+// apply 用 Record 更新记录，零值时间保存为 NULL
+func (a *Attempt) apply(r Record) {
+	a.Failures, a.Lockouts = r.Failures, r.Lockouts
+	a.LastFailure, a.LockedUntil = nil, nil
+	if !r.LastFailure.IsZero() {
+		a.LastFailure = &r.LastFailure
+	}
+	if !r.LockedUntil.IsZero() {
+		a.LockedUntil = &r.LockedUntil
+	}
+}
+
+// DatabaseStore 保存在数据库中的记录，连接同一个数据库的多个服务实例共享失败次数和锁定
+type DatabaseStore struct {
+	db *gorm.DB
+}
+
+// NewDatabaseStore 创建数据库存储，调用方负责迁移 Attempt 表
+func NewDatabaseStore(db *gorm.DB) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+// Get 返回 key 的记录，没有时返回零值
+func (s *DatabaseStore) Get(key string) (Record, error) {
+	var attempt Attempt
+	if err := s.db.Where("id = ?", key).First(&attempt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Record{}, nil
+		}
+		return Record{}, err
+	}
+	return attempt.record(), nil
+}
+
+// Update 在事务中锁定记录行后修改，多个实例同时记录失败时不会丢失计数
+func (s *DatabaseStore) Update(key string, fn func(*Record)) (Record, error) {
+	var record Record
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		attempt := Attempt{ID: key}
+		// 先插入空记录（已存在时忽略），之后总能锁定到这一行
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&attempt).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", key).First(&attempt).Error; err != nil {
+			return err
+		}
+		record = attempt.record()
+		fn(&record)
+		attempt.apply(record)
+		return tx.Save(&attempt).Error
+	})
+	return record, err
+}
+
+// Delete 删除 key 的记录
+func (s *DatabaseStore) Delete(key string) error {
+	return s.db.Where("id = ?", key).Delete(&Attempt{}).Error
+}
+
+// Locked 返回锁定到 now 之后的记录
+func (s *DatabaseStore) Locked(now time.Time) (map[string]Record, error) {
+	attempts := make([]Attempt, 0)
+	if err := s.db.Where("locked_until > ?", now).Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	locked := make(map[string]Record, len(attempts))
+	for i := range attempts {
+		locked[attempts[i].ID] = attempts[i].record()
+	}
+	return locked, nil
+}
+
+// Purge 删除在 before 之前就已没有失败和锁定的记录
+func (s *DatabaseStore) Purge(before time.Time) error {
+	return s.db.
+		Where("last_failure IS NULL OR last_failure < ?", before).
+		Where("locked_until IS NULL OR locked_until < ?", before).
+		Delete(&Attempt{}).Error
+}
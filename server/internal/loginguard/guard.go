@@ -0,0 +1,293 @@
+package loginguard
+
+import (
+	"errors"
+	"fmt"
+	"fsync/server/global"
+	"fsync/server/internal/audit"
+	"fsync/server/models"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 存储类型
+const (
+	StoreMemory   = "memory"
+	StoreDatabase = "database"
+)
+
+// purgeInterval 清理过期记录的间隔
+const purgeInterval = 10 * time.Minute
+
+// 记录的键前缀，用户名和 IP 分别计数
+const (
+	userPrefix = "user:"
+	ipPrefix   = "ip:"
+)
+
+// ErrInvalidIP 解除锁定时给出的 IP 无效
+var ErrInvalidIP = errors.New("无效的 IP 地址")
+
+var (
+	storeMutex sync.RWMutex
+	store      Store = NewMemoryStore()
+)
+
+// ThrottledError 用户名或 IP 正处于锁定或失败后的等待期间，本次尝试未校验密码
+type ThrottledError struct {
+	Locked     bool          // 因连续失败被临时锁定，否则只是需要等待
+	RetryAfter time.Duration // 距离可以再次尝试的时间
+}
+
+func (e *ThrottledError) Error() string {
+	wait := e.RetryAfter.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	if e.Locked {
+		return fmt.Sprintf("登录失败次数过多，已临时锁定，请 %s 后再试", wait)
+	}
+	return fmt.Sprintf("登录失败，请 %s 后再试", wait)
+}
+
+// Lockout 正在锁定中的用户名或 IP
+type Lockout struct {
+	Username    string    `json:"username,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+	LockedUntil time.Time `json:"locked_until"`
+	Lockouts    int       `json:"lockouts"` // 累计锁定次数
+}
+
+// Init 按配置选择存储：memory 只对当前服务实例有效，database 由连接同一数据库的所有实例共享
+func Init(cfg models.LoginGuardConfig) error {
+	switch cfg.Store {
+	case StoreMemory:
+		SetStore(NewMemoryStore())
+	case StoreDatabase:
+		SetStore(NewDatabaseStore(global.DB))
+	default:
+		return fmt.Errorf("不支持的登录防护存储 %q，可选 memory、database", cfg.Store)
+	}
+	return nil
+}
+
+// SetStore 使用自定义的存储（如 Redis），应在开始处理请求前调用
+func SetStore(s Store) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	store = s
+}
+
+// currentStore 返回正在使用的存储
+func currentStore() Store {
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	return store
+}
+
+// Check 在校验密码（或两步验证码）前调用：用户名或 IP 被锁定、或距上次失败未满等待时间时返回 *ThrottledError。
+// 读取记录失败时放行，避免存储故障导致所有用户无法登录
+func Check(username, ip string) error {
+	cfg := global.Config().LoginGuard
+	now := time.Now()
+	var wait time.Duration
+	locked := false
+	for _, key := range keys(username, ip) {
+		record, err := currentStore().Get(key)
+		if err != nil {
+			global.Logger.Warn("读取登录失败记录失败，放行本次登录", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		// 等待时间只作用于用户名，同一出口 IP 后面的其他用户不受影响
+		d, l := retryAfter(record, now, strings.HasPrefix(key, userPrefix), cfg)
+		wait = max(wait, d)
+		locked = locked || l
+	}
+	if wait > 0 {
+		return &ThrottledError{Locked: locked, RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail 记录一次失败的登录，用户名或 IP 连续失败达到阈值时临时锁定，reason 写入审计日志
+func Fail(username, ip, reason string) {
+	cfg := global.Config().LoginGuard
+	now := time.Now()
+	audit.Record(audit.Entry{Event: audit.EventLoginFailed, Username: username, IP: ip, Detail: reason})
+	for _, key := range keys(username, ip) {
+		threshold := cfg.MaxFailures
+		if strings.HasPrefix(key, ipPrefix) {
+			threshold = cfg.IPMaxFailures
+		}
+		locked := false
+		record, err := currentStore().Update(key, func(r *Record) {
+			locked = recordFailure(r, now, threshold, cfg)
+		})
+		if err != nil {
+			global.Logger.Warn("保存登录失败记录失败", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		if locked {
+			entry := audit.Entry{Event: audit.EventLocked, IP: ip,
+				Detail: fmt.Sprintf("第 %d 次锁定，至 %s", record.Lockouts, record.LockedUntil.Format(time.DateTime))}
+			if strings.HasPrefix(key, userPrefix) {
+				entry.Username = username
+			}
+			audit.Record(entry)
+		}
+	}
+}
+
+// Succeed 登录成功，清除用户名的失败记录。IP 的记录保留到过期，
+// 避免攻击者用自己的账号登录来清零同一 IP 上针对其他用户的失败次数
+func Succeed(username, ip string) {
+	if err := currentStore().Delete(userKey(username)); err != nil {
+		global.Logger.Warn("清除登录失败记录失败", zap.String("username", username), zap.Error(err))
+	}
+	audit.Record(audit.Entry{Event: audit.EventLoginSucceeded, Username: username, IP: ip})
+}
+
+// UnlockUser 管理员解除用户名的锁定并清零失败次数
+func UnlockUser(actor, username string) error {
+	if err := currentStore().Delete(userKey(username)); err != nil {
+		return fmt.Errorf("解除锁定失败: %w", err)
+	}
+	audit.Record(audit.Entry{Event: audit.EventUnlocked, Username: username, Actor: actor})
+	return nil
+}
+
+// UnlockIP 管理员解除 IP 的锁定并清零失败次数
+func UnlockIP(actor, ip string) error {
+	if net.ParseIP(ip) == nil {
+		return ErrInvalidIP
+	}
+	if err := currentStore().Delete(ipPrefix + ip); err != nil {
+		return fmt.Errorf("解除锁定失败: %w", err)
+	}
+	audit.Record(audit.Entry{Event: audit.EventUnlocked, IP: ip, Actor: actor})
+	return nil
+}
+
+// List 列出正在锁定中的用户名和 IP，按锁定结束时间排序
+func List() ([]Lockout, error) {
+	locked, err := currentStore().Locked(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("查询锁定记录失败: %w", err)
+	}
+	lockouts := make([]Lockout, 0, len(locked))
+	for key, record := range locked {
+		lockout := Lockout{LockedUntil: record.LockedUntil, Lockouts: record.Lockouts}
+		if name, ok := strings.CutPrefix(key, userPrefix); ok {
+			lockout.Username = name
+		} else {
+			lockout.IP = strings.TrimPrefix(key, ipPrefix)
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	sort.Slice(lockouts, func(i, j int) bool { return lockouts[i].LockedUntil.Before(lockouts[j].LockedUntil) })
+	return lockouts, nil
+}
+
+// Watch 定期清理超过 reset_after 没有失败的记录，直到 quit 关闭
+func Watch(quit <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				before := time.Now().Add(-global.Config().LoginGuard.ResetAfter)
+				if err := currentStore().Purge(before); err != nil {
+					global.Logger.Warn("清理登录失败记录失败", zap.Error(err))
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// keys 返回一次登录尝试对应的记录键。用户名不区分大小写，与数据库的默认排序规则一致
+func keys(username, ip string) []string {
+	keys := make([]string, 0, 2)
+	if username != "" {
+		keys = append(keys, userKey(username))
+	}
+	if ip != "" {
+		keys = append(keys, ipPrefix+ip)
+	}
+	return keys
+}
+
+// userKey 返回用户名的记录键
+func userKey(username string) string {
+	return userPrefix + strings.ToLower(username)
+}
+
+// retryAfter 返回记录要求的剩余等待时间，以及是否处于锁定中。withDelay 为 false 时只检查锁定，不计算失败后的等待
+func retryAfter(r Record, now time.Time, withDelay bool, cfg models.LoginGuardConfig) (time.Duration, bool) {
+	if stale(r, now, cfg) {
+		return 0, false
+	}
+	if d := r.LockedUntil.Sub(now); d > 0 {
+		return d, true
+	}
+	if withDelay && r.Failures > 0 {
+		if d := r.LastFailure.Add(delay(r.Failures, cfg)).Sub(now); d > 0 {
+			return d, false
+		}
+	}
+	return 0, false
+}
+
+// recordFailure 在记录上累计一次失败，连续失败达到 threshold（不大于 0 时不锁定）时锁定并清零失败次数，返回是否因此锁定
+func recordFailure(r *Record, now time.Time, threshold int, cfg models.LoginGuardConfig) bool {
+	if stale(*r, now, cfg) {
+		*r = Record{}
+	}
+	r.Failures++
+	r.LastFailure = now
+	if threshold <= 0 || r.Failures < threshold {
+		return false
+	}
+	r.LockedUntil = now.Add(lockoutDuration(r.Lockouts, cfg))
+	r.Lockouts++
+	r.Failures = 0
+	return true
+}
+
+// stale 判断记录是否已超过 reset_after 没有失败和锁定，此时视为没有记录
+func stale(r Record, now time.Time, cfg models.LoginGuardConfig) bool {
+	return cfg.ResetAfter > 0 && now.Sub(r.lastActive()) > cfg.ResetAfter
+}
+
+// delay 返回连续失败 failures 次后再次尝试前需要等待的时间，每多失败一次加倍，不超过 max_delay
+func delay(failures int, cfg models.LoginGuardConfig) time.Duration {
+	return backoff(cfg.BaseDelay, failures-1, cfg.MaxDelay)
+}
+
+// lockoutDuration 返回第 lockouts+1 次锁定的时长，每次加倍，不超过 max_lockout
+func lockoutDuration(lockouts int, cfg models.LoginGuardConfig) time.Duration {
+	return backoff(cfg.Lockout, lockouts, cfg.MaxLockout)
+}
+
+// backoff 返回 base*2^n，不超过 limit（limit 不大于 0 时不限制）
+func backoff(base time.Duration, n int, limit time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := float64(base) * math.Pow(2, float64(n))
+	if limit > 0 && d > float64(limit) {
+		return limit
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
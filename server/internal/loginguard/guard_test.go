@@ -0,0 +1,163 @@
+package loginguard
+
+import (
+	"fsync/server/models"
+	"math"
+	"testing"
+	"time"
+)
+
+var testConfig = models.LoginGuardConfig{
+	MaxFailures:   3,
+	IPMaxFailures: 5,
+	BaseDelay:     time.Second,
+	MaxDelay:      8 * time.Second,
+	Lockout:       15 * time.Minute,
+	MaxLockout:    2 * time.Hour,
+	ResetAfter:    24 * time.Hour,
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  time.Duration
+		n     int
+		limit time.Duration
+		want  time.Duration
+	}{
+		{"zero base", 0, 3, time.Minute, 0},
+		{"first", time.Second, 0, time.Minute, time.Second},
+		{"doubles", time.Second, 3, time.Minute, 8 * time.Second},
+		{"capped", time.Second, 10, time.Minute, time.Minute},
+		{"no limit", time.Second, 10, 0, 1024 * time.Second},
+		{"overflow without limit", time.Hour, 100, 0, time.Duration(math.MaxInt64)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoff(tt.base, tt.n, tt.limit); got != tt.want {
+				t.Errorf("backoff(%s, %d, %s) = %s, want %s", tt.base, tt.n, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelayDoublesUpToMax(t *testing.T) {
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, 8 * time.Second}
+	for i, w := range want {
+		if got := delay(i+1, testConfig); got != w {
+			t.Errorf("delay(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestLockoutDurationDoublesUpToMax(t *testing.T) {
+	want := []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 2 * time.Hour}
+	for i, w := range want {
+		if got := lockoutDuration(i, testConfig); got != w {
+			t.Errorf("lockoutDuration(%d) = %s, want %s", i, got, w)
+		}
+	}
+}
+
+func TestRecordFailureLocksAtThreshold(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var r Record
+	wantLockouts := []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 2 * time.Hour}
+	for round, lockout := range wantLockouts {
+		for i := 1; i <= testConfig.MaxFailures; i++ {
+			now = now.Add(time.Minute)
+			locked := recordFailure(&r, now, testConfig.MaxFailures, testConfig)
+			if locked != (i == testConfig.MaxFailures) {
+				t.Fatalf("round %d failure %d: locked = %v", round, i, locked)
+			}
+		}
+		if r.Failures != 0 {
+			t.Errorf("round %d: failures = %d after lockout, want 0", round, r.Failures)
+		}
+		if r.Lockouts != round+1 {
+			t.Errorf("round %d: lockouts = %d, want %d", round, r.Lockouts, round+1)
+		}
+		if got := r.LockedUntil.Sub(now); got != lockout {
+			t.Errorf("round %d: locked for %s, want %s", round, got, lockout)
+		}
+		now = r.LockedUntil
+	}
+}
+
+func TestRecordFailureWithoutThreshold(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var r Record
+	for i := 0; i < 100; i++ {
+		if recordFailure(&r, now, 0, testConfig) {
+			t.Fatal("locked although the threshold is 0")
+		}
+	}
+	if r.Failures != 100 || !r.LockedUntil.IsZero() {
+		t.Errorf("record = %+v, want 100 failures and no lockout", r)
+	}
+}
+
+func TestRecordFailureResetsStaleRecord(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := Record{Failures: 2, Lockouts: 3, LastFailure: now, LockedUntil: now.Add(time.Hour)}
+
+	// 锁定结束后超过 reset_after 没有失败，锁定时长重新从 lockout 开始计算
+	now = r.LockedUntil.Add(testConfig.ResetAfter + time.Second)
+	if recordFailure(&r, now, testConfig.MaxFailures, testConfig) {
+		t.Fatal("locked on the first failure after reset")
+	}
+	if r.Failures != 1 || r.Lockouts != 0 {
+		t.Fatalf("record = %+v, want 1 failure and 0 lockouts", r)
+	}
+	recordFailure(&r, now, testConfig.MaxFailures, testConfig)
+	recordFailure(&r, now, testConfig.MaxFailures, testConfig)
+	if got := r.LockedUntil.Sub(now); got != testConfig.Lockout {
+		t.Errorf("locked for %s, want %s", got, testConfig.Lockout)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		record     Record
+		withDelay  bool
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{"no record", Record{}, true, 0, false},
+		{"locked", Record{LockedUntil: now.Add(10 * time.Minute)}, true, 10 * time.Minute, true},
+		{"locked without delay", Record{LockedUntil: now.Add(10 * time.Minute)}, false, 10 * time.Minute, true},
+		{"lockout expired", Record{Lockouts: 1, LockedUntil: now.Add(-time.Second)}, true, 0, false},
+		{"first failure", Record{Failures: 1, LastFailure: now}, true, time.Second, false},
+		{"third failure", Record{Failures: 3, LastFailure: now.Add(-time.Second)}, true, 3 * time.Second, false},
+		{"delay elapsed", Record{Failures: 2, LastFailure: now.Add(-3 * time.Second)}, true, 0, false},
+		{"delay capped", Record{Failures: 20, LastFailure: now}, true, testConfig.MaxDelay, false},
+		{"ip records have no delay", Record{Failures: 4, LastFailure: now}, false, 0, false},
+		{"stale lockout", Record{LockedUntil: now.Add(-testConfig.ResetAfter - time.Second)}, true, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, locked := retryAfter(tt.record, now, tt.withDelay, testConfig)
+			if wait != tt.wantWait || locked != tt.wantLocked {
+				t.Errorf("retryAfter() = %s, %v, want %s, %v", wait, locked, tt.wantWait, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestThrottledErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  ThrottledError
+		want string
+	}{
+		{ThrottledError{Locked: true, RetryAfter: 90 * time.Second}, "登录失败次数过多，已临时锁定，请 1m30s 后再试"},
+		{ThrottledError{RetryAfter: 1500 * time.Millisecond}, "登录失败，请 2s 后再试"},
+		{ThrottledError{RetryAfter: 100 * time.Millisecond}, "登录失败，请 1s 后再试"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package loginguard
+
+import (
+	"sync"
+	"time"
+)
+
+// Record 一个用户名或 IP 的登录失败记录
+type Record struct {
+	Failures    int       // 上次锁定（或清零）以来的连续失败次数
+	Lockouts    int       // 已被锁定的次数，下一次锁定的时长按它加倍
+	LastFailure time.Time // 最近一次失败的时间
+	LockedUntil time.Time // 锁定的结束时间，未锁定时为零值或已过去
+}
+
+// lastActive 返回记录最后一次变化的时间：最近一次失败或锁定结束，取较晚者
+func (r Record) lastActive() time.Time {
+	if r.LockedUntil.After(r.LastFailure) {
+		return r.LockedUntil
+	}
+	return r.LastFailure
+}
+
+// Store 保存登录失败记录。多个服务实例使用同一个共享存储（如 DatabaseStore）时，
+// 失败次数在实例之间累计，锁定对所有实例生效
+type Store interface {
+	// Get 返回 key 的记录，没有时返回零值
+	Get(key string) (Record, error)
+	// Update 原子地读取、修改并保存 key 的记录，返回修改后的记录
+	Update(key string, fn func(*Record)) (Record, error)
+	// Delete 删除 key 的记录
+	Delete(key string) error
+	// Locked 返回锁定到 now 之后的记录
+	Locked(now time.Time) (map[string]Record, error)
+	// Purge 删除在 before 之前就已没有失败和锁定的记录
+	Purge(before time.Time) error
+}
+
+// MemoryStore 保存在进程内存中的记录，只对单个服务实例有效，重启后清空
+type MemoryStore struct {
+	mutex   sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore 创建进程内存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+// Get 返回 key 的记录
+func (s *MemoryStore) Get(key string) (Record, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.records[key], nil
+}
+
+// Update 在互斥锁内修改记录
+func (s *MemoryStore) Update(key string, fn func(*Record)) (Record, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record := s.records[key]
+	fn(&record)
+	s.records[key] = record
+	return record, nil
+}
+
+// Delete 删除 key 的记录
+func (s *MemoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// Locked 返回锁定到 now 之后的记录
+func (s *MemoryStore) Locked(now time.Time) (map[string]Record, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	locked := make(map[string]Record)
+	for key, record := range s.records {
+		if record.LockedUntil.After(now) {
+			locked[key] = record
+		}
+	}
+	return locked, nil
+}
+
+// Purge 删除在 before 之前就已没有失败和锁定的记录
+func (s *MemoryStore) Purge(before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, record := range s.records {
+		if record.lastActive().Before(before) {
+			delete(s.records, key)
+		}
+	}
+	return nil
+}
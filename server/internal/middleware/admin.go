@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	user_service "fsync/server/internal/modules/user/service"
+	"fsync/server/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin 只允许管理员访问，须在 JWTAuth 之后使用
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		isAdmin, err := user_service.IsAdmin(c.GetString(ContextUsernameKey))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{Code: http.StatusInternalServerError, Msg: err.Error()})
+			return
+		}
+		if !isAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.Response{Code: http.StatusForbidden, Msg: "需要管理员权限"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -1 +1,45 @@
-package middleware
\ No newline at end of file
+package middleware
+
+import (
+	"fsync/server/global"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS 处理跨域请求。每个请求都读取当前配置，重新加载配置后立即生效；
+// 只允许 cors_origins 中明确列出的来源，列表为空时拒绝所有跨域请求
+func CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		cfg := global.Config().Server
+		if origin == "" || !cfg.CorsEnabled || !originAllowed(origin, cfg.CorsOrigins) {
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Credentials", "true")
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Content-Range, X-Request-ID")
+			h.Set("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// originAllowed 判断来源是否在允许列表中，列表为空时不允许任何来源
+func originAllowed(origin string, allowed []string) bool {
+	for _, o := range allowed {
+		if strings.EqualFold(strings.TrimRight(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
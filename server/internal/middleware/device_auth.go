@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"errors"
+	"fsync/server/global"
+	device_service "fsync/server/internal/modules/device/service"
+	"fsync/server/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextDeviceKey 认证通过后设备 ID 在 gin.Context 中的键
+const ContextDeviceKey = "device_id"
+
+// DeviceAuth 校验客户端证书对应的设备，须在 JWTAuth 之后使用。
+// 证书已在 TLS 握手时由本地 CA 校验，这里检查设备是否已登记、未吊销，且与访问令牌属于同一用户和设备；
+// 没有客户端证书的请求在 server.tls.require_device_cert 开启时被拒绝
+func DeviceAuth() gin.HandlerFunc {
+	return deviceAuth(true)
+}
+
+// DeviceAuthOptional 与 DeviceAuth 相同，但始终允许不出示证书的请求，用于还没有证书的设备申请证书（`device enroll`）
+func DeviceAuthOptional() gin.HandlerFunc {
+	return deviceAuth(false)
+}
+
+// deviceAuth 校验设备证书，enforce 为 true 时按 server.tls.require_device_cert 要求出示证书
+func deviceAuth(enforce bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.PeerCertificates) == 0 {
+			if enforce && global.Config().Server.TLS.RequireDeviceCert {
+				abortDevice(c, device_service.ErrDeviceCertNeeded)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		device, err := device_service.Authenticate(c.Request.TLS.PeerCertificates[0], c.GetString(ContextUsernameKey))
+		if err != nil {
+			abortDevice(c, err)
+			return
+		}
+		if device.ID != c.GetString(ContextDeviceKey) {
+			abortDevice(c, device_service.ErrDeviceMismatch)
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortDevice 以设备认证错误结束请求
+func abortDevice(c *gin.Context, err error) {
+	status := http.StatusForbidden
+	if errors.Is(err, device_service.ErrDeviceCertNeeded) || errors.Is(err, device_service.ErrUnknownCert) {
+		status = http.StatusUnauthorized
+	} else if !errors.Is(err, device_service.ErrDeviceRevoked) && !errors.Is(err, device_service.ErrDeviceMismatch) {
+		status = http.StatusInternalServerError
+	}
+	c.AbortWithStatusJSON(status, models.Response{Code: status, Msg: err.Error()})
+}
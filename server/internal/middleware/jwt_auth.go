@@ -1 +1,51 @@
-package middleware
\ No newline at end of file
+package middleware
+
+import (
+	"fsync/pkg/utils"
+	device_service "fsync/server/internal/modules/device/service"
+	user_service "fsync/server/internal/modules/user/service"
+	"fsync/server/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextUsernameKey 认证通过后用户名在 gin.Context 中的键
+const ContextUsernameKey = "username"
+
+// ContextClaimsKey 认证通过后访问令牌的声明（*utils.Claims）在 gin.Context 中的键
+const ContextClaimsKey = "claims"
+
+// JWTAuth 校验 Authorization 头中的访问令牌，拒绝已吊销的令牌和已吊销设备的令牌，并将用户名和设备写入上下文。
+// 同时记录设备的最近活动，并把所在连接归属到该设备，远程登出设备时断开
+func JWTAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := utils.ParseToken(c.GetHeader("Authorization"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.Response{
+				Code: http.StatusUnauthorized,
+				Msg:  "未认证或令牌无效: " + err.Error(),
+			})
+			return
+		}
+		// 没有会话标识的令牌是服务端记录刷新令牌之前签发的，无法吊销，要求重新登录
+		if claims.SessionID == "" || user_service.IsTokenRevoked(claims.ID) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.Response{
+				Code: http.StatusUnauthorized,
+				Msg:  "令牌已失效，请重新登录",
+			})
+			return
+		}
+		// 设备被吊销后，即使令牌吊销失败或令牌签发于吊销之前，也不再接受该设备的令牌
+		if device_service.IsRevoked(claims.DeviceID) {
+			abortDevice(c, device_service.ErrDeviceRevoked)
+			return
+		}
+		c.Set(ContextUsernameKey, claims.Username)
+		c.Set(ContextClaimsKey, claims)
+		c.Set(ContextDeviceKey, claims.DeviceID)
+		device_service.Touch(claims.DeviceID, c.ClientIP())
+		device_service.TrackConn(c.Request.Context(), claims.DeviceID)
+		c.Next()
+	}
+}
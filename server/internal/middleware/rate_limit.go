@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"fsync/server/global"
+	"fsync/server/models"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// 限流规则名，对应配置 rate_limit.rules 下的键
+const (
+	RateLimitAuth = "auth" // 登录、注册、刷新令牌
+	RateLimitAPI  = "api"  // 一般接口
+	RateLimitFile = "file" // 文件传输接口
+	// RateLimitIP 需要登录的接口在校验令牌之前按客户端 IP 限流，伪造或过期令牌的请求也会被计数，
+	// 避免只靠按用户计数的规则时，未通过认证的请求可以无限制地消耗签名校验和吊销检查
+	RateLimitIP = "ip"
+)
+
+// limiterIdle 令牌桶超过该时长未使用时清理（此时早已回满，清理不影响限流）
+const limiterIdle = 10 * time.Minute
+
+// limiterEntry 一个用户或 IP 的令牌桶
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// limiterSet 一条规则下按用户或 IP 区分的令牌桶，规则的配置变化时全部重建
+type limiterSet struct {
+	mutex     sync.Mutex
+	rule      models.RateLimitRule
+	entries   map[string]*limiterEntry
+	lastSweep time.Time
+}
+
+var (
+	limiterSetsMutex sync.Mutex
+	limiterSets      = make(map[string]*limiterSet)
+)
+
+// RateLimit 按令牌桶限制请求速率，超出时返回 429 和 Retry-After。已认证的请求（放在 JWTAuth 之后）按用户名计数，
+// 否则（包括放在 JWTAuth 之前）按客户端 IP 计数。每个请求读取当前配置，重新加载配置后立即生效；规则未配置或未启用限流时不限制
+func RateLimit(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := global.Config().RateLimit
+		rule, ok := cfg.Rules[name]
+		if !cfg.Enabled || !ok || rule.Requests <= 0 {
+			c.Next()
+			return
+		}
+		key := "ip:" + c.ClientIP()
+		if username := c.GetString(ContextUsernameKey); username != "" {
+			key = "user:" + username
+		}
+		if wait := getLimiterSet(name).reserve(rule, key, time.Now()); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.Response{
+				Code: http.StatusTooManyRequests,
+				Msg:  "请求过于频繁，请稍后再试",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// getLimiterSet 返回规则对应的令牌桶集合
+func getLimiterSet(name string) *limiterSet {
+	limiterSetsMutex.Lock()
+	defer limiterSetsMutex.Unlock()
+	set, ok := limiterSets[name]
+	if !ok {
+		set = &limiterSet{entries: make(map[string]*limiterEntry)}
+		limiterSets[name] = set
+	}
+	return set
+}
+
+// reserve 从 key 的令牌桶中取一个令牌，令牌不足时不消耗令牌，返回需要等待的时间
+func (s *limiterSet) reserve(rule models.RateLimitRule, key string, now time.Time) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if rule != s.rule {
+		s.rule = rule
+		s.entries = make(map[string]*limiterEntry)
+	}
+	if now.Sub(s.lastSweep) > limiterIdle {
+		for k, e := range s.entries {
+			if now.Sub(e.lastSeen) > limiterIdle {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		burst := rule.Burst
+		if burst <= 0 {
+			burst = rule.Requests
+		}
+		limit := rate.Limit(float64(rule.Requests) / rule.Per.Seconds())
+		entry = &limiterEntry{limiter: rate.NewLimiter(limit, burst)}
+		s.entries[key] = entry
+	}
+	entry.lastSeen = now
+	r := entry.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return rule.Per
+	}
+	if wait := r.DelayFrom(now); wait > 0 {
+		r.CancelAt(now)
+		return wait
+	}
+	return 0
+}
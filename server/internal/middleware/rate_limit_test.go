@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"fsync/server/global"
+	"fsync/server/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setRateLimit 发布只包含限流配置的测试配置，并清空已有的令牌桶
+func setRateLimit(t *testing.T, cfg models.RateLimitConfig) {
+	t.Helper()
+	previous := global.Config()
+	global.SetConfig(&models.Config{RateLimit: cfg})
+	limiterSetsMutex.Lock()
+	limiterSets = make(map[string]*limiterSet)
+	limiterSetsMutex.Unlock()
+	t.Cleanup(func() { global.SetConfig(previous) })
+}
+
+// newRateLimitRouter 返回一个经过 handlers 后响应 200 的路由
+func newRateLimitRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })...)
+	return r
+}
+
+// doRequest 以 remoteAddr 为来源发出请求
+func doRequest(r http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	return doForwardedRequest(r, remoteAddr, "")
+}
+
+// doForwardedRequest 以 remoteAddr 为来源发出带 X-Forwarded-For 的请求
+func doForwardedRequest(r http.Handler, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// asUser 模拟 JWTAuth 在上下文中写入用户名
+func asUser(username string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ContextUsernameKey, username)
+	}
+}
+
+func TestRateLimitRejectsWithRetryAfter(t *testing.T) {
+	setRateLimit(t, models.RateLimitConfig{
+		Enabled: true,
+		Rules:   map[string]models.RateLimitRule{RateLimitAPI: {Requests: 2, Per: time.Minute}},
+	})
+	r := newRateLimitRouter(RateLimit(RateLimitAPI))
+
+	for i := 0; i < 2; i++ {
+		if w := doRequest(r, "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, w.Code)
+		}
+	}
+	w := doRequest(r, "192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	// 每分钟 2 个令牌，下一个令牌 30 秒后可用
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+
+	// 其他 IP 使用自己的令牌桶
+	if w := doRequest(r, "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("other IP: status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	rules := map[string]models.RateLimitRule{
+		RateLimitIP:  {Requests: 1, Per: time.Minute},
+		RateLimitAPI: {Requests: 1, Per: time.Minute},
+	}
+	t.Run("before authentication counts by IP", func(t *testing.T) {
+		setRateLimit(t, models.RateLimitConfig{Enabled: true, Rules: rules})
+		// 放在 JWTAuth 之前时上下文中还没有用户名，不同用户从同一 IP 发出的请求共用一个令牌桶
+		alice := newRateLimitRouter(RateLimit(RateLimitIP), asUser("alice"))
+		bob := newRateLimitRouter(RateLimit(RateLimitIP), asUser("bob"))
+		if w := doRequest(alice, "192.0.2.1:1"); w.Code != http.StatusOK {
+			t.Fatalf("first request: status = %d, want 200", w.Code)
+		}
+		if w := doRequest(bob, "192.0.2.1:2"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("same IP, other user: status = %d, want 429", w.Code)
+		}
+	})
+
+	t.Run("after authentication counts by user", func(t *testing.T) {
+		setRateLimit(t, models.RateLimitConfig{Enabled: true, Rules: rules})
+		alice := newRateLimitRouter(asUser("alice"), RateLimit(RateLimitAPI))
+		bob := newRateLimitRouter(asUser("bob"), RateLimit(RateLimitAPI))
+		if w := doRequest(alice, "192.0.2.1:1"); w.Code != http.StatusOK {
+			t.Fatalf("alice: status = %d, want 200", w.Code)
+		}
+		if w := doRequest(bob, "192.0.2.1:2"); w.Code != http.StatusOK {
+			t.Fatalf("bob from the same IP: status = %d, want 200", w.Code)
+		}
+		if w := doRequest(alice, "192.0.2.9:1"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("alice from another IP: status = %d, want 429", w.Code)
+		}
+	})
+
+	t.Run("unauthenticated requests are limited before authentication", func(t *testing.T) {
+		setRateLimit(t, models.RateLimitConfig{Enabled: true, Rules: rules})
+		// 模拟 JWTAuth 拒绝伪造的令牌：被拒绝的请求同样消耗 IP 的令牌
+		reject := func(c *gin.Context) { c.AbortWithStatus(http.StatusUnauthorized) }
+		r := newRateLimitRouter(RateLimit(RateLimitIP), reject)
+		if w := doRequest(r, "192.0.2.1:1"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("first request: status = %d, want 401", w.Code)
+		}
+		w := doRequest(r, "192.0.2.1:1")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("second request: status = %d, want 429", w.Code)
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Error("missing Retry-After")
+		}
+	})
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	rules := map[string]models.RateLimitRule{RateLimitIP: {Requests: 1, Per: time.Minute}}
+	// newRouter 与 routers.InitRouter 一样按 server.trusted_proxies 设置可信代理
+	newRouter := func(t *testing.T, proxies []string) *gin.Engine {
+		t.Helper()
+		r := newRateLimitRouter(RateLimit(RateLimitIP))
+		if err := r.SetTrustedProxies(proxies); err != nil {
+			t.Fatalf("SetTrustedProxies: %v", err)
+		}
+		return r
+	}
+
+	t.Run("spoofed header from an untrusted peer", func(t *testing.T) {
+		setRateLimit(t, models.RateLimitConfig{Enabled: true, Rules: rules})
+		r := newRouter(t, nil)
+		if w := doForwardedRequest(r, "192.0.2.1:1", "198.51.100.1"); w.Code != http.StatusOK {
+			t.Fatalf("first request: status = %d, want 200", w.Code)
+		}
+		// 每次换一个伪造的 X-Forwarded-For 仍按连接地址计数，不会得到新的令牌桶
+		if w := doForwardedRequest(r, "192.0.2.1:1", "198.51.100.2"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("spoofed header: status = %d, want 429", w.Code)
+		}
+	})
+
+	t.Run("trusted proxy", func(t *testing.T) {
+		setRateLimit(t, models.RateLimitConfig{Enabled: true, Rules: rules})
+		r := newRouter(t, []string{"10.0.0.1"})
+		// 经可信代理转发的请求按代理记录的客户端 IP 分别计数
+		if w := doForwardedRequest(r, "10.0.0.1:1", "198.51.100.1"); w.Code != http.StatusOK {
+			t.Fatalf("first client: status = %d, want 200", w.Code)
+		}
+		if w := doForwardedRequest(r, "10.0.0.1:1", "198.51.100.2"); w.Code != http.StatusOK {
+			t.Fatalf("second client: status = %d, want 200", w.Code)
+		}
+		if w := doForwardedRequest(r, "10.0.0.1:1", "198.51.100.1"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("first client again: status = %d, want 429", w.Code)
+		}
+	})
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  models.RateLimitConfig
+	}{
+		{"disabled", models.RateLimitConfig{Enabled: false, Rules: map[string]models.RateLimitRule{RateLimitAPI: {Requests: 1, Per: time.Minute}}}},
+		{"rule missing", models.RateLimitConfig{Enabled: true, Rules: map[string]models.RateLimitRule{RateLimitFile: {Requests: 1, Per: time.Minute}}}},
+		{"zero requests", models.RateLimitConfig{Enabled: true, Rules: map[string]models.RateLimitRule{RateLimitAPI: {Requests: 0, Per: time.Minute}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRateLimit(t, tt.cfg)
+			r := newRateLimitRouter(RateLimit(RateLimitAPI))
+			for i := 0; i < 5; i++ {
+				if w := doRequest(r, "192.0.2.1:1"); w.Code != http.StatusOK {
+					t.Fatalf("request %d: status = %d, want 200", i+1, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterSetReserve(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	rule := models.RateLimitRule{Requests: 6, Per: time.Minute, Burst: 2}
+	s := &limiterSet{entries: make(map[string]*limiterEntry)}
+
+	// 突发 2 个请求后每 10 秒恢复一个令牌
+	for i := 0; i < 2; i++ {
+		if wait := s.reserve(rule, "k", now); wait != 0 {
+			t.Fatalf("burst request %d: wait = %s, want 0", i+1, wait)
+		}
+	}
+	if wait := s.reserve(rule, "k", now); wait != 10*time.Second {
+		t.Fatalf("wait = %s, want 10s", wait)
+	}
+	// 被拒绝的请求不消耗令牌
+	if wait := s.reserve(rule, "k", now.Add(4*time.Second)); wait.Round(time.Millisecond) != 6*time.Second {
+		t.Fatalf("wait = %s, want 6s", wait)
+	}
+	if wait := s.reserve(rule, "k", now.Add(10*time.Second)); wait != 0 {
+		t.Fatalf("after refill: wait = %s, want 0", wait)
+	}
+
+	// 规则变化时重建令牌桶
+	if wait := s.reserve(models.RateLimitRule{Requests: 6, Per: time.Minute, Burst: 3}, "k", now.Add(10*time.Second)); wait != 0 {
+		t.Fatalf("after rule change: wait = %s, want 0", wait)
+	}
+}
+
+func TestRateLimitConcurrent(t *testing.T) {
+	setRateLimit(t, models.RateLimitConfig{
+		Enabled: true,
+		Rules:   map[string]models.RateLimitRule{RateLimitFile: {Requests: 10, Per: time.Hour}},
+	})
+	r := newRateLimitRouter(RateLimit(RateLimitFile))
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	ok := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if doRequest(r, "192.0.2.1:1").Code == http.StatusOK {
+				mutex.Lock()
+				ok++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ok != 10 {
+		t.Errorf("%d requests passed, want 10", ok)
+	}
+}
@@ -0,0 +1,102 @@
+package device_handler
+
+import (
+	"errors"
+	"fsync/server/internal/middleware"
+	device_model "fsync/server/internal/modules/device/model"
+	device_service "fsync/server/internal/modules/device/service"
+	user_service "fsync/server/internal/modules/user/service"
+	"fsync/server/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// List 列出当前用户的设备
+func List(ctx *gin.Context) {
+	devices, err := device_service.ListByUser(ctx.GetString(middleware.ContextUsernameKey))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	current := ctx.GetString(middleware.ContextDeviceKey)
+	for i := range devices {
+		devices[i].Current = devices[i].ID == current
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: devices})
+}
+
+// Rename 重命名当前用户的设备
+func Rename(ctx *gin.Context) {
+	var req device_model.RenameRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+	device, err := device_service.Rename(ctx.GetString(middleware.ContextUsernameKey), ctx.Param("id"), req.Name)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "设备已重命名", Data: device})
+}
+
+// Logout 远程登出当前用户的设备，吊销其令牌并断开连接
+func Logout(ctx *gin.Context) {
+	device, err := user_service.LogoutDevice(ctx.GetString(middleware.ContextUsernameKey), ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "设备已登出", Data: device})
+}
+
+// Enroll 为当前设备签发客户端证书
+func Enroll(ctx *gin.Context) {
+	var req device_model.EnrollRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	resp, err := device_service.Enroll(ctx.GetString(middleware.ContextUsernameKey), ctx.GetString(middleware.ContextDeviceKey), &req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "设备证书已签发", Data: resp})
+}
+
+// AdminList 列出所有设备
+func AdminList(ctx *gin.Context) {
+	devices, err := device_service.List()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: devices})
+}
+
+// AdminRevoke 吊销设备证书，立即断开该设备的连接
+func AdminRevoke(ctx *gin.Context) {
+	device, err := user_service.RevokeDevice(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "设备证书已吊销", Data: device})
+}
+
+// respondError 按服务层错误类型返回对应的状态码
+func respondError(ctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, device_service.ErrInvalidCSR):
+		status = http.StatusBadRequest
+	case errors.Is(err, device_service.ErrDeviceNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, device_service.ErrDeviceRevoked):
+		status = http.StatusForbidden
+	}
+	ctx.JSON(status, models.Response{Code: status, Msg: err.Error()})
+}
@@ -0,0 +1,49 @@
+package device_model
+
+import "time"
+
+// Device 用户登录过的设备。每个令牌和连接都属于一台设备，可以按设备查看、重命名和远程登出；
+// 登记了设备证书的设备在双向 TLS 时据证书把请求绑定到设备
+type Device struct {
+	ID              string     `gorm:"primaryKey;size:36" json:"id"` // 同时作为客户端证书的 CN
+	Username        string     `gorm:"size:64;index;not null" json:"username"`
+	Name            string     `gorm:"size:128;not null" json:"name"`
+	Platform        string     `gorm:"size:64" json:"platform"` // 如 linux/amd64
+	ClientVersion   string     `gorm:"size:64" json:"client_version"`
+	LastSeenIP      string     `gorm:"size:64" json:"last_seen_ip"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+	CertSerial      *string    `gorm:"size:40;uniqueIndex" json:"cert_serial"` // 十六进制，未登记设备证书时为空
+	CertFingerprint string     `gorm:"size:64" json:"cert_fingerprint"`        // 证书 SHA256
+	CertNotAfter    *time.Time `json:"cert_not_after"`
+	RevokedAt       *time.Time `json:"revoked_at"` // 非空表示设备证书已吊销
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	Current bool `gorm:"-" json:"current"` // 是否为发出请求的设备，仅用于响应
+}
+
+// Info 客户端登录时上报的设备信息，ID 为之前登录时分配的设备 ID
+type Info struct {
+	ID            string `json:"id"`
+	Name          string `json:"name" binding:"max=128"`
+	Platform      string `json:"platform" binding:"max=64"`
+	ClientVersion string `json:"client_version" binding:"max=64"`
+}
+
+// EnrollRequest 登记设备证书，私钥留在设备上，只提交证书签名请求
+type EnrollRequest struct {
+	Name string `json:"name" binding:"max=128"`
+	CSR  string `json:"csr" binding:"required"` // PEM 格式
+}
+
+// EnrollResponse 登记结果
+type EnrollResponse struct {
+	Device Device `json:"device"`
+	Cert   string `json:"cert"` // 客户端证书，PEM 格式
+	CACert string `json:"ca_cert"`
+}
+
+// RenameRequest 重命名设备
+type RenameRequest struct {
+	Name string `json:"name" binding:"required,max=128"`
+}
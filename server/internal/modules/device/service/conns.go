@@ -0,0 +1,68 @@
+package device_service
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// connKey 在请求上下文中保存底层连接的键
+type connKey struct{}
+
+var (
+	// conns 每台设备当前使用的连接，吊销时逐个关闭
+	conns      = make(map[string]map[net.Conn]struct{})
+	connDevice = make(map[net.Conn]string)
+	connsMutex sync.Mutex
+)
+
+// ConnContext 在连接的上下文中保存连接本身，设置到 http.Server.ConnContext
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// ConnClosed 连接关闭后不再跟踪，在 http.Server.ConnState 收到 StateClosed 时调用。
+// 被接管（Hijack）的连接之后不再有状态变化，一直跟踪到设备被吊销
+func ConnClosed(c net.Conn) {
+	connsMutex.Lock()
+	defer connsMutex.Unlock()
+	if id, ok := connDevice[c]; ok {
+		delete(conns[id], c)
+		if len(conns[id]) == 0 {
+			delete(conns, id)
+		}
+		delete(connDevice, c)
+	}
+}
+
+// TrackConn 记录请求所在连接属于哪台设备。WebSocket 等升级后的连接仍是同一个底层连接，吊销时同样被关闭
+func TrackConn(ctx context.Context, deviceID string) {
+	c, ok := ctx.Value(connKey{}).(net.Conn)
+	if !ok {
+		return
+	}
+	connsMutex.Lock()
+	defer connsMutex.Unlock()
+	if conns[deviceID] == nil {
+		conns[deviceID] = make(map[net.Conn]struct{})
+	}
+	conns[deviceID][c] = struct{}{}
+	connDevice[c] = deviceID
+}
+
+// CloseConnections 关闭设备的所有连接，返回关闭的数量
+func CloseConnections(deviceID string) int {
+	connsMutex.Lock()
+	list := make([]net.Conn, 0, len(conns[deviceID]))
+	for c := range conns[deviceID] {
+		list = append(list, c)
+		delete(connDevice, c)
+	}
+	delete(conns, deviceID)
+	connsMutex.Unlock()
+
+	for _, c := range list {
+		c.Close()
+	}
+	return len(list)
+}
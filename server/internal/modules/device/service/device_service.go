@@ -0,0 +1,217 @@
+package device_service
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"fsync/pkg/utils"
+	"fsync/server/global"
+	device_model "fsync/server/internal/modules/device/model"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCSR       = errors.New("无效的证书签名请求")
+	ErrDeviceNotFound   = errors.New("设备不存在")
+	ErrDeviceRevoked    = errors.New("设备证书已吊销")
+	ErrUnknownCert      = errors.New("未登记的设备证书")
+	ErrDeviceMismatch   = errors.New("设备证书与登录的用户或设备不符")
+	ErrDeviceCertNeeded = errors.New("需要设备证书，请先执行 `device enroll` 登记本设备")
+)
+
+// defaultName 客户端没有上报名称时的设备名
+const defaultName = "未命名设备"
+
+// Enroll 按证书签名请求用本地 CA 为设备签发客户端证书，deviceID 为当前令牌所属的设备。
+// 重新登记时新证书替换旧证书
+func Enroll(username, deviceID string, req *device_model.EnrollRequest) (*device_model.EnrollResponse, error) {
+	block, _ := pem.Decode([]byte(req.CSR))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, ErrInvalidCSR
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSR, err)
+	}
+	device, err := Get(username, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.RevokedAt != nil {
+		return nil, ErrDeviceRevoked
+	}
+
+	tlsCfg := global.Config().Server.TLS
+	ca, err := utils.LoadCA(tlsCfg.CACertFile, tlsCfg.CAKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载本地 CA 失败: %w", err)
+	}
+	cert, err := ca.SignClientCSR(csr, utils.CertOptions{CommonName: device.ID, Validity: tlsCfg.DeviceValidity})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSR, err)
+	}
+
+	serial := cert.SerialNumber.Text(16)
+	device.CertSerial = &serial
+	device.CertFingerprint = fingerprint(cert)
+	device.CertNotAfter = &cert.NotAfter
+	if req.Name != "" {
+		device.Name = req.Name
+	}
+	if err := global.DB.Save(device).Error; err != nil {
+		return nil, fmt.Errorf("保存设备失败: %w", err)
+	}
+	global.Logger.Info("已签发设备证书", zap.String("username", username), zap.String("device", device.ID), zap.String("name", device.Name))
+	return &device_model.EnrollResponse{
+		Device: *device,
+		Cert:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		CACert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Cert.Raw})),
+	}, nil
+}
+
+// Register 返回登录所用的设备并更新设备信息和最近活动。优先使用设备证书对应的设备（certDeviceID），
+// 其次是客户端保存的设备 ID；都没有或设备不属于该用户时登记新设备。
+// 已吊销的设备返回 ErrDeviceRevoked，不能通过重新登录换一个新设备 ID 绕过吊销
+func Register(username, certDeviceID string, info *device_model.Info, ip string) (*device_model.Device, error) {
+	id := certDeviceID
+	if id == "" {
+		id = info.ID
+	}
+	var device *device_model.Device
+	create := false
+	if id != "" {
+		existing, err := Get(username, id)
+		if err != nil && !errors.Is(err, ErrDeviceNotFound) {
+			return nil, err
+		}
+		if existing != nil && existing.RevokedAt != nil {
+			return nil, ErrDeviceRevoked
+		}
+		device = existing
+	}
+	if device == nil {
+		newID, err := utils.NewUUID()
+		if err != nil {
+			return nil, err
+		}
+		device = &device_model.Device{ID: newID, Username: username, Name: defaultName}
+		create = true
+		global.Logger.Info("登记新设备", zap.String("username", username), zap.String("device", newID), zap.String("name", info.Name))
+	}
+
+	if info.Name != "" && device.Name == defaultName {
+		device.Name = info.Name
+	}
+	device.Platform = info.Platform
+	device.ClientVersion = info.ClientVersion
+	device.LastSeenIP = ip
+	device.LastSeenAt = time.Now()
+	save := global.DB.Save
+	if create {
+		save = global.DB.Create
+	}
+	if err := save(device).Error; err != nil {
+		return nil, fmt.Errorf("保存设备失败: %w", err)
+	}
+	markSeen(device.ID, device.LastSeenAt)
+	return device, nil
+}
+
+// Get 查询属于 username 的设备
+func Get(username, id string) (*device_model.Device, error) {
+	var device device_model.Device
+	if err := global.DB.Where("id = ? AND username = ?", id, username).First(&device).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+	return &device, nil
+}
+
+// ListByUser 列出用户的设备，最近活动的在前
+func ListByUser(username string) ([]device_model.Device, error) {
+	devices := make([]device_model.Device, 0)
+	if err := global.DB.Where("username = ?", username).Order("last_seen_at DESC").Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+	return devices, nil
+}
+
+// Rename 重命名用户的设备
+func Rename(username, id, name string) (*device_model.Device, error) {
+	device, err := Get(username, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := global.DB.Model(device).Update("name", name).Error; err != nil {
+		return nil, fmt.Errorf("重命名设备失败: %w", err)
+	}
+	return device, nil
+}
+
+// Authenticate 根据已通过 CA 校验的客户端证书查找设备，设备须属于 username 且未被吊销
+func Authenticate(cert *x509.Certificate, username string) (*device_model.Device, error) {
+	var device device_model.Device
+	err := global.DB.Where("cert_serial = ?", cert.SerialNumber.Text(16)).First(&device).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnknownCert
+		}
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+	if device.CertFingerprint != fingerprint(cert) {
+		return nil, ErrUnknownCert
+	}
+	if device.RevokedAt != nil {
+		return nil, ErrDeviceRevoked
+	}
+	if device.Username != username {
+		return nil, ErrDeviceMismatch
+	}
+	return &device, nil
+}
+
+// List 列出所有用户的设备，管理员使用
+func List() ([]device_model.Device, error) {
+	devices := make([]device_model.Device, 0)
+	if err := global.DB.Order("created_at").Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+	return devices, nil
+}
+
+// Revoke 吊销设备证书，之后该设备的令牌不再被接受，并立即断开该设备的所有连接（包括进行中的传输和升级后的长连接）。
+// 设备上会话的令牌由 user_service.RevokeDevice 一并吊销
+func Revoke(id string) (*device_model.Device, error) {
+	var device device_model.Device
+	if err := global.DB.Where("id = ?", id).First(&device).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+	if device.RevokedAt == nil {
+		now := time.Now()
+		if err := global.DB.Model(&device).Update("revoked_at", now).Error; err != nil {
+			return nil, fmt.Errorf("吊销设备失败: %w", err)
+		}
+		device.RevokedAt = &now
+	}
+	markRevoked(device.ID)
+	closed := CloseConnections(device.ID)
+	global.Logger.Info("设备证书已吊销", zap.String("device", device.ID), zap.String("username", device.Username), zap.Int("closed_conns", closed))
+	return &device, nil
+}
+
+// fingerprint 返回证书的 SHA256 十六进制摘要
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,42 @@
+package device_service
+
+import (
+	"fmt"
+	"fsync/server/global"
+	device_model "fsync/server/internal/modules/device/model"
+	"sync"
+)
+
+var (
+	// revoked 已吊销的设备 ID，启动时从数据库加载。每个请求都要检查令牌所属的设备，因此保存在内存中
+	revoked      = make(map[string]bool)
+	revokedMutex sync.RWMutex
+)
+
+// IsRevoked 判断设备是否已被吊销
+func IsRevoked(deviceID string) bool {
+	revokedMutex.RLock()
+	defer revokedMutex.RUnlock()
+	return revoked[deviceID]
+}
+
+// LoadRevoked 从数据库加载已吊销的设备，启动时调用
+func LoadRevoked() error {
+	var ids []string
+	if err := global.DB.Model(&device_model.Device{}).Where("revoked_at IS NOT NULL").Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("加载已吊销设备失败: %w", err)
+	}
+	revokedMutex.Lock()
+	defer revokedMutex.Unlock()
+	for _, id := range ids {
+		revoked[id] = true
+	}
+	return nil
+}
+
+// markRevoked 记录已吊销的设备
+func markRevoked(deviceID string) {
+	revokedMutex.Lock()
+	defer revokedMutex.Unlock()
+	revoked[deviceID] = true
+}
@@ -0,0 +1,47 @@
+package device_service
+
+import (
+	"fsync/server/global"
+	device_model "fsync/server/internal/modules/device/model"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// seenInterval 同一设备两次写入最近活动的最小间隔，避免每个请求都写数据库
+const seenInterval = time.Minute
+
+var (
+	// lastSeen 每台设备最近一次写入数据库的活动时间
+	lastSeen      = make(map[string]time.Time)
+	lastSeenMutex sync.Mutex
+)
+
+// Touch 记录设备的最近活动，距上次记录不足 seenInterval 时跳过
+func Touch(deviceID, ip string) {
+	if deviceID == "" {
+		return
+	}
+	now := time.Now()
+	lastSeenMutex.Lock()
+	if now.Sub(lastSeen[deviceID]) < seenInterval {
+		lastSeenMutex.Unlock()
+		return
+	}
+	lastSeen[deviceID] = now
+	lastSeenMutex.Unlock()
+
+	err := global.DB.Model(&device_model.Device{}).Where("id = ?", deviceID).
+		Updates(map[string]interface{}{"last_seen_ip": ip, "last_seen_at": now}).Error
+	if err != nil {
+		global.Logger.Warn("更新设备最近活动失败", zap.String("device", deviceID), zap.Error(err))
+	}
+}
+
+// markSeen 记录已写入数据库的活动时间
+func markSeen(deviceID string, at time.Time) {
+	lastSeenMutex.Lock()
+	defer lastSeenMutex.Unlock()
+	lastSeen[deviceID] = at
+}
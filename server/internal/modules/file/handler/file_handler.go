@@ -0,0 +1,254 @@
+package file_handler
+
+import (
+	"errors"
+	"fsync/server/internal/blobstore"
+	"fsync/server/internal/middleware"
+	file_model "fsync/server/internal/modules/file/model"
+	file_service "fsync/server/internal/modules/file/service"
+	"fsync/server/models"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChunkHashHeader 上传分块时携带分块 SHA256 的请求头
+const ChunkHashHeader = "X-Chunk-Hash"
+
+// FileHashHeader 下载时返回文件整体 SHA256 的响应头
+const FileHashHeader = "X-File-Hash"
+
+// ContentMACHeader 下载时返回端到端加密文件明文 MAC 的响应头
+const ContentMACHeader = "X-Content-MAC"
+
+// ContentCodecHeader 下载时返回文件压缩编码的响应头。不使用 Content-Encoding，
+// 避免 HTTP 客户端自动解压后与文件哈希和 Range 偏移对不上
+const ContentCodecHeader = "X-Content-Codec"
+
+// CreateUploadSession 创建或恢复上传会话
+func CreateUploadSession(ctx *gin.Context) {
+	var req file_model.CreateUploadRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	info, err := file_service.CreateUploadSession(ctx.GetString(middleware.ContextUsernameKey), &req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: info})
+}
+
+// GetUploadSession 查询上传会话，客户端据此得知哪些分块已被服务端确认
+func GetUploadSession(ctx *gin.Context) {
+	info, err := file_service.GetUploadSession(ctx.GetString(middleware.ContextUsernameKey), ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: info})
+}
+
+// PutChunk 接收一个分块，请求体为分块原始内容
+func PutChunk(ctx *gin.Context) {
+	index, err := strconv.Atoi(ctx.Param("index"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "分块序号无效"})
+		return
+	}
+	hash := ctx.GetHeader(ChunkHashHeader)
+	if len(hash) != 64 {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "缺少或无效的 " + ChunkHashHeader})
+		return
+	}
+
+	err = file_service.PutChunk(ctx.GetString(middleware.ContextUsernameKey), ctx.Param("id"), index, hash, ctx.Request.Body)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success"})
+}
+
+// CompleteUpload 完成上传，拼装并校验文件
+func CompleteUpload(ctx *gin.Context) {
+	meta, err := file_service.CompleteUpload(ctx.GetString(middleware.ContextUsernameKey), ctx.GetString(middleware.ContextDeviceKey), ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: meta})
+}
+
+// MissingChunks 查询服务端缺失的内容分块
+func MissingChunks(ctx *gin.Context) {
+	var req file_model.MissingChunksRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	missing, err := file_service.MissingChunks(ctx.GetString(middleware.ContextUsernameKey), req.Hashes)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: gin.H{"missing": missing}})
+}
+
+// PutContentChunk 上传一个内容分块，路径参数为分块 SHA256
+func PutContentChunk(ctx *gin.Context) {
+	hash := ctx.Param("hash")
+	if len(hash) != 64 {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "分块哈希无效"})
+		return
+	}
+	if err := file_service.PutContentChunk(ctx.GetString(middleware.ContextUsernameKey), hash, ctx.Request.Body); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success"})
+}
+
+// CommitFile 以分块清单提交文件新版本
+func CommitFile(ctx *gin.Context) {
+	var req file_model.CommitFileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	meta, err := file_service.CommitFile(ctx.GetString(middleware.ContextUsernameKey), ctx.GetString(middleware.ContextDeviceKey), &req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: meta})
+}
+
+// GetFileMeta 查询文件元数据，客户端据此判断内容是否需要上传
+func GetFileMeta(ctx *gin.Context) {
+	meta, err := file_service.GetFileMeta(ctx.GetString(middleware.ContextUsernameKey), ctx.Query("path"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: meta})
+}
+
+// GetManifest 查询文件的分块清单，客户端下载后按清单逐块解压
+func GetManifest(ctx *gin.Context) {
+	chunks, err := file_service.GetManifest(ctx.GetString(middleware.ContextUsernameKey), ctx.Query("path"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: gin.H{"chunks": chunks}})
+}
+
+// RemoveFile 删除文件，其他设备拉取变更后把本地副本移入回收站
+func RemoveFile(ctx *gin.Context) {
+	err := file_service.RemoveFile(ctx.GetString(middleware.ContextUsernameKey), ctx.GetString(middleware.ContextDeviceKey), ctx.Query("path"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success"})
+}
+
+// ListChanges 拉取其他设备的文件变更。不带 since 时只返回当前游标，新设备从此开始拉取
+func ListChanges(ctx *gin.Context) {
+	username := ctx.GetString(middleware.ContextUsernameKey)
+	if ctx.Query("since") == "" {
+		latest, err := file_service.LatestChange(username)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success",
+			Data: file_model.FileChanges{Changes: []file_model.FileChange{}, Cursor: latest}})
+		return
+	}
+	since, err := strconv.ParseUint(ctx.Query("since"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "since 参数无效"})
+		return
+	}
+
+	changes, err := file_service.ListChanges(username, ctx.GetString(middleware.ContextDeviceKey), since)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: changes})
+}
+
+// GetKeyFile 读取端到端加密密钥文件，root 参数为加密根目录标识
+func GetKeyFile(ctx *gin.Context) {
+	kf, err := file_service.GetKeyFile(ctx.GetString(middleware.ContextUsernameKey), ctx.Query("root"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: kf})
+}
+
+// PutKeyFile 创建或更新端到端加密密钥文件，root 参数为加密根目录标识
+func PutKeyFile(ctx *gin.Context) {
+	var req file_model.PutKeyFileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+	kf, err := file_service.PutKeyFile(ctx.GetString(middleware.ContextUsernameKey), ctx.Query("root"), &req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: kf})
+}
+
+// Download 下载文件，支持 HTTP Range 断点续传
+func Download(ctx *gin.Context) {
+	f, meta, err := file_service.OpenFile(ctx.GetString(middleware.ContextUsernameKey), ctx.Query("path"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer f.Close()
+
+	ctx.Header(FileHashHeader, meta.Hash)
+	if meta.ContentMAC != "" {
+		ctx.Header(ContentMACHeader, meta.ContentMAC)
+	}
+	if meta.Codec != "" {
+		ctx.Header(ContentCodecHeader, meta.Codec)
+	}
+	ctx.Header("ETag", `"`+meta.Hash+`"`)
+	http.ServeContent(ctx.Writer, ctx.Request, path.Base(meta.Path), meta.UpdatedAt, f)
+}
+
+// respondError 将业务错误映射为 HTTP 状态码
+func respondError(ctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, file_service.ErrInvalidPath), errors.Is(err, file_service.ErrInvalidRootID),
+		errors.Is(err, file_service.ErrChunkOutOfRange),
+		errors.Is(err, file_service.ErrSizeMismatch), errors.Is(err, file_service.ErrChunkSize),
+		errors.Is(err, file_service.ErrChunkCodec):
+		status = http.StatusBadRequest
+	case errors.Is(err, file_service.ErrSessionNotFound), errors.Is(err, file_service.ErrFileNotFound),
+		errors.Is(err, file_service.ErrKeyFileNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, file_service.ErrIncomplete), errors.Is(err, file_service.ErrMissingChunks),
+		errors.Is(err, file_service.ErrKeyFileConflict):
+		status = http.StatusConflict
+	case errors.Is(err, blobstore.ErrMismatch):
+		status = http.StatusUnprocessableEntity
+	}
+	ctx.JSON(status, models.Response{Code: status, Msg: err.Error()})
+}
@@ -0,0 +1,131 @@
+package file_model
+
+import "time"
+
+// FileMeta 用户文件元数据，记录同步路径对应的内容哈希
+type FileMeta struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Username string `gorm:"size:64;uniqueIndex:idx_user_path_hash;not null" json:"username"`
+	Path     string `gorm:"size:3775;not null" json:"path"` // 相对同步根目录的路径，使用 / 分隔，列宽与 maxPathLen 一致
+	// PathHash 路径的 SHA256。加密路径太长，无法直接建唯一索引，按路径哈希保证同一用户的路径唯一
+	PathHash string `gorm:"size:64;uniqueIndex:idx_user_path_hash;not null" json:"-"`
+	Size     int64  `json:"size"`
+	Hash     string `gorm:"size:64;not null" json:"hash"` // 文件内容 SHA256
+	Manifest string `gorm:"type:longtext" json:"-"`       // 增量同步的分块清单（JSON），为空时内容为整块对象
+	// ContentMAC 端到端加密时客户端提交的明文带密钥 MAC，服务端无法由它得到明文，
+	// 客户端用它判断内容是否变化并校验下载结果
+	ContentMAC string `gorm:"size:64" json:"content_mac"`
+	// Codec 客户端上传前使用的压缩编码，为空表示未压缩，下载时客户端据此解压
+	Codec     string    `gorm:"size:16" json:"codec"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FileChange 文件变更记录。其他设备按 ID 递增拉取，把远端的覆盖和删除应用到本地
+type FileChange struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username  string    `gorm:"size:64;index;not null" json:"-"`
+	Path      string    `gorm:"size:3775;not null" json:"path"`
+	Deleted   bool      `gorm:"not null;default:false" json:"deleted"`
+	DeviceID  string    `gorm:"size:36" json:"-"` // 发起变更的设备，拉取时不返回请求设备自己的变更
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FileChanges 一次拉取的变更，Cursor 为下次拉取的起点，More 表示还有未返回的变更
+type FileChanges struct {
+	Changes []FileChange `json:"changes"`
+	Cursor  uint64       `json:"cursor"`
+	More    bool         `json:"more"`
+}
+
+// UserChunk 用户已上传的内容分块。分块在磁盘上跨用户去重，但查询缺失分块时按用户隔离，
+// 避免通过哈希探测其他用户的文件内容
+type UserChunk struct {
+	Username  string `gorm:"primaryKey;size:64"`
+	Hash      string `gorm:"primaryKey;size:64"`
+	Size      int64
+	CreatedAt time.Time
+}
+
+// ChunkRef 分块清单中的一项。Codec 为该分块的压缩编码，压缩不能缩小的分块以原样保存，Codec 为空
+type ChunkRef struct {
+	Hash  string `json:"hash" binding:"required,len=64,hexadecimal"`
+	Size  int64  `json:"size" binding:"min=0"`
+	Codec string `json:"codec,omitempty" binding:"omitempty,oneof=gzip zstd"`
+}
+
+// MissingChunksRequest 查询服务端缺失的分块
+type MissingChunksRequest struct {
+	Hashes []string `json:"hashes" binding:"required,max=10000,dive,len=64,hexadecimal"`
+}
+
+// CommitFileRequest 以分块清单提交文件新版本
+type CommitFileRequest struct {
+	Path       string     `json:"path" binding:"required"`
+	Size       int64      `json:"size" binding:"min=0"`
+	Hash       string     `json:"hash" binding:"required,len=64,hexadecimal"`
+	Chunks     []ChunkRef `json:"chunks" binding:"dive"`
+	ContentMAC string     `json:"content_mac" binding:"omitempty,len=64,hexadecimal"`
+	Codec      string     `json:"codec" binding:"omitempty,oneof=gzip zstd"`
+}
+
+// UploadSession 分块上传会话，客户端中断后可凭会话 ID 续传
+type UploadSession struct {
+	ID         string    `gorm:"primaryKey;size:36" json:"id"`
+	Username   string    `gorm:"size:64;index;not null" json:"username"`
+	Path       string    `gorm:"size:3775;not null" json:"path"`
+	Size       int64     `json:"size"`
+	Hash       string    `gorm:"size:64;not null" json:"hash"`
+	ContentMAC string    `gorm:"size:64" json:"content_mac"`
+	Codec      string    `gorm:"size:16" json:"codec"`
+	ChunkSize  int64     `json:"chunk_size"`
+	Completed  bool      `gorm:"not null;default:false" json:"completed"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// UploadChunk 已确认接收的上传分块
+type UploadChunk struct {
+	SessionID string `gorm:"primaryKey;size:36"`
+	Index     int    `gorm:"primaryKey;autoIncrement:false"`
+	Size      int64
+	Hash      string `gorm:"size:64"`
+}
+
+// CreateUploadRequest 创建上传会话请求
+type CreateUploadRequest struct {
+	Path       string `json:"path" binding:"required"`
+	Size       int64  `json:"size" binding:"min=0"`
+	Hash       string `json:"hash" binding:"required,len=64,hexadecimal"`
+	ChunkSize  int64  `json:"chunk_size"` // 可选，为 0 时使用服务端默认值
+	ContentMAC string `json:"content_mac" binding:"omitempty,len=64,hexadecimal"`
+	Codec      string `json:"codec" binding:"omitempty,oneof=gzip zstd"`
+}
+
+// UploadSessionInfo 上传会话信息，Received 为服务端已确认的分块序号
+type UploadSessionInfo struct {
+	SessionID   string `json:"session_id"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Hash        string `json:"hash"`
+	ChunkSize   int64  `json:"chunk_size"`
+	TotalChunks int    `json:"total_chunks"`
+	Received    []int  `json:"received"`
+	Completed   bool   `json:"completed"`
+}
+
+// KeyFile 端到端加密根目录的密钥文件，每个用户的每个加密根目录一份。内容是被口令或恢复密钥包装后的主密钥，
+// 服务端只负责保存和分发，无法解开
+type KeyFile struct {
+	Username  string    `gorm:"primaryKey;size:64" json:"-"`
+	RootID    string    `gorm:"primaryKey;size:64;default:default" json:"root_id"` // 客户端 client.encryption.root_id
+	Data      string    `gorm:"type:text;not null" json:"data"`
+	Version   int       `gorm:"not null" json:"version"` // 每次更新加一，用于防止并发覆盖
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PutKeyFileRequest 保存密钥文件，Version 为客户端读取时的版本，首次创建为 0
+type PutKeyFileRequest struct {
+	Data    string `json:"data" binding:"required,max=65536"`
+	Version int    `json:"version" binding:"min=0"`
+}
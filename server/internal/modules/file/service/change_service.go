@@ -0,0 +1,79 @@
+package file_service
+
+import (
+	"fmt"
+	"fsync/server/global"
+	file_model "fsync/server/internal/modules/file/model"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxChangesPerPage 一次拉取返回的最大变更数
+const maxChangesPerPage = 500
+
+// recordChange 在事务中记录一次文件变更
+func recordChange(tx *gorm.DB, username, deviceID, p string, deleted bool) error {
+	change := file_model.FileChange{Username: username, Path: p, Deleted: deleted, DeviceID: deviceID}
+	if err := tx.Create(&change).Error; err != nil {
+		return fmt.Errorf("记录文件变更失败: %w", err)
+	}
+	return nil
+}
+
+// ListChanges 返回 since 之后的文件变更。请求设备自己发起的变更不返回，但游标会越过它们
+func ListChanges(username, deviceID string, since uint64) (*file_model.FileChanges, error) {
+	var rows []file_model.FileChange
+	if err := global.DB.Where("username = ? AND id > ?", username, since).
+		Order("id").Limit(maxChangesPerPage + 1).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询文件变更失败: %w", err)
+	}
+
+	result := &file_model.FileChanges{Changes: make([]file_model.FileChange, 0, len(rows)), Cursor: since}
+	if len(rows) > maxChangesPerPage {
+		rows = rows[:maxChangesPerPage]
+		result.More = true
+	}
+	for _, change := range rows {
+		result.Cursor = change.ID
+		if deviceID != "" && change.DeviceID == deviceID {
+			continue
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	return result, nil
+}
+
+// LatestChange 返回用户最新一次变更的 ID，新设备从这里开始拉取，不重放之前的历史
+func LatestChange(username string) (uint64, error) {
+	var latest file_model.FileChange
+	err := global.DB.Where("username = ?", username).Order("id DESC").Limit(1).Find(&latest).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询文件变更失败: %w", err)
+	}
+	return latest.ID, nil
+}
+
+// RemoveFile 删除用户文件并记录删除，其他设备拉取变更后把本地副本移入回收站。
+// 内容对象和分块可能被其他文件引用，不在这里删除
+func RemoveFile(username, deviceID, p string) error {
+	p, err := CleanPath(p)
+	if err != nil {
+		return err
+	}
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("username = ? AND path_hash = ?", username, pathHash(p)).Delete(&file_model.FileMeta{})
+		if result.Error != nil {
+			return fmt.Errorf("删除文件元数据失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrFileNotFound
+		}
+		return recordChange(tx, username, deviceID, p, true)
+	})
+	if err != nil {
+		return err
+	}
+	global.Logger.Info("文件已删除", zap.String("username", username), zap.String("path", p))
+	return nil
+}
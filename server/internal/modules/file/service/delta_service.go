@@ -0,0 +1,271 @@
+package file_service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fsync/pkg/chunker"
+	"fsync/server/global"
+	file_model "fsync/server/internal/modules/file/model"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrMissingChunks = errors.New("分块清单中存在服务端缺失的分块")
+	ErrSizeMismatch  = errors.New("分块大小之和与文件大小不一致")
+	ErrChunkSize     = errors.New("分块大小与上传时记录的不一致")
+	ErrChunkCodec    = errors.New("分块压缩编码与文件压缩编码不一致")
+)
+
+// MissingChunks 返回用户尚未上传的分块哈希，客户端只需上传这些分块
+func MissingChunks(username string, hashes []string) ([]string, error) {
+	wanted := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		wanted[strings.ToLower(h)] = true
+	}
+	list := make([]string, 0, len(wanted))
+	for h := range wanted {
+		list = append(list, h)
+	}
+
+	var owned []string
+	if len(list) > 0 {
+		if err := global.DB.Model(&file_model.UserChunk{}).
+			Where("username = ? AND hash IN ?", username, list).
+			Pluck("hash", &owned).Error; err != nil {
+			return nil, fmt.Errorf("查询分块失败: %w", err)
+		}
+	}
+	for _, h := range owned {
+		delete(wanted, h)
+	}
+
+	missing := make([]string, 0, len(wanted))
+	for h := range wanted {
+		missing = append(missing, h)
+	}
+	// 有记录但物理文件已被清理的分块同样按缺失处理
+	for _, h := range owned {
+		if !global.Blobs.HasChunk(h) {
+			missing = append(missing, h)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// PutContentChunk 接收一个内容分块并记为用户所有
+func PutContentChunk(username, hash string, body io.Reader) error {
+	hash = strings.ToLower(hash)
+	size, err := global.Blobs.PutChunk(hash, chunker.MaxEncodedSize, body)
+	if err != nil {
+		return err
+	}
+	chunk := file_model.UserChunk{Username: username, Hash: hash, Size: size}
+	if err := global.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&chunk).Error; err != nil {
+		return fmt.Errorf("记录分块失败: %w", err)
+	}
+	return nil
+}
+
+// CommitFile 以分块清单提交文件新版本。各分块上传时已按 SHA256 校验，清单只需引用已有分块且大小
+// 与上传时的记录一致，拼装后的内容即由清单唯一确定。提交不重新读取整个文件，只修改一个分块的大文件
+// 提交代价与分块数量成正比；整体哈希由下载方在拼装后校验
+func CommitFile(username, deviceID string, req *file_model.CommitFileRequest) (*file_model.FileMeta, error) {
+	p, err := CleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	hashes := make([]string, 0, len(req.Chunks))
+	for i := range req.Chunks {
+		req.Chunks[i].Hash = strings.ToLower(req.Chunks[i].Hash)
+		if req.Chunks[i].Codec != "" && req.Chunks[i].Codec != req.Codec {
+			return nil, fmt.Errorf("%w: %s", ErrChunkCodec, req.Chunks[i].Hash)
+		}
+		total += req.Chunks[i].Size
+		hashes = append(hashes, req.Chunks[i].Hash)
+	}
+	if total != req.Size {
+		return nil, ErrSizeMismatch
+	}
+	missing, err := MissingChunks(username, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %d 个", ErrMissingChunks, len(missing))
+	}
+	if err := checkChunkSizes(username, req.Chunks); err != nil {
+		return nil, err
+	}
+
+	manifest, err := json.Marshal(req.Chunks)
+	if err != nil {
+		return nil, err
+	}
+	meta := file_model.FileMeta{
+		Username:   username,
+		Path:       p,
+		PathHash:   pathHash(p),
+		Size:       req.Size,
+		Hash:       strings.ToLower(req.Hash),
+		Manifest:   string(manifest),
+		ContentMAC: strings.ToLower(req.ContentMAC),
+		Codec:      req.Codec,
+	}
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "username"}, {Name: "path_hash"}},
+			DoUpdates: clause.AssignmentColumns([]string{"size", "hash", "manifest", "content_mac", "codec", "updated_at"}),
+		}).Create(&meta).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, username, deviceID, p, false)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("更新文件元数据失败: %w", err)
+	}
+
+	global.Logger.Info("增量同步提交文件", zap.String("username", username), zap.String("path", p),
+		zap.Int("chunks", len(req.Chunks)), zap.Int64("size", req.Size))
+	return &meta, nil
+}
+
+// GetManifest 返回文件的分块清单。各分块的压缩编码可能不同，客户端需要按清单逐块还原；
+// 整块上传的文件没有清单，返回空列表
+func GetManifest(username, p string) ([]file_model.ChunkRef, error) {
+	meta, err := GetFileMeta(username, p)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]file_model.ChunkRef, 0)
+	if meta.Manifest == "" {
+		return chunks, nil
+	}
+	if err := json.Unmarshal([]byte(meta.Manifest), &chunks); err != nil {
+		return nil, fmt.Errorf("解析分块清单失败: %w", err)
+	}
+	return chunks, nil
+}
+
+// checkChunkSizes 校验清单中每个分块的大小与上传时服务端记录的一致
+func checkChunkSizes(username string, chunks []file_model.ChunkRef) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	hashes := make([]string, 0, len(chunks))
+	for _, c := range chunks {
+		hashes = append(hashes, c.Hash)
+	}
+	var stored []file_model.UserChunk
+	if err := global.DB.Where("username = ? AND hash IN ?", username, hashes).Find(&stored).Error; err != nil {
+		return fmt.Errorf("查询分块失败: %w", err)
+	}
+	sizes := make(map[string]int64, len(stored))
+	for _, c := range stored {
+		sizes[c.Hash] = c.Size
+	}
+	for _, c := range chunks {
+		size, ok := sizes[c.Hash]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrMissingChunks, c.Hash)
+		}
+		if size != c.Size {
+			return fmt.Errorf("%w: %s", ErrChunkSize, c.Hash)
+		}
+	}
+	return nil
+}
+
+// manifestReader 按分块清单拼装文件内容，实现 io.ReadSeeker 以支持 HTTP Range
+type manifestReader struct {
+	chunks  []file_model.ChunkRef
+	offsets []int64 // 每个分块在文件中的起始偏移
+	size    int64
+	pos     int64
+
+	current    *os.File
+	currentIdx int
+}
+
+func newManifestReader(manifest string) (*manifestReader, error) {
+	var chunks []file_model.ChunkRef
+	if err := json.Unmarshal([]byte(manifest), &chunks); err != nil {
+		return nil, fmt.Errorf("解析分块清单失败: %w", err)
+	}
+	offsets := make([]int64, len(chunks))
+	var size int64
+	for i, c := range chunks {
+		offsets[i] = size
+		size += c.Size
+	}
+	return &manifestReader{chunks: chunks, offsets: offsets, size: size, currentIdx: -1}, nil
+}
+
+func (r *manifestReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	// 找到 pos 所在的分块
+	idx := sort.Search(len(r.offsets), func(i int) bool { return r.offsets[i] > r.pos }) - 1
+	if idx != r.currentIdx {
+		r.Close()
+		f, err := global.Blobs.OpenChunk(r.chunks[idx].Hash)
+		if err != nil {
+			return 0, fmt.Errorf("读取分块失败: %w", err)
+		}
+		r.current = f
+		r.currentIdx = idx
+	}
+
+	inChunk := r.pos - r.offsets[idx]
+	remain := r.chunks[idx].Size - inChunk
+	if int64(len(p)) > remain {
+		p = p[:remain]
+	}
+	n, err := r.current.ReadAt(p, inChunk)
+	r.pos += int64(n)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *manifestReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.pos + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, fmt.Errorf("无效的 whence: %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("无效的偏移量: %d", abs)
+	}
+	r.pos = abs
+	return abs, nil
+}
+
+// Close 关闭当前打开的分块文件
+func (r *manifestReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	r.currentIdx = -1
+	return err
+}
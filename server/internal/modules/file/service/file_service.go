@@ -0,0 +1,362 @@
+package file_service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fsync/pkg/utils"
+	"fsync/server/global"
+	file_model "fsync/server/internal/modules/file/model"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidPath     = errors.New("非法的文件路径")
+	ErrSessionNotFound = errors.New("上传会话不存在")
+	ErrChunkOutOfRange = errors.New("分块序号超出范围")
+	ErrIncomplete      = errors.New("仍有分块未上传")
+	ErrFileNotFound    = errors.New("文件不存在")
+)
+
+// 端到端加密时每个路径段被加密为 base64url(16 字节 IV + 最多 160 字节名称)，最长 235 个字符。
+// 路径最大长度按加密后的段长和最大深度计算，与 FileMeta.Path 等列宽一致
+const (
+	maxEncryptedSegmentLen = 235
+	maxPathDepth           = 16
+	maxPathLen             = (maxEncryptedSegmentLen+1)*maxPathDepth - 1
+)
+
+// CleanPath 规范化客户端提交的相对路径，拒绝绝对路径和越界路径
+func CleanPath(p string) (string, error) {
+	p = strings.ReplaceAll(p, "\\", "/")
+	if p == "" || strings.HasPrefix(p, "/") || len(p) > maxPathLen {
+		return "", ErrInvalidPath
+	}
+	cleaned := path.Clean(p)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidPath
+	}
+	return cleaned, nil
+}
+
+// MigratePathHash 在自动迁移之前把旧表升级为按路径哈希唯一：补齐 path_hash 并删除 (username, path) 唯一索引。
+// 旧索引限制了路径列宽，不删除时无法把路径列加宽到 maxPathLen
+func MigratePathHash(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&file_model.FileMeta{}) {
+		return nil
+	}
+	if !m.HasColumn(&file_model.FileMeta{}, "PathHash") {
+		if err := m.AddColumn(&file_model.FileMeta{}, "PathHash"); err != nil {
+			return fmt.Errorf("添加 path_hash 列失败: %w", err)
+		}
+		if err := db.Model(&file_model.FileMeta{}).Where("path_hash = ?", "").
+			UpdateColumn("path_hash", gorm.Expr("SHA2(path, 256)")).Error; err != nil {
+			return fmt.Errorf("计算路径哈希失败: %w", err)
+		}
+	}
+	if m.HasIndex(&file_model.FileMeta{}, "idx_user_path") {
+		if err := m.DropIndex(&file_model.FileMeta{}, "idx_user_path"); err != nil {
+			return fmt.Errorf("删除旧路径索引失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// pathHash 返回路径的 SHA256，用于按路径查找文件元数据
+func pathHash(p string) string {
+	sum := sha256.Sum256([]byte(p))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateUploadSession 创建上传会话。同一用户对同一路径、同一内容存在未完成会话时直接返回该会话以便续传
+func CreateUploadSession(username string, req *file_model.CreateUploadRequest) (*file_model.UploadSessionInfo, error) {
+	p, err := CleanPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	req.Hash = strings.ToLower(req.Hash)
+
+	var existing file_model.UploadSession
+	err = global.DB.Where("username = ? AND path = ? AND hash = ? AND size = ? AND completed = ?",
+		username, p, req.Hash, req.Size, false).
+		Order("created_at DESC").First(&existing).Error
+	if err == nil {
+		return sessionInfo(&existing)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询上传会话失败: %w", err)
+	}
+
+	chunkSize := global.Config().Storage.ChunkSize
+	if req.ChunkSize > 0 {
+		chunkSize = req.ChunkSize
+	}
+	if max := global.Config().Storage.MaxChunkSize; max > 0 && chunkSize > max {
+		chunkSize = max
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("分块大小配置无效: %d", chunkSize)
+	}
+
+	id, err := utils.NewUUID()
+	if err != nil {
+		return nil, fmt.Errorf("生成会话 ID 失败: %w", err)
+	}
+	session := &file_model.UploadSession{
+		ID:         id,
+		Username:   username,
+		Path:       p,
+		Size:       req.Size,
+		Hash:       req.Hash,
+		ChunkSize:  chunkSize,
+		ContentMAC: strings.ToLower(req.ContentMAC),
+		Codec:      req.Codec,
+	}
+	if err := global.DB.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("创建上传会话失败: %w", err)
+	}
+
+	global.Logger.Info("创建上传会话", zap.String("session_id", id), zap.String("username", username),
+		zap.String("path", p), zap.Int64("size", req.Size))
+	return sessionInfo(session)
+}
+
+// GetUploadSession 查询上传会话及已接收的分块
+func GetUploadSession(username, sessionID string) (*file_model.UploadSessionInfo, error) {
+	session, err := findSession(username, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return sessionInfo(session)
+}
+
+// PutChunk 接收一个分块，校验大小和哈希后记录为已接收。重复上传同一分块是幂等的
+func PutChunk(username, sessionID string, index int, hash string, body io.Reader) error {
+	session, err := findSession(username, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Completed {
+		return nil
+	}
+	total := totalChunks(session)
+	if index < 0 || index >= total {
+		return ErrChunkOutOfRange
+	}
+
+	size := session.ChunkSize
+	if remain := session.Size - int64(index)*session.ChunkSize; remain < size {
+		size = remain
+	}
+	hash = strings.ToLower(hash)
+	if err := global.Blobs.WriteUploadChunk(sessionID, index, size, hash, body); err != nil {
+		return err
+	}
+
+	chunk := file_model.UploadChunk{SessionID: sessionID, Index: index, Size: size, Hash: hash}
+	if err := global.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&chunk).Error; err != nil {
+		return fmt.Errorf("记录分块失败: %w", err)
+	}
+	return nil
+}
+
+// CompleteUpload 所有分块到齐后拼装文件，校验整体哈希，更新文件元数据并记录变更
+func CompleteUpload(username, deviceID, sessionID string) (*file_model.FileMeta, error) {
+	session, err := findSession(username, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	meta := file_model.FileMeta{
+		Username:   username,
+		Path:       session.Path,
+		PathHash:   pathHash(session.Path),
+		Size:       session.Size,
+		Hash:       session.Hash,
+		ContentMAC: session.ContentMAC,
+		Codec:      session.Codec,
+	}
+	// 重试已完成的会话（如客户端没收到上次的响应）直接返回成功，分块记录已在完成时清理
+	if session.Completed {
+		return &meta, nil
+	}
+
+	var count int64
+	if err := global.DB.Model(&file_model.UploadChunk{}).Where("session_id = ?", sessionID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询分块失败: %w", err)
+	}
+	if int(count) < totalChunks(session) {
+		return nil, ErrIncomplete
+	}
+
+	reader := newChunkReader(sessionID, totalChunks(session))
+	err = global.Blobs.PutBlob(session.Hash, reader)
+	reader.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		// 并发的重复完成请求只有一个能把会话标记为完成，其余的直接返回成功
+		result := tx.Model(session).Where("completed = ?", false).Update("completed", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "username"}, {Name: "path_hash"}},
+			DoUpdates: clause.AssignmentColumns([]string{"size", "hash", "manifest", "content_mac", "codec", "updated_at"}),
+		}).Create(&meta).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", sessionID).Delete(&file_model.UploadChunk{}).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, username, deviceID, session.Path, false)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("更新文件元数据失败: %w", err)
+	}
+	if err := global.Blobs.RemoveUpload(sessionID); err != nil {
+		global.Logger.Warn("清理上传分块失败", zap.String("session_id", sessionID), zap.Error(err))
+	}
+
+	global.Logger.Info("文件上传完成", zap.String("username", username), zap.String("path", session.Path),
+		zap.String("hash", session.Hash))
+	return &meta, nil
+}
+
+// GetFileMeta 查询用户文件元数据
+func GetFileMeta(username, p string) (*file_model.FileMeta, error) {
+	p, err := CleanPath(p)
+	if err != nil {
+		return nil, err
+	}
+	var meta file_model.FileMeta
+	if err := global.DB.Where("username = ? AND path_hash = ?", username, pathHash(p)).First(&meta).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("查询文件失败: %w", err)
+	}
+	return &meta, nil
+}
+
+// OpenFile 打开用户文件用于下载，增量同步提交的文件按分块清单拼装
+func OpenFile(username, p string) (io.ReadSeekCloser, *file_model.FileMeta, error) {
+	meta, err := GetFileMeta(username, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta.Manifest != "" {
+		r, err := newManifestReader(meta.Manifest)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, meta, nil
+	}
+	f, err := global.Blobs.OpenBlob(meta.Hash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开文件内容失败: %w", err)
+	}
+	return f, meta, nil
+}
+
+// findSession 查询属于该用户的上传会话
+func findSession(username, sessionID string) (*file_model.UploadSession, error) {
+	var session file_model.UploadSession
+	if err := global.DB.Where("id = ? AND username = ?", sessionID, username).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("查询上传会话失败: %w", err)
+	}
+	return &session, nil
+}
+
+// sessionInfo 组装会话信息，附带已接收的分块序号
+func sessionInfo(session *file_model.UploadSession) (*file_model.UploadSessionInfo, error) {
+	received := make([]int, 0)
+	if !session.Completed {
+		if err := global.DB.Model(&file_model.UploadChunk{}).Where("session_id = ?", session.ID).
+			Order("`index`").Pluck("index", &received).Error; err != nil {
+			return nil, fmt.Errorf("查询已接收分块失败: %w", err)
+		}
+	}
+	return &file_model.UploadSessionInfo{
+		SessionID:   session.ID,
+		Path:        session.Path,
+		Size:        session.Size,
+		Hash:        session.Hash,
+		ChunkSize:   session.ChunkSize,
+		TotalChunks: totalChunks(session),
+		Received:    received,
+		Completed:   session.Completed,
+	}, nil
+}
+
+// totalChunks 计算会话的分块总数，空文件也占用一个空分块
+func totalChunks(session *file_model.UploadSession) int {
+	if session.Size == 0 {
+		return 1
+	}
+	return int((session.Size + session.ChunkSize - 1) / session.ChunkSize)
+}
+
+// chunkReader 按序号依次读取暂存分块，拼成完整文件内容
+type chunkReader struct {
+	sessionID string
+	total     int
+	next      int
+	current   *os.File
+}
+
+func newChunkReader(sessionID string, total int) *chunkReader {
+	return &chunkReader{sessionID: sessionID, total: total}
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.next >= r.total {
+				return 0, io.EOF
+			}
+			f, err := os.Open(global.Blobs.UploadChunkPath(r.sessionID, r.next))
+			if err != nil {
+				return 0, fmt.Errorf("读取分块 %d 失败: %w", r.next, err)
+			}
+			r.current = f
+			r.next++
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close 关闭正在读取的分块文件
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
@@ -0,0 +1,108 @@
+package file_service
+
+import (
+	"errors"
+	"fmt"
+	"fsync/server/global"
+	file_model "fsync/server/internal/modules/file/model"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrKeyFileNotFound = errors.New("密钥文件不存在")
+	ErrKeyFileConflict = errors.New("密钥文件已被其他设备修改，请重新读取后再试")
+	ErrInvalidRootID   = errors.New("无效的根目录标识")
+)
+
+// DefaultRootID 未指定根目录时使用的标识，与客户端默认值一致
+const DefaultRootID = "default"
+
+// rootIDPattern 根目录标识的格式，与客户端校验一致
+var rootIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,63}$`)
+
+// CleanRootID 校验根目录标识，为空时使用 DefaultRootID
+func CleanRootID(id string) (string, error) {
+	if id == "" {
+		return DefaultRootID, nil
+	}
+	if !rootIDPattern.MatchString(id) {
+		return "", ErrInvalidRootID
+	}
+	return id, nil
+}
+
+// MigrateKeyFileRoot 在自动迁移之前把旧的密钥文件表升级为按用户和根目录区分，原有密钥文件归入 DefaultRootID
+func MigrateKeyFileRoot(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&file_model.KeyFile{}) || m.HasColumn(&file_model.KeyFile{}, "RootID") {
+		return nil
+	}
+	if err := m.AddColumn(&file_model.KeyFile{}, "RootID"); err != nil {
+		return fmt.Errorf("添加 root_id 列失败: %w", err)
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&file_model.KeyFile{}); err != nil {
+		return err
+	}
+	sql := fmt.Sprintf("ALTER TABLE `%s` DROP PRIMARY KEY, ADD PRIMARY KEY (`username`, `root_id`)", stmt.Schema.Table)
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("更新密钥文件主键失败: %w", err)
+	}
+	return nil
+}
+
+// GetKeyFile 读取用户某个加密根目录的密钥文件
+func GetKeyFile(username, rootID string) (*file_model.KeyFile, error) {
+	rootID, err := CleanRootID(rootID)
+	if err != nil {
+		return nil, err
+	}
+	var kf file_model.KeyFile
+	if err := global.DB.Where("username = ? AND root_id = ?", username, rootID).First(&kf).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKeyFileNotFound
+		}
+		return nil, fmt.Errorf("查询密钥文件失败: %w", err)
+	}
+	return &kf, nil
+}
+
+// PutKeyFile 保存某个加密根目录的密钥文件。请求中的版本必须与当前版本一致，避免两台设备同时改口令时互相覆盖
+func PutKeyFile(username, rootID string, req *file_model.PutKeyFileRequest) (*file_model.KeyFile, error) {
+	rootID, err := CleanRootID(rootID)
+	if err != nil {
+		return nil, err
+	}
+	kf := file_model.KeyFile{Username: username, RootID: rootID, Data: req.Data, Version: req.Version + 1}
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if req.Version == 0 {
+			var count int64
+			if err := tx.Model(&file_model.KeyFile{}).Where("username = ? AND root_id = ?", username, rootID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrKeyFileConflict
+			}
+			return tx.Create(&kf).Error
+		}
+		result := tx.Model(&file_model.KeyFile{}).
+			Where("username = ? AND root_id = ? AND version = ?", username, rootID, req.Version).
+			Updates(map[string]interface{}{"data": kf.Data, "version": kf.Version})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrKeyFileConflict
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrKeyFileConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("保存密钥文件失败: %w", err)
+	}
+	return &kf, nil
+}
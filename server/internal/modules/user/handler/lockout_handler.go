@@ -0,0 +1,72 @@
+package user_handler
+
+import (
+	"errors"
+	"fsync/server/internal/audit"
+	"fsync/server/internal/loginguard"
+	"fsync/server/internal/middleware"
+	"fsync/server/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 查询审计日志的默认和最大条数
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 1000
+)
+
+// AdminListLockouts 列出因连续登录失败正在锁定中的用户名和 IP
+func AdminListLockouts(ctx *gin.Context) {
+	lockouts, err := loginguard.List()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.Response{Code: http.StatusInternalServerError, Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: lockouts})
+}
+
+// AdminUnlockUser 解除用户名的锁定
+func AdminUnlockUser(ctx *gin.Context) {
+	username := ctx.Param("username")
+	if err := loginguard.UnlockUser(ctx.GetString(middleware.ContextUsernameKey), username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.Response{Code: http.StatusInternalServerError, Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "已解除锁定"})
+}
+
+// AdminUnlockIP 解除 IP 的锁定
+func AdminUnlockIP(ctx *gin.Context) {
+	err := loginguard.UnlockIP(ctx.GetString(middleware.ContextUsernameKey), ctx.Param("ip"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, loginguard.ErrInvalidIP) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, models.Response{Code: status, Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "已解除锁定"})
+}
+
+// AdminAuditLog 按时间倒序查询审计日志，可按 username 过滤，limit 默认 50
+func AdminAuditLog(ctx *gin.Context) {
+	limit := defaultAuditLimit
+	if s := ctx.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "limit 应为 1-1000"})
+			return
+		}
+		limit = n
+	}
+	entries, err := audit.List(ctx.Query("username"), limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.Response{Code: http.StatusInternalServerError, Msg: "查询审计日志失败: " + err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: entries})
+}
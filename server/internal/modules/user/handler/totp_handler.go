@@ -0,0 +1,105 @@
+package user_handler
+
+import (
+	"errors"
+	"fsync/server/internal/middleware"
+	device_service "fsync/server/internal/modules/device/service"
+	user_model "fsync/server/internal/modules/user/model"
+	user_service "fsync/server/internal/modules/user/service"
+	"fsync/server/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TOTPStatus 查看两步验证状态
+func TOTPStatus(ctx *gin.Context) {
+	status, err := user_service.GetTOTPStatus(ctx.GetString(middleware.ContextUsernameKey))
+	if err != nil {
+		respondTOTPError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "success", Data: status})
+}
+
+// SetupTOTP 生成两步验证密钥
+func SetupTOTP(ctx *gin.Context) {
+	setup, err := user_service.SetupTOTP(ctx.GetString(middleware.ContextUsernameKey))
+	if err != nil {
+		respondTOTPError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "请用验证器应用添加账号后提交第一个验证码", Data: setup})
+}
+
+// EnableTOTP 验证第一个验证码，开启两步验证
+func EnableTOTP(ctx *gin.Context) {
+	var req user_model.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+	codes, err := user_service.EnableTOTP(ctx.GetString(middleware.ContextUsernameKey), req.Code)
+	if err != nil {
+		respondTOTPError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "两步验证已开启", Data: codes})
+}
+
+// DisableTOTP 关闭两步验证
+func DisableTOTP(ctx *gin.Context) {
+	var req user_model.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+	if err := user_service.DisableTOTP(ctx.GetString(middleware.ContextUsernameKey), req.Code); err != nil {
+		respondTOTPError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 生成新的恢复码
+func RegenerateRecoveryCodes(ctx *gin.Context) {
+	var req user_model.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+	codes, err := user_service.RegenerateRecoveryCodes(ctx.GetString(middleware.ContextUsernameKey), req.Code)
+	if err != nil {
+		respondTOTPError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "已生成新的恢复码，旧的恢复码失效", Data: codes})
+}
+
+// AdminResetTOTP 管理员关闭用户的两步验证
+func AdminResetTOTP(ctx *gin.Context) {
+	if err := user_service.ResetTOTP(ctx.GetString(middleware.ContextUsernameKey), ctx.Param("username")); err != nil {
+		respondTOTPError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "已重置该用户的两步验证"})
+}
+
+// respondTOTPError 按服务层错误类型返回对应的状态码
+func respondTOTPError(ctx *gin.Context, err error) {
+	if respondThrottled(ctx, err) {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, user_service.ErrInvalidTOTP), errors.Is(err, user_service.ErrInvalidChallenge):
+		status = http.StatusUnauthorized
+	case errors.Is(err, user_service.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, user_service.ErrTOTPEnabled), errors.Is(err, user_service.ErrTOTPDisabled), errors.Is(err, user_service.ErrTOTPNotSetup):
+		status = http.StatusConflict
+	case errors.Is(err, device_service.ErrDeviceRevoked):
+		status = http.StatusForbidden
+	}
+	ctx.JSON(status, models.Response{Code: status, Msg: err.Error()})
+}
@@ -1,11 +1,151 @@
 package user_handler
 
-import "github.com/gin-gonic/gin"
+import (
+	"crypto/x509"
+	"errors"
+	"fsync/pkg/utils"
+	"fsync/server/internal/loginguard"
+	"fsync/server/internal/middleware"
+	device_service "fsync/server/internal/modules/device/service"
+	user_model "fsync/server/internal/modules/user/model"
+	user_service "fsync/server/internal/modules/user/service"
+	"fsync/server/models"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
 
 func Register(ctx *gin.Context) {
+	var req user_model.RegisterRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	user, err := user_service.Register(&req, ctx.ClientIP())
+	if err != nil {
+		if respondThrottled(ctx, err) {
+			return
+		}
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, user_service.ErrUserExists):
+			status = http.StatusConflict
+		case errors.Is(err, user_service.ErrAdminRequired):
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, models.Response{Code: status, Msg: err.Error()})
+		return
+	}
 
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "注册成功", Data: user})
 }
 
 func Login(ctx *gin.Context) {
+	var req user_model.LoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	tokens, err := user_service.Login(&req, peerCert(ctx), ctx.ClientIP())
+	if err != nil {
+		if respondThrottled(ctx, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, user_service.ErrInvalidCredentials) {
+			status = http.StatusUnauthorized
+		} else if errors.Is(err, device_service.ErrDeviceRevoked) {
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, models.Response{Code: status, Msg: err.Error()})
+		return
+	}
+
+	if tokens.TOTPRequired {
+		ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "请输入两步验证码", Data: tokens})
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "登录成功", Data: tokens})
+}
+
+// LoginTOTP 登录的第二步，提交两步验证码或恢复码
+func LoginTOTP(ctx *gin.Context) {
+	var req user_model.TOTPLoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	tokens, err := user_service.LoginTOTP(&req, peerCert(ctx), ctx.ClientIP())
+	if err != nil {
+		respondTOTPError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "登录成功", Data: tokens})
+}
+
+// Refresh 使用刷新令牌换取新的令牌对
+func Refresh(ctx *gin.Context) {
+	var req user_model.RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.Response{Code: http.StatusBadRequest, Msg: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	tokens, err := user_service.Refresh(req.RefreshToken)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, user_service.ErrInvalidToken) || errors.Is(err, user_service.ErrTokenReused) {
+			status = http.StatusUnauthorized
+		} else if errors.Is(err, device_service.ErrDeviceRevoked) {
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, models.Response{Code: status, Msg: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "刷新成功", Data: tokens})
+}
+
+// Logout 登出当前会话，会话中的访问令牌和刷新令牌立即失效
+func Logout(ctx *gin.Context) {
+	claims := ctx.MustGet(middleware.ContextClaimsKey).(*utils.Claims)
+	if err := user_service.Logout(claims); err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.Response{Code: http.StatusInternalServerError, Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "已登出"})
+}
+
+// LogoutAll 登出当前用户在所有设备上的会话
+func LogoutAll(ctx *gin.Context) {
+	sessions, err := user_service.LogoutAll(ctx.GetString(middleware.ContextUsernameKey))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.Response{Code: http.StatusInternalServerError, Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, models.Response{Code: http.StatusOK, Msg: "已登出所有会话", Data: gin.H{"sessions": sessions}})
+}
+
+// peerCert 返回请求出示的客户端证书（已通过本地 CA 校验），没有时返回 nil
+func peerCert(ctx *gin.Context) *x509.Certificate {
+	if ctx.Request.TLS == nil || len(ctx.Request.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return ctx.Request.TLS.PeerCertificates[0]
+}
 
+// respondThrottled 登录因连续失败被限制时返回 429 和 Retry-After，返回是否已响应
+func respondThrottled(ctx *gin.Context, err error) bool {
+	var throttled *loginguard.ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	ctx.JSON(http.StatusTooManyRequests, models.Response{Code: http.StatusTooManyRequests, Msg: err.Error()})
+	return true
 }
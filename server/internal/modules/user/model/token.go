@@ -0,0 +1,36 @@
+package user_model
+
+import "time"
+
+// RefreshToken 服务端记录的刷新令牌。每次刷新签发新令牌并把旧令牌标记为已使用，
+// 同一次登录经过多次刷新签发的令牌属于同一个会话（令牌家族）
+type RefreshToken struct {
+	ID        string     `gorm:"primaryKey;size:36" json:"id"` // jti
+	SessionID string     `gorm:"size:36;index;not null" json:"session_id"`
+	Username  string     `gorm:"size:64;index;not null" json:"username"`
+	DeviceID  string     `gorm:"size:36;index" json:"device_id"` // 登录时出示设备证书的设备，未登记时为空
+	AccessID  string     `gorm:"size:36;not null" json:"-"`      // 与该刷新令牌一同签发的访问令牌 jti，吊销会话时一并吊销
+	AccessExp time.Time  `json:"-"`
+	ExpiresAt time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`    // 已用于刷新，再次使用说明令牌被盗用
+	RevokedAt *time.Time `json:"revoked_at"` // 登出或检测到盗用时吊销
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedToken 已吊销但尚未过期的访问令牌（jti 黑名单），过期后清理
+type RevokedToken struct {
+	ID        string    `gorm:"primaryKey;size:36"` // jti
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// LoginChallenge 已签发的两步验证挑战令牌。验证码错误达到上限或登录成功后作废，
+// 令牌本身虽未过期也不能再使用，过期后清理
+type LoginChallenge struct {
+	ID        string     `gorm:"primaryKey;size:36"` // jti
+	Username  string     `gorm:"size:64;not null"`
+	Failures  int        `gorm:"not null;default:0"` // 提交错误验证码的次数
+	UsedAt    *time.Time // 已用于完成登录
+	ExpiresAt time.Time  `gorm:"index"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,34 @@
+package user_model
+
+import "time"
+
+// RecoveryCode 两步验证的一次性恢复码，只保存哈希
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey"`
+	Username  string     `gorm:"size:64;index;not null"`
+	CodeHash  string     `gorm:"size:64;not null"` // 规范化后的恢复码的 SHA-256
+	UsedAt    *time.Time // 已使用的恢复码不能再用
+	CreatedAt time.Time
+}
+
+// TOTPSetupResponse 开启两步验证的第一步：新生成的密钥和验证器应用扫描的链接
+type TOTPSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TOTPCodeRequest 提交验证码（或恢复码）
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TOTPStatus 两步验证状态
+type TOTPStatus struct {
+	Enabled       bool `json:"enabled"`
+	RecoveryCodes int  `json:"recovery_codes"` // 剩余可用的恢复码
+}
+
+// RecoveryCodesResponse 新生成的恢复码，只在生成时返回一次
+type RecoveryCodesResponse struct {
+	Codes []string `json:"codes"`
+}
@@ -1 +1,57 @@
-package user_model
\ No newline at end of file
+package user_model
+
+import (
+	"fsync/pkg/utils"
+	device_model "fsync/server/internal/modules/device/model"
+	"time"
+)
+
+// User 用户表
+type User struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"size:64;uniqueIndex;not null" json:"username"`
+	Password  string    `gorm:"size:255;not null" json:"-"` // bcrypt 哈希
+	IsAdmin   bool      `gorm:"not null;default:false" json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 两步验证：TOTPSecret 在 setup 时保存，验证第一个验证码后 TOTPEnabled 才开启
+	TOTPSecret   string `gorm:"column:totp_secret;size:64" json:"-"`
+	TOTPEnabled  bool   `gorm:"column:totp_enabled;not null;default:false" json:"totp_enabled"`
+	TOTPLastStep int64  `gorm:"column:totp_last_step;not null;default:0" json:"-"` // 最近使用的验证码时间步，防止重放
+}
+
+// RegisterRequest 注册请求
+type RegisterRequest struct {
+	Username      string `json:"username" binding:"required"`
+	Password      string `json:"password" binding:"required"`
+	AdminUsername string `json:"admin_username"` // 已存在用户时需要管理员验证
+	AdminPassword string `json:"admin_password"`
+}
+
+// LoginRequest 登录请求
+type LoginRequest struct {
+	Username string            `json:"username" binding:"required"`
+	Password string            `json:"password" binding:"required"`
+	Device   device_model.Info `json:"device"` // 登录的设备
+}
+
+// LoginResponse 登录结果。开启了两步验证的用户密码正确时只返回 Challenge，
+// 客户端再以 TOTPLoginRequest 提交验证码换取令牌
+type LoginResponse struct {
+	*utils.TokenPair
+	TOTPRequired bool   `json:"totp_required,omitempty"`
+	Challenge    string `json:"challenge,omitempty"`
+}
+
+// TOTPLoginRequest 登录的第二步
+type TOTPLoginRequest struct {
+	Challenge string            `json:"challenge" binding:"required"`
+	Code      string            `json:"code" binding:"required"` // 验证码或恢复码
+	Device    device_model.Info `json:"device"`
+}
+
+// RefreshRequest 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
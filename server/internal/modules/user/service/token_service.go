@@ -0,0 +1,242 @@
+package user_service
+
+import (
+	"errors"
+	"fmt"
+	"fsync/pkg/utils"
+	"fsync/server/global"
+	device_model "fsync/server/internal/modules/device/model"
+	device_service "fsync/server/internal/modules/device/service"
+	user_model "fsync/server/internal/modules/user/model"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tokenCleanupInterval 清理过期令牌记录的周期
+const tokenCleanupInterval = time.Hour
+
+var (
+	ErrInvalidToken = errors.New("刷新令牌无效、已过期或已吊销，请重新登录")
+	ErrTokenReused  = errors.New("刷新令牌已被使用过，可能已经泄露，该会话的所有令牌已吊销，请重新登录")
+)
+
+var (
+	// denylist 已吊销访问令牌的 jti 及其过期时间，启动时从数据库加载，每个请求都要检查，因此保存在内存中
+	denylist      = make(map[string]time.Time)
+	denylistMutex sync.RWMutex
+)
+
+// issueTokens 为会话签发令牌对，并记录刷新令牌
+func issueTokens(username, sessionID, deviceID string) (*utils.TokenPair, error) {
+	tokens, err := utils.GenerateTokenPair(username, sessionID, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("签发令牌失败: %w", err)
+	}
+	record := &user_model.RefreshToken{
+		ID:        tokens.RefreshClaims.ID,
+		SessionID: sessionID,
+		Username:  username,
+		DeviceID:  deviceID,
+		AccessID:  tokens.AccessClaims.ID,
+		AccessExp: tokens.AccessClaims.ExpiresAt.Time,
+		ExpiresAt: tokens.RefreshClaims.ExpiresAt.Time,
+	}
+	if err := global.DB.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("保存刷新令牌失败: %w", err)
+	}
+	return tokens, nil
+}
+
+// Refresh 用刷新令牌换取新的令牌对，旧的刷新令牌随即失效（轮换）。
+// 已轮换的刷新令牌再次被使用说明它可能被盗用，此时吊销整个会话，合法持有者和攻击者都需要重新登录
+func Refresh(refreshToken string) (*utils.TokenPair, error) {
+	claims, err := utils.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var stored user_model.RefreshToken
+	if err := global.DB.Where("id = ?", claims.ID).First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("查询刷新令牌失败: %w", err)
+	}
+	if stored.RevokedAt != nil {
+		return nil, ErrInvalidToken
+	}
+	if device_service.IsRevoked(stored.DeviceID) {
+		return nil, device_service.ErrDeviceRevoked
+	}
+
+	// 条件更新，并发的两次刷新只有一次成功
+	result := global.DB.Model(&user_model.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", stored.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新刷新令牌失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		global.Logger.Warn("刷新令牌被重复使用，吊销整个会话",
+			zap.String("username", stored.Username), zap.String("session", stored.SessionID), zap.String("device", stored.DeviceID))
+		if _, err := revokeTokens("session_id = ?", stored.SessionID); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
+	return issueTokens(stored.Username, stored.SessionID, stored.DeviceID)
+}
+
+// Logout 吊销当前令牌所在的会话，包括其中所有的刷新令牌和未过期的访问令牌
+func Logout(claims *utils.Claims) error {
+	if _, err := revokeTokens("session_id = ?", claims.SessionID); err != nil {
+		return err
+	}
+	global.Logger.Info("用户已登出", zap.String("username", claims.Username), zap.String("session", claims.SessionID))
+	return nil
+}
+
+// LogoutAll 吊销用户的所有会话，返回吊销的会话数
+func LogoutAll(username string) (int, error) {
+	sessions, err := revokeTokens("username = ?", username)
+	if err != nil {
+		return 0, err
+	}
+	global.Logger.Info("用户已登出所有会话", zap.String("username", username), zap.Int("sessions", sessions))
+	return sessions, nil
+}
+
+// LogoutDevice 远程登出用户的一台设备：吊销该设备上所有会话的令牌，并断开它的连接
+func LogoutDevice(username, deviceID string) (*device_model.Device, error) {
+	device, err := device_service.Get(username, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := revokeTokens("device_id = ?", device.ID)
+	if err != nil {
+		return nil, err
+	}
+	closed := device_service.CloseConnections(device.ID)
+	global.Logger.Info("设备已被远程登出", zap.String("username", username), zap.String("device", device.ID),
+		zap.Int("sessions", sessions), zap.Int("closed_conns", closed))
+	return device, nil
+}
+
+// RevokeDevice 吊销设备证书，同时吊销该设备上所有会话的令牌，管理员使用
+func RevokeDevice(deviceID string) (*device_model.Device, error) {
+	device, err := device_service.Revoke(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := revokeTokens("device_id = ?", device.ID)
+	if err != nil {
+		return nil, err
+	}
+	global.Logger.Info("已吊销设备的所有会话", zap.String("device", device.ID), zap.String("username", device.Username), zap.Int("sessions", sessions))
+	return device, nil
+}
+
+// revokeTokens 吊销符合条件的刷新令牌，并把与它们一同签发、尚未过期的访问令牌加入黑名单，返回涉及的会话数
+func revokeTokens(query string, args ...interface{}) (int, error) {
+	var tokens []user_model.RefreshToken
+	if err := global.DB.Where(query, args...).Where("revoked_at IS NULL").Find(&tokens).Error; err != nil {
+		return 0, fmt.Errorf("查询刷新令牌失败: %w", err)
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	ids := make([]string, 0, len(tokens))
+	sessions := make(map[string]bool)
+	revoked := make([]user_model.RevokedToken, 0, len(tokens))
+	for _, t := range tokens {
+		ids = append(ids, t.ID)
+		sessions[t.SessionID] = true
+		if t.AccessExp.After(now) {
+			revoked = append(revoked, user_model.RevokedToken{ID: t.AccessID, ExpiresAt: t.AccessExp})
+		}
+	}
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user_model.RefreshToken{}).Where("id IN ?", ids).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if len(revoked) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("吊销令牌失败: %w", err)
+	}
+
+	denylistMutex.Lock()
+	for _, r := range revoked {
+		denylist[r.ID] = r.ExpiresAt
+	}
+	denylistMutex.Unlock()
+	return len(sessions), nil
+}
+
+// IsTokenRevoked 判断访问令牌是否已被吊销
+func IsTokenRevoked(jti string) bool {
+	denylistMutex.RLock()
+	defer denylistMutex.RUnlock()
+	_, ok := denylist[jti]
+	return ok
+}
+
+// LoadDenylist 从数据库加载尚未过期的已吊销访问令牌，启动时调用
+func LoadDenylist() error {
+	var revoked []user_model.RevokedToken
+	if err := global.DB.Where("expires_at > ?", time.Now()).Find(&revoked).Error; err != nil {
+		return fmt.Errorf("加载已吊销令牌失败: %w", err)
+	}
+	denylistMutex.Lock()
+	defer denylistMutex.Unlock()
+	for _, r := range revoked {
+		denylist[r.ID] = r.ExpiresAt
+	}
+	return nil
+}
+
+// WatchTokens 定期清理过期的刷新令牌和黑名单记录，直到 quit 关闭。过期的令牌本身已无法通过校验，不必再记录
+func WatchTokens(quit <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(tokenCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				purgeExpiredTokens()
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// purgeExpiredTokens 删除过期的令牌记录
+func purgeExpiredTokens() {
+	now := time.Now()
+	denylistMutex.Lock()
+	for jti, exp := range denylist {
+		if !exp.After(now) {
+			delete(denylist, jti)
+		}
+	}
+	denylistMutex.Unlock()
+
+	if err := global.DB.Where("expires_at <= ?", now).Delete(&user_model.RevokedToken{}).Error; err != nil {
+		global.Logger.Warn("清理过期的已吊销令牌失败", zap.Error(err))
+	}
+	if err := global.DB.Where("expires_at <= ?", now).Delete(&user_model.RefreshToken{}).Error; err != nil {
+		global.Logger.Warn("清理过期的刷新令牌失败", zap.Error(err))
+	}
+	if err := global.DB.Where("expires_at <= ?", now).Delete(&user_model.LoginChallenge{}).Error; err != nil {
+		global.Logger.Warn("清理过期的挑战令牌失败", zap.Error(err))
+	}
+}
@@ -0,0 +1,337 @@
+package user_service
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fsync/pkg/utils"
+	"fsync/server/global"
+	"fsync/server/internal/loginguard"
+	user_model "fsync/server/internal/modules/user/model"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+	// maxChallengeFailures 同一个挑战令牌允许提交错误验证码的次数，达到后需重新输入密码
+	maxChallengeFailures = 5
+)
+
+var (
+	ErrUserNotFound     = errors.New("用户不存在")
+	ErrInvalidTOTP      = errors.New("验证码或恢复码错误")
+	ErrInvalidChallenge = errors.New("登录验证已过期或已失效，请重新输入用户名和密码")
+	ErrTOTPNotSetup     = errors.New("尚未生成两步验证密钥，请先执行 setup")
+	ErrTOTPEnabled      = errors.New("两步验证已开启")
+	ErrTOTPDisabled     = errors.New("两步验证未开启")
+)
+
+// LoginTOTP 登录的第二步：校验挑战令牌和验证码（或恢复码），通过后开始会话。
+// 每个挑战令牌只能完成一次登录，验证码错误 maxChallengeFailures 次后作废
+func LoginTOTP(req *user_model.TOTPLoginRequest, cert *x509.Certificate, ip string) (*utils.TokenPair, error) {
+	claims, err := utils.ParseChallenge(req.Challenge)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	if err := loginguard.Check(claims.Username, ip); err != nil {
+		return nil, err
+	}
+	if err := checkChallenge(claims); err != nil {
+		return nil, err
+	}
+	user, err := findUser(claims.Username)
+	if err != nil {
+		return nil, err
+	}
+	// 挑战签发后管理员可能已重置两步验证，此时密码验证已足够
+	if user.TOTPEnabled {
+		if err := verifySecondFactor(user, req.Code); err != nil {
+			if errors.Is(err, ErrInvalidTOTP) {
+				loginguard.Fail(user.Username, ip, "两步验证码错误")
+				failChallenge(claims.ID)
+			}
+			return nil, err
+		}
+	}
+	if err := consumeChallenge(claims.ID); err != nil {
+		return nil, err
+	}
+	loginguard.Succeed(user.Username, ip)
+	return startSession(user.Username, cert, &req.Device, ip)
+}
+
+// issueChallenge 签发挑战令牌并记录其 jti
+func issueChallenge(username string) (string, error) {
+	token, claims, err := utils.GenerateChallenge(username)
+	if err != nil {
+		return "", fmt.Errorf("签发挑战令牌失败: %w", err)
+	}
+	record := &user_model.LoginChallenge{ID: claims.ID, Username: username, ExpiresAt: claims.ExpiresAt.Time}
+	if err := global.DB.Create(record).Error; err != nil {
+		return "", fmt.Errorf("保存挑战令牌失败: %w", err)
+	}
+	return token, nil
+}
+
+// checkChallenge 确认挑战令牌已记录、未使用且错误次数未达上限
+func checkChallenge(claims *utils.Claims) error {
+	var challenge user_model.LoginChallenge
+	if err := global.DB.Where("id = ?", claims.ID).First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidChallenge
+		}
+		return fmt.Errorf("查询挑战令牌失败: %w", err)
+	}
+	if challenge.Username != claims.Username || challenge.UsedAt != nil || challenge.Failures >= maxChallengeFailures {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// failChallenge 记录一次错误的验证码，次数达到上限后挑战令牌作废
+func failChallenge(id string) {
+	err := global.DB.Model(&user_model.LoginChallenge{}).Where("id = ?", id).
+		UpdateColumn("failures", gorm.Expr("failures + 1")).Error
+	if err != nil {
+		global.Logger.Warn("记录挑战令牌失败次数失败", zap.String("challenge", id), zap.Error(err))
+	}
+}
+
+// consumeChallenge 把挑战令牌标记为已使用。条件更新，同一个令牌的并发请求只有一个成功
+func consumeChallenge(id string) error {
+	result := global.DB.Model(&user_model.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL AND failures < ?", id, maxChallengeFailures).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("更新挑战令牌失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// SetupTOTP 生成新的两步验证密钥，验证第一个验证码（EnableTOTP）后才开启
+func SetupTOTP(username string) (*user_model.TOTPSetupResponse, error) {
+	user, err := findUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := global.DB.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		return nil, fmt.Errorf("保存两步验证密钥失败: %w", err)
+	}
+	issuer := global.Config().App.Name
+	if issuer == "" {
+		issuer = "fsync"
+	}
+	return &user_model.TOTPSetupResponse{Secret: secret, URI: utils.TOTPURI(issuer, username, secret)}, nil
+}
+
+// EnableTOTP 用验证器应用生成的第一个验证码确认密钥，开启两步验证并生成恢复码
+func EnableTOTP(username, code string) (*user_model.RecoveryCodesResponse, error) {
+	user, err := findUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTOTPNotSetup
+	}
+	if err := verifyTOTPCode(user, code); err != nil {
+		return nil, err
+	}
+	codes, err := newRecoveryCodes(username)
+	if err != nil {
+		return nil, err
+	}
+	if err := global.DB.Model(user).Update("totp_enabled", true).Error; err != nil {
+		return nil, fmt.Errorf("开启两步验证失败: %w", err)
+	}
+	global.Logger.Info("用户已开启两步验证", zap.String("username", username))
+	return &user_model.RecoveryCodesResponse{Codes: codes}, nil
+}
+
+// DisableTOTP 验证验证码（或恢复码）后关闭两步验证
+func DisableTOTP(username, code string) error {
+	user, err := findUser(username)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPDisabled
+	}
+	if err := verifySecondFactor(user, code); err != nil {
+		return err
+	}
+	if err := clearTOTP(user); err != nil {
+		return err
+	}
+	global.Logger.Info("用户已关闭两步验证", zap.String("username", username))
+	return nil
+}
+
+// ResetTOTP 管理员为丢失验证器和恢复码的用户关闭两步验证
+func ResetTOTP(admin, username string) error {
+	user, err := findUser(username)
+	if err != nil {
+		return err
+	}
+	if err := clearTOTP(user); err != nil {
+		return err
+	}
+	global.Logger.Warn("管理员重置了用户的两步验证", zap.String("admin", admin), zap.String("username", username))
+	return nil
+}
+
+// RegenerateRecoveryCodes 验证验证码（或恢复码）后生成新的恢复码，旧的全部失效
+func RegenerateRecoveryCodes(username, code string) (*user_model.RecoveryCodesResponse, error) {
+	user, err := findUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPDisabled
+	}
+	if err := verifySecondFactor(user, code); err != nil {
+		return nil, err
+	}
+	codes, err := newRecoveryCodes(username)
+	if err != nil {
+		return nil, err
+	}
+	return &user_model.RecoveryCodesResponse{Codes: codes}, nil
+}
+
+// GetTOTPStatus 返回两步验证状态和剩余的恢复码数量
+func GetTOTPStatus(username string) (*user_model.TOTPStatus, error) {
+	user, err := findUser(username)
+	if err != nil {
+		return nil, err
+	}
+	status := &user_model.TOTPStatus{Enabled: user.TOTPEnabled}
+	if user.TOTPEnabled {
+		var count int64
+		if err := global.DB.Model(&user_model.RecoveryCode{}).
+			Where("username = ? AND used_at IS NULL", username).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("查询恢复码失败: %w", err)
+		}
+		status.RecoveryCodes = int(count)
+	}
+	return status, nil
+}
+
+// verifySecondFactor 校验验证码，不是 6 位数字时按恢复码校验
+func verifySecondFactor(user *user_model.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == utils.TOTPDigits && strings.Trim(code, "0123456789") == "" {
+		return verifyTOTPCode(user, code)
+	}
+	return useRecoveryCode(user.Username, code)
+}
+
+// verifyTOTPCode 校验验证码，每个时间步的验证码只能使用一次
+func verifyTOTPCode(user *user_model.User, code string) error {
+	step, ok := utils.VerifyTOTP(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return ErrInvalidTOTP
+	}
+	// 条件更新，并发提交同一个验证码时只有一次成功
+	result := global.DB.Model(&user_model.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return fmt.Errorf("更新两步验证状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTOTP
+	}
+	user.TOTPLastStep = step
+	return nil
+}
+
+// useRecoveryCode 使用一个恢复码，用过后失效
+func useRecoveryCode(username, code string) error {
+	result := global.DB.Model(&user_model.RecoveryCode{}).
+		Where("username = ? AND code_hash = ? AND used_at IS NULL", username, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("校验恢复码失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTOTP
+	}
+	global.Logger.Warn("用户使用了恢复码", zap.String("username", username))
+	return nil
+}
+
+// newRecoveryCodes 生成新的恢复码并替换旧的，返回明文，只在此时显示给用户
+func newRecoveryCodes(username string) ([]string, error) {
+	codes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]user_model.RecoveryCode, len(codes))
+	for i, code := range codes {
+		records[i] = user_model.RecoveryCode{Username: username, CodeHash: hashRecoveryCode(code)}
+	}
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("username = ?", username).Delete(&user_model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存恢复码失败: %w", err)
+	}
+	return codes, nil
+}
+
+// clearTOTP 关闭两步验证，删除密钥和恢复码
+func clearTOTP(user *user_model.User) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret": "", "totp_enabled": false, "totp_last_step": 0,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("关闭两步验证失败: %w", err)
+		}
+		if err := tx.Where("username = ?", user.Username).Delete(&user_model.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("删除恢复码失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// hashRecoveryCode 返回规范化后的恢复码的 SHA-256。恢复码是高熵随机值，不需要慢哈希
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(utils.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// findUser 按用户名查询用户
+func findUser(username string) (*user_model.User, error) {
+	var user user_model.User
+	if err := global.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return &user, nil
+}
@@ -1 +1,194 @@
-package user_service
\ No newline at end of file
+package user_service
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"fsync/pkg/crypto"
+	"fsync/pkg/utils"
+	"fsync/server/global"
+	"fsync/server/internal/loginguard"
+	device_model "fsync/server/internal/modules/device/model"
+	device_service "fsync/server/internal/modules/device/service"
+	user_model "fsync/server/internal/modules/user/model"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserExists         = errors.New("用户名已存在")
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrAdminRequired      = errors.New("需要管理员用户名和密码验证")
+)
+
+// Register 注册用户。系统中没有任何用户时，第一个注册的用户成为管理员；之后注册需要管理员验证，
+// 管理员密码错误与登录失败一样计入失败次数
+func Register(req *user_model.RegisterRequest, ip string) (*user_model.User, error) {
+	if err := utils.ValidatePassword(req.Password); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := global.DB.Model(&user_model.User{}).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询用户数量失败: %w", err)
+	}
+	if count == 0 {
+		user, err := createFirstAdmin(req.Username, req.Password)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			global.Logger.Info("用户注册成功", zap.String("username", user.Username), zap.Bool("is_admin", true))
+			return user, nil
+		}
+		// 并发注册的其他用户已成为管理员，按普通注册处理
+	}
+
+	if err := loginguard.Check(req.AdminUsername, ip); err != nil {
+		return nil, err
+	}
+	admin, err := authenticate(req.AdminUsername, req.AdminPassword)
+	if errors.Is(err, ErrInvalidCredentials) {
+		loginguard.Fail(req.AdminUsername, ip, "注册时管理员验证失败")
+	}
+	if err != nil || !admin.IsAdmin {
+		return nil, ErrAdminRequired
+	}
+
+	var existing int64
+	if err := global.DB.Model(&user_model.User{}).Where("username = ?", req.Username).Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if existing > 0 {
+		return nil, ErrUserExists
+	}
+
+	hashed, err := crypto.HashStringByBcrypt(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+	user := &user_model.User{Username: req.Username, Password: hashed}
+	if err := global.DB.Create(user).Error; err != nil {
+		return nil, fmt.Errorf("创建用户失败: %w", err)
+	}
+
+	global.Logger.Info("用户注册成功", zap.String("username", user.Username), zap.Bool("is_admin", false))
+	return user, nil
+}
+
+// createFirstAdmin 在用户表为空时创建管理员。插入语句自身检查用户表为空，
+// 并发的首次注册只有一个成功，其余返回 nil 后按普通注册处理
+func createFirstAdmin(username, password string) (*user_model.User, error) {
+	hashed, err := crypto.HashStringByBcrypt(password)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+
+	var user *user_model.User
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Exec("INSERT INTO users (username, password, is_admin, created_at, updated_at) "+
+			"SELECT ?, ?, TRUE, ?, ? FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM users)",
+			username, hashed, now, now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		user = &user_model.User{}
+		return tx.Where("username = ?", username).First(user).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建管理员失败: %w", err)
+	}
+	return user, nil
+}
+
+// Login 校验用户名密码，在登录的设备上开始新的会话并签发令牌对。开启了两步验证的用户只返回挑战令牌，
+// 由 LoginTOTP 完成登录。cert 为请求出示的设备证书，没有时为 nil
+func Login(req *user_model.LoginRequest, cert *x509.Certificate, ip string) (*user_model.LoginResponse, error) {
+	if err := loginguard.Check(req.Username, ip); err != nil {
+		return nil, err
+	}
+	user, err := authenticate(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			loginguard.Fail(req.Username, ip, "密码错误")
+		}
+		return nil, err
+	}
+	// 密码正确但还需要两步验证时不清除失败记录，否则知道密码的人可以反复登录来清零验证码的失败次数
+	if user.TOTPEnabled {
+		challenge, err := issueChallenge(user.Username)
+		if err != nil {
+			return nil, err
+		}
+		return &user_model.LoginResponse{TOTPRequired: true, Challenge: challenge}, nil
+	}
+	loginguard.Succeed(user.Username, ip)
+	tokens, err := startSession(user.Username, cert, &req.Device, ip)
+	if err != nil {
+		return nil, err
+	}
+	return &user_model.LoginResponse{TokenPair: tokens}, nil
+}
+
+// startSession 登记登录的设备，开始新的会话并签发令牌对。出示了有效设备证书时使用证书对应的设备，
+// 否则按客户端上报的设备 ID 查找或登记新设备；证书未登记或不属于该用户时忽略，用户登录后可执行 `device enroll` 申请新证书。
+// 证书或设备已吊销时拒绝登录
+func startSession(username string, cert *x509.Certificate, info *device_model.Info, ip string) (*utils.TokenPair, error) {
+	certDeviceID := ""
+	if cert != nil {
+		device, err := device_service.Authenticate(cert, username)
+		switch {
+		case err == nil:
+			certDeviceID = device.ID
+		case errors.Is(err, device_service.ErrDeviceRevoked):
+			return nil, err
+		default:
+			global.Logger.Info("登录请求的设备证书无效，忽略", zap.String("username", username), zap.Error(err))
+		}
+	}
+	device, err := device_service.Register(username, certDeviceID, info, ip)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := utils.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(username, sessionID, device.ID)
+}
+
+// authenticate 校验用户名和密码
+func authenticate(username, password string) (*user_model.User, error) {
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	var user user_model.User
+	if err := global.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if !crypto.VerifyStringWithBcrypt(user.Password, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+// IsAdmin 判断用户是否为管理员
+func IsAdmin(username string) (bool, error) {
+	var user user_model.User
+	if err := global.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return user.IsAdmin, nil
+}
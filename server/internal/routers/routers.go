@@ -3,38 +3,52 @@ package routers
 
 import (
 	"fsync/server/global"
+	"fsync/server/internal/auth"
+	"fsync/server/internal/middleware"
 	chat_handler "fsync/server/internal/modules/chat/handler"
+	device_handler "fsync/server/internal/modules/device/handler"
+	file_handler "fsync/server/internal/modules/file/handler"
 	user_handler "fsync/server/internal/modules/user/handler"
-	"fsync/server/models"
 	"fsync/server/logger"
+	"fsync/server/models"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 func InitRouter() *gin.Engine {
-	if global.Configs.App.Env == "production" {
+	if global.Config().App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	} else {
 		gin.SetMode(gin.DebugMode)
 	}
 
 	r := gin.New()
+	// 只采信可信反向代理转发的 X-Forwarded-For。gin 默认信任所有来源，任何人都能伪造客户端 IP，
+	// 绕过按 IP 的限流和登录失败计数，甚至让其他 IP 被锁定
+	if err := r.SetTrustedProxies(global.Config().Server.TrustedProxies); err != nil {
+		global.Logger.Panic("设置可信代理失败", zap.Error(err))
+	}
 
 	r.Use(logger.GinLogger(global.Logger))
 	r.Use(gin.Recovery())
+	r.Use(middleware.CORS())
 
 	// 注册路由组
 	registerChatRoutes(r)
 	registerUserRoutes(r)
+	registerFileRoutes(r)
+	registerDeviceRoutes(r)
 
 	r.GET("/health", healthCheck)
+	r.GET("/.well-known/jwks.json", auth.JWKS)
 	return r
 }
 
 // registerChatRoutes 注册 chat 相关路由
 func registerChatRoutes(r *gin.Engine) {
-	chatGroup := r.Group("/chat")
+	chatGroup := r.Group("/chat", middleware.RateLimit(middleware.RateLimitAPI))
 	{
 		chatGroup.POST("/chat", chat_handler.GetChatHistory)
 	}
@@ -42,10 +56,74 @@ func registerChatRoutes(r *gin.Engine) {
 
 // registerUserRoutes 注册 user 相关路由
 func registerUserRoutes(r *gin.Engine) {
-	userGroup := r.Group("/user")
+	userGroup := r.Group("/user", middleware.RateLimit(middleware.RateLimitAuth))
 	{
 		userGroup.POST("/register", user_handler.Register)
 		userGroup.POST("/login", user_handler.Login)
+		userGroup.POST("/login/totp", user_handler.LoginTOTP)
+		userGroup.POST("/refresh", user_handler.Refresh)
+	}
+	sessionGroup := r.Group("/user", middleware.RateLimit(middleware.RateLimitIP), middleware.JWTAuth(), middleware.RateLimit(middleware.RateLimitAPI), middleware.DeviceAuth())
+	{
+		sessionGroup.POST("/logout", user_handler.Logout)
+		sessionGroup.POST("/logout/all", user_handler.LogoutAll)
+		sessionGroup.GET("/totp", user_handler.TOTPStatus)
+		sessionGroup.POST("/totp/setup", user_handler.SetupTOTP)
+		sessionGroup.POST("/totp/enable", user_handler.EnableTOTP)
+		sessionGroup.POST("/totp/disable", user_handler.DisableTOTP)
+		sessionGroup.POST("/totp/recovery-codes", user_handler.RegenerateRecoveryCodes)
+	}
+	adminGroup := r.Group("/admin/users", middleware.RateLimit(middleware.RateLimitIP), middleware.JWTAuth(), middleware.RateLimit(middleware.RateLimitAPI), middleware.DeviceAuth(), middleware.RequireAdmin())
+	{
+		adminGroup.POST("/:username/totp/reset", user_handler.AdminResetTOTP)
+		adminGroup.POST("/:username/unlock", user_handler.AdminUnlockUser)
+	}
+	securityGroup := r.Group("/admin", middleware.RateLimit(middleware.RateLimitIP), middleware.JWTAuth(), middleware.RateLimit(middleware.RateLimitAPI), middleware.DeviceAuth(), middleware.RequireAdmin())
+	{
+		securityGroup.GET("/lockouts", user_handler.AdminListLockouts)
+		securityGroup.POST("/ips/:ip/unlock", user_handler.AdminUnlockIP)
+		securityGroup.GET("/audit", user_handler.AdminAuditLog)
+	}
+}
+
+// registerFileRoutes 注册文件传输相关路由
+func registerFileRoutes(r *gin.Engine) {
+	fileGroup := r.Group("/file", middleware.RateLimit(middleware.RateLimitIP), middleware.JWTAuth(), middleware.RateLimit(middleware.RateLimitFile), middleware.DeviceAuth())
+	{
+		fileGroup.POST("/uploads", file_handler.CreateUploadSession)
+		fileGroup.GET("/uploads/:id", file_handler.GetUploadSession)
+		fileGroup.PUT("/uploads/:id/chunks/:index", file_handler.PutChunk)
+		fileGroup.POST("/uploads/:id/complete", file_handler.CompleteUpload)
+		fileGroup.POST("/chunks/missing", file_handler.MissingChunks)
+		fileGroup.PUT("/chunks/:hash", file_handler.PutContentChunk)
+		fileGroup.POST("/commit", file_handler.CommitFile)
+		fileGroup.GET("/meta", file_handler.GetFileMeta)
+		fileGroup.GET("/manifest", file_handler.GetManifest)
+		fileGroup.DELETE("", file_handler.RemoveFile)
+		fileGroup.GET("/changes", file_handler.ListChanges)
+		fileGroup.GET("/keyfile", file_handler.GetKeyFile)
+		fileGroup.PUT("/keyfile", file_handler.PutKeyFile)
+		fileGroup.GET("/download", file_handler.Download)
+	}
+}
+
+// registerDeviceRoutes 注册设备管理和设备证书相关路由
+func registerDeviceRoutes(r *gin.Engine) {
+	deviceGroup := r.Group("/device", middleware.RateLimit(middleware.RateLimitIP), middleware.JWTAuth(), middleware.RateLimit(middleware.RateLimitAPI), middleware.DeviceAuth())
+	{
+		deviceGroup.GET("", device_handler.List)
+		deviceGroup.PUT("/:id", device_handler.Rename)
+		deviceGroup.POST("/:id/logout", device_handler.Logout)
+	}
+	// 申请证书时设备可能还没有证书，不要求出示，但出示了已吊销或不符的证书时同样拒绝
+	enrollGroup := r.Group("/device", middleware.RateLimit(middleware.RateLimitIP), middleware.JWTAuth(), middleware.RateLimit(middleware.RateLimitAPI), middleware.DeviceAuthOptional())
+	{
+		enrollGroup.POST("/enroll", device_handler.Enroll)
+	}
+	adminGroup := r.Group("/admin", middleware.RateLimit(middleware.RateLimitIP), middleware.JWTAuth(), middleware.RateLimit(middleware.RateLimitAPI), middleware.DeviceAuth(), middleware.RequireAdmin())
+	{
+		adminGroup.GET("/devices", device_handler.AdminList)
+		adminGroup.POST("/devices/:id/revoke", device_handler.AdminRevoke)
 	}
 }
 
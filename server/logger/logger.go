@@ -12,6 +12,9 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// atomicLevel 当前日志级别，重新加载配置时可在运行中调整
+var atomicLevel = zap.NewAtomicLevel()
+
 // InitLogger 根据配置初始化zap日志记录器
 func InitLogger() error {
 	// 获取当前工作目录
@@ -21,8 +24,8 @@ func InitLogger() error {
 	}
 
 	// 处理普通日志输出路径
-	outputPaths := make([]string, len(global.Configs.Logger.OutputPaths))
-	for i, path := range global.Configs.Logger.OutputPaths {
+	outputPaths := make([]string, len(global.Config().Logger.OutputPaths))
+	for i, path := range global.Config().Logger.OutputPaths {
 		if path == "stdout" || path == "stderr" {
 			outputPaths[i] = path
 		} else {
@@ -41,8 +44,8 @@ func InitLogger() error {
 	}
 
 	// 处理错误日志输出路径
-	errorOutputPaths := make([]string, len(global.Configs.Logger.ErrorOutputPaths))
-	for i, path := range global.Configs.Logger.ErrorOutputPaths {
+	errorOutputPaths := make([]string, len(global.Config().Logger.ErrorOutputPaths))
+	for i, path := range global.Config().Logger.ErrorOutputPaths {
 		if path == "stderr" || path == "stdout" {
 			errorOutputPaths[i] = path
 		} else {
@@ -62,15 +65,17 @@ func InitLogger() error {
 
 	// 解析日志级别
 	var level zapcore.Level
-	if err := level.UnmarshalText([]byte(global.Configs.Logger.Level)); err != nil {
-		return fmt.Errorf("无效的日志级别: %s", global.Configs.Logger.Level)
+	if err := level.UnmarshalText([]byte(global.Config().Logger.Level)); err != nil {
+		return fmt.Errorf("无效的日志级别: %s", global.Config().Logger.Level)
 	}
 
+	atomicLevel.SetLevel(level)
+
 	// 创建zap配置
 	config := zap.Config{
-		Level:            zap.NewAtomicLevelAt(level),
-		Development:      global.Configs.App.Env == "development",
-		Encoding:         global.Configs.Logger.Format,
+		Level:            atomicLevel,
+		Development:      global.Config().App.Env == "development",
+		Encoding:         global.Config().Logger.Format,
 		OutputPaths:      outputPaths,
 		ErrorOutputPaths: errorOutputPaths,
 		EncoderConfig: zapcore.EncoderConfig{
@@ -89,11 +94,11 @@ func InitLogger() error {
 	}
 
 	// 根据配置启用调用者和堆栈跟踪
-	if global.Configs.Logger.EnableCaller {
+	if global.Config().Logger.EnableCaller {
 		config.EncoderConfig.CallerKey = "caller"
 	}
 
-	if global.Configs.Logger.EnableStacktrace {
+	if global.Config().Logger.EnableStacktrace {
 		config.EncoderConfig.StacktraceKey = "stacktrace"
 	}
 
@@ -113,6 +118,16 @@ func InitLogger() error {
 	return nil
 }
 
+// SetLevel 在运行中调整日志级别
+func SetLevel(text string) error {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(text)); err != nil {
+		return fmt.Errorf("无效的日志级别: %s", text)
+	}
+	atomicLevel.SetLevel(level)
+	return nil
+}
+
 // Sync 同步日志缓冲区，在程序退出前调用
 func Sync() {
 	if global.Logger != nil {
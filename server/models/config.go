@@ -1,14 +1,21 @@
 package models
 
-import "time"
+import (
+	"net"
+	"strconv"
+	"time"
+)
 
 // Config 是整个应用的配置根结构体
 type Config struct {
-	App      AppConfig      `mapstructure:"app"`
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Logger   LoggerConfig   `mapstructure:"logger"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
+	App        AppConfig        `mapstructure:"app"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Logger     LoggerConfig     `mapstructure:"logger"`
+	JWT        JWTConfig        `mapstructure:"jwt"`
+	LoginGuard LoginGuardConfig `mapstructure:"login_guard"`
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Storage    StorageConfig    `mapstructure:"storage"`
 }
 
 // AppConfig 应用基本信息
@@ -20,11 +27,42 @@ type AppConfig struct {
 
 // ServerConfig HTTP 服务配置
 type ServerConfig struct {
-	Host         string        `mapstructure:"host"`
-	Port         string        `mapstructure:"port"`
-	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
-	WriteTimeout time.Duration `mapstructure:"write_timeout"`
-	CorsEnabled  bool          `mapstructure:"cors_enabled"`
+	Host            string        `mapstructure:"host"`
+	Port            int           `mapstructure:"port"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	CorsEnabled     bool          `mapstructure:"cors_enabled"`
+	CorsOrigins     []string      `mapstructure:"cors_origins"`     // 允许跨域访问的来源，为空时拒绝所有跨域请求
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"` // 退出时等待进行中的请求完成的最长时间
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，只有来自这些地址的请求才按 X-Forwarded-For 确定客户端 IP，
+	// 为空时始终使用连接的对端地址
+	TrustedProxies []string  `mapstructure:"trusted_proxies"`
+	TLS            TLSConfig `mapstructure:"tls"`
+}
+
+// TLSConfig HTTPS 配置
+type TLSConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	CertFile      string        `mapstructure:"cert_file"`
+	KeyFile       string        `mapstructure:"key_file"`
+	AutoGenerate  bool          `mapstructure:"auto_generate"`  // 证书或私钥文件不存在时用本地 CA 签发，临近过期时自动续期
+	CACertFile    string        `mapstructure:"ca_cert_file"`   // 本地 CA 证书，不存在时自动生成
+	CAKeyFile     string        `mapstructure:"ca_key_file"`    // 本地 CA 私钥
+	KeyType       string        `mapstructure:"key_type"`       // 生成的密钥算法：ecdsa（P-256）或 ed25519
+	Hosts         []string      `mapstructure:"hosts"`          // 服务端证书中 127.0.0.1、localhost 之外的 IP 或域名
+	Validity      time.Duration `mapstructure:"validity"`       // 服务端证书的有效期
+	CAValidity    time.Duration `mapstructure:"ca_validity"`    // 本地 CA 的有效期
+	RenewBefore   time.Duration `mapstructure:"renew_before"`   // 自动生成的证书剩余有效期少于该时长时续期
+	MinVersion    string        `mapstructure:"min_version"`    // 最低 TLS 版本：1.3 或 1.2
+	ExpiryWarning time.Duration `mapstructure:"expiry_warning"` // 证书剩余有效期少于该时长时记录错误日志
+	// 设备证书（双向 TLS）：由本地 CA 按客户端的证书签名请求签发
+	DeviceValidity    time.Duration `mapstructure:"device_validity"`     // 设备证书的有效期
+	RequireDeviceCert bool          `mapstructure:"require_device_cert"` // 文件接口是否必须携带已登记的设备证书
+}
+
+// Addr 返回监听地址，如 0.0.0.0:18084
+func (c ServerConfig) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
 }
 
 // DatabaseConfig MySQL 数据库配置
@@ -54,8 +92,41 @@ type LoggerConfig struct {
 
 // JWTConfig JWT 认证配置
 type JWTConfig struct {
-	Access             string `mapstructure:"access_secret"`
-	Refresh            string `mapstructure:"refresh_secret"`
-	AccessTokenExpire  int    `mapstructure:"access_token_expire"`
-	RefreshTokenExpire int    `mapstructure:"refresh_token_expire"`
+	Algorithm          string        `mapstructure:"algorithm"`    // 签名算法：EdDSA 或 ES256
+	KeyDir             string        `mapstructure:"key_dir"`      // 签名密钥目录，没有密钥时自动生成
+	RotateAfter        time.Duration `mapstructure:"rotate_after"` // 签名密钥使用超过该时长后换用新密钥，旧密钥继续用于校验
+	AccessTokenExpire  int           `mapstructure:"access_token_expire"`
+	RefreshTokenExpire int           `mapstructure:"refresh_token_expire"`
+}
+
+// LoginGuardConfig 登录防护配置：连续失败后需要等待，失败次数达到阈值后临时锁定用户名或 IP
+type LoginGuardConfig struct {
+	Store         string        `mapstructure:"store"`           // 失败记录的存储：memory（单个服务实例）或 database（多个实例共享）
+	MaxFailures   int           `mapstructure:"max_failures"`    // 同一用户名连续失败该次数后锁定，0 表示不锁定
+	IPMaxFailures int           `mapstructure:"ip_max_failures"` // 同一 IP 连续失败该次数后锁定，0 表示不锁定
+	BaseDelay     time.Duration `mapstructure:"base_delay"`      // 失败后再次尝试前需要等待的时间，每多失败一次加倍
+	MaxDelay      time.Duration `mapstructure:"max_delay"`       // 等待时间的上限
+	Lockout       time.Duration `mapstructure:"lockout"`         // 首次锁定的时长，之后每次锁定加倍
+	MaxLockout    time.Duration `mapstructure:"max_lockout"`     // 锁定时长的上限
+	ResetAfter    time.Duration `mapstructure:"reset_after"`     // 超过该时长没有失败且未锁定时清除记录，锁定时长重新计算
+}
+
+// RateLimitConfig 接口限流配置，每个路由组使用 Rules 中的一条规则
+type RateLimitConfig struct {
+	Enabled bool                     `mapstructure:"enabled"`
+	Rules   map[string]RateLimitRule `mapstructure:"rules"` // 规则名（auth、api、file）到规则，未配置的规则不限制
+}
+
+// RateLimitRule 令牌桶限流规则：每个用户（未认证时每个 IP）在 Per 内平均允许 Requests 个请求
+type RateLimitRule struct {
+	Requests int           `mapstructure:"requests"` // 0 表示不限制
+	Per      time.Duration `mapstructure:"per"`
+	Burst    int           `mapstructure:"burst"` // 令牌桶容量，即允许的突发请求数，0 时等于 requests
+}
+
+// StorageConfig 文件存储配置
+type StorageConfig struct {
+	DataDir      string `mapstructure:"data_dir"`       // 对象与上传分块的存储目录
+	ChunkSize    int64  `mapstructure:"chunk_size"`     // 默认上传分块大小（字节）
+	MaxChunkSize int64  `mapstructure:"max_chunk_size"` // 客户端可请求的最大分块大小（字节）
 }
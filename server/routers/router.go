@@ -1,7 +1,6 @@
 package routers
 
 import (
-	"fmt"
 	"fsync/server/global"
 	"net/http"
 	"time"
@@ -13,7 +12,7 @@ import (
 // InitRouter 初始化gin路由
 func InitRouter() *gin.Engine {
 	// 根据环境设置gin模式
-	if global.Configs.App.Env == "production" {
+	if global.Config().App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	} else {
 		gin.SetMode(gin.DebugMode)
@@ -77,5 +76,5 @@ func healthCheck(c *gin.Context) {
 
 // GetAddr 获取服务监听地址
 func GetAddr() string {
-	return fmt.Sprintf("%s:%d", global.Configs.Server.Host, global.Configs.Server.Port)
+	return global.Config().Server.Addr()
 }